@@ -0,0 +1,115 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+)
+
+var (
+	// autoDetectAuthModes are the auth modes tried in order when detecting the auth mode of a device.
+	// AuthModeNone is tried last, and only without a username, as many cameras allow some functions to be called
+	// without authentication.
+	autoDetectAuthModes = []string{AuthModeUsernameToken, AuthModeDigest, AuthModeBoth, AuthModeNone}
+)
+
+// resolveAuthMode returns the credentials with the auth mode which should be used for the device. When the
+// credentials use AuthModeAuto, the auth mode detected for the device is used, or AuthModeUsernameToken if the auth
+// mode has not been detected yet.
+func (d *Driver) resolveAuthMode(device models.Device, credential Credentials) Credentials {
+	if credential.AuthMode != AuthModeAuto {
+		return credential
+	}
+
+	credential.AuthMode = AuthModeUsernameToken
+	if detected := device.Protocols[OnvifProtocol][DetectedAuthMode]; detected != "" && detected != AuthModeAuto && IsAuthModeValid(detected) {
+		credential.AuthMode = detected
+	}
+	return credential
+}
+
+// detectableAuthModes returns the auth modes to try in order when detecting the auth mode of a device, skipping the
+// auth mode which is already known to fail. AuthModeNone is only tried for the credentials without a username, so
+// wrong credentials are not hidden by a camera which does not require authentication.
+func detectableAuthModes(credential Credentials, failedMode string) []string {
+	var modes []string
+	for _, mode := range autoDetectAuthModes {
+		if mode == failedMode || (mode == AuthModeNone && credential.Username != "") {
+			continue
+		}
+		modes = append(modes, mode)
+	}
+	return modes
+}
+
+// startAuthModeDetection detects the auth mode of the device in the background, as the attempts are rate limited, and
+// stores the detected auth mode in the DetectedAuthMode protocol property of the device. Returns false if the
+// detection was skipped, since the credentials of the device are already being probed.
+func (d *Driver) startAuthModeDetection(device models.Device, credential Credentials, failedMode string) bool {
+	if !d.credentialProber.start(device.Name) {
+		return false
+	}
+	go func() {
+		defer d.credentialProber.finish(device.Name)
+		mode, found := d.detectAuthMode(device, credential, failedMode)
+		if !found {
+			return
+		}
+		if err := d.storeDetectedAuthMode(device.Name, mode); err != nil {
+			d.lc.Errorf("Failed to store the detected auth mode of device %s: %s", device.Name, err.Error())
+		}
+	}()
+	return true
+}
+
+// storeDetectedAuthMode sets the DetectedAuthMode protocol property of the device, which is used by the next status
+// check and the client of the device
+func (d *Driver) storeDetectedAuthMode(deviceName string, mode string) error {
+	device, err := d.sdkService.GetDeviceByName(deviceName)
+	if err != nil {
+		return err
+	}
+	device.Protocols[OnvifProtocol][DetectedAuthMode] = mode
+	return d.sdkService.UpdateDevice(device)
+}
+
+// detectAuthMode tries each of the detectable auth modes in order against the device by calling GetDeviceInformation.
+// The failed attempts are rate limited like the credential probing, to avoid triggering the lockout protection of
+// the camera. Returns the first auth mode accepted by the device, or false if none of them were accepted.
+// The caller must have started the credential prober of the device.
+func (d *Driver) detectAuthMode(device models.Device, credential Credentials, failedMode string) (string, bool) {
+	delay, maxAttempts, lockout := d.credentialProbeLimits()
+
+	attempted := false
+	for _, mode := range detectableAuthModes(credential, failedMode) {
+		if !d.credentialProber.allowAttempt(device.Name, maxAttempts, lockout, time.Now()) {
+			d.lc.Debugf("Maximum credential probe attempts reached for device %s, the auth mode will be detected in the next lockout window.", device.Name)
+			return "", false
+		}
+
+		// rate limit the attempts against the same camera
+		if attempted {
+			select {
+			case <-d.taskCh:
+				return "", false
+			case <-time.After(delay):
+			}
+		}
+		attempted = true
+
+		credential.AuthMode = mode
+		if d.tryCredentials(device, credential) {
+			d.lc.Infof("Detected auth mode %s for device %s.", mode, device.Name)
+			return mode, true
+		}
+		d.credentialProber.recordFailure(device.Name, time.Now())
+	}
+	d.lc.Debugf("Unable to detect the auth mode of device %s, none of the auth modes were accepted.", device.Name)
+	return "", false
+}
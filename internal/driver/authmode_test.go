@@ -0,0 +1,217 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	sdkMocks "github.com/edgexfoundry/device-sdk-go/v2/pkg/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAuthMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		authMode string
+		detected string
+		expected string
+	}{
+		{
+			name:     "explicit auth mode",
+			authMode: AuthModeDigest,
+			detected: AuthModeUsernameToken,
+			expected: AuthModeDigest,
+		},
+		{
+			name:     "auto without detected auth mode",
+			authMode: AuthModeAuto,
+			expected: AuthModeUsernameToken,
+		},
+		{
+			name:     "auto with detected auth mode",
+			authMode: AuthModeAuto,
+			detected: AuthModeBoth,
+			expected: AuthModeBoth,
+		},
+		{
+			name:     "auto with invalid detected auth mode",
+			authMode: AuthModeAuto,
+			detected: "invalid",
+			expected: AuthModeUsernameToken,
+		},
+	}
+
+	driver, _ := createDriverWithMockService()
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			device := models.Device{Protocols: map[string]models.ProtocolProperties{
+				OnvifProtocol: {DetectedAuthMode: test.detected},
+			}}
+			credential := driver.resolveAuthMode(device, Credentials{Username: "user", AuthMode: test.authMode})
+			assert.Equal(t, test.expected, credential.AuthMode)
+			assert.Equal(t, "user", credential.Username)
+		})
+	}
+}
+
+func TestDetectAuthMode(t *testing.T) {
+	camera := newTestCamera(t)
+	defer camera.server.Close()
+	device := camera.device(t, "cam1")
+
+	driver, _ := createTestCameraDriver(t, AuthModeAuto, []models.Device{device})
+	credential := Credentials{Username: testRotationUser, Password: testRotationPassword, AuthMode: AuthModeAuto}
+
+	mode, found := driver.detectAuthMode(device, credential, "")
+	require.True(t, found)
+	assert.Equal(t, AuthModeUsernameToken, mode)
+
+	// the auth mode which already failed is not tried again, and the camera also accepts both auth modes
+	mode, found = driver.detectAuthMode(device, credential, AuthModeUsernameToken)
+	require.True(t, found)
+	assert.Equal(t, AuthModeBoth, mode)
+
+	credential.Password = "wrong"
+	_, found = driver.detectAuthMode(device, credential, "")
+	assert.False(t, found)
+}
+
+func TestDetectableAuthModes(t *testing.T) {
+	tests := []struct {
+		name       string
+		username   string
+		failedMode string
+		expected   []string
+	}{
+		{
+			name:     "with username",
+			username: "user",
+			expected: []string{AuthModeUsernameToken, AuthModeDigest, AuthModeBoth},
+		},
+		{
+			name:       "with username and failed auth mode",
+			username:   "user",
+			failedMode: AuthModeUsernameToken,
+			expected:   []string{AuthModeDigest, AuthModeBoth},
+		},
+		{
+			name:     "without username",
+			expected: []string{AuthModeUsernameToken, AuthModeDigest, AuthModeBoth, AuthModeNone},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			modes := detectableAuthModes(Credentials{Username: test.username, AuthMode: AuthModeAuto}, test.failedMode)
+			assert.Equal(t, test.expected, modes)
+		})
+	}
+}
+
+func TestDetectAuthMode_lockout(t *testing.T) {
+	camera := newTestCamera(t)
+	defer camera.server.Close()
+	device := camera.device(t, "cam1")
+
+	driver, _ := createTestCameraDriver(t, AuthModeAuto, []models.Device{device})
+	driver.config.AppCustom.CredentialProbeMaxAttempts = 2
+	driver.config.AppCustom.CredentialProbeLockoutSeconds = 1800
+	credential := Credentials{Username: testRotationUser, Password: "wrong", AuthMode: AuthModeAuto}
+
+	failedAttempts := func() int {
+		driver.credentialProber.mu.Lock()
+		defer driver.credentialProber.mu.Unlock()
+		return driver.credentialProber.states[device.Name].failedAttempts
+	}
+
+	_, found := driver.detectAuthMode(device, credential, "")
+	assert.False(t, found)
+	assert.Equal(t, 2, failedAttempts())
+
+	// no more attempts are made until the lockout window has elapsed, even with the right credentials
+	credential.Password = testRotationPassword
+	_, found = driver.detectAuthMode(device, credential, "")
+	assert.False(t, found)
+	assert.Equal(t, 2, failedAttempts())
+}
+
+func TestTestConnectionMethods_detectAuthMode(t *testing.T) {
+	camera := newTestCamera(t)
+	defer camera.server.Close()
+
+	tests := []struct {
+		name           string
+		detected       string
+		probing        bool
+		expectedStatus string
+		expectedStored string
+	}{
+		{
+			name:           "not detected yet",
+			expectedStatus: UpWithAuth,
+		},
+		{
+			name:           "detected auth mode no longer accepted",
+			detected:       AuthModeDigest,
+			expectedStatus: UpWithoutAuth,
+			expectedStored: AuthModeUsernameToken,
+		},
+		{
+			name:           "credentials being probed",
+			detected:       AuthModeDigest,
+			probing:        true,
+			expectedStatus: UpWithoutAuth,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			device := camera.device(t, "cam1")
+			device.Protocols[OnvifProtocol][DetectedAuthMode] = test.detected
+
+			driver, _ := createTestCameraDriver(t, AuthModeAuto, []models.Device{device})
+			stored := make(chan string, 1)
+			driver.sdkService.(*sdkMocks.DeviceServiceSDK).On("UpdateDevice", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				stored <- args.Get(0).(models.Device).Protocols[OnvifProtocol][DetectedAuthMode]
+			})
+			if test.probing {
+				require.True(t, driver.credentialProber.start(device.Name))
+			}
+
+			status, properties := driver.testConnectionMethods(device)
+			assert.Equal(t, test.expectedStatus, status)
+			if test.expectedStatus == UpWithAuth {
+				assert.Equal(t, AuthModeUsernameToken, properties[DetectedAuthMode])
+				return
+			}
+
+			health, found := driver.healthTracker.Get(device.Name)
+			require.True(t, found)
+			if test.probing {
+				// the skipped detection is recorded in the health details
+				assert.Contains(t, health.LastError, "already being probed")
+				assert.Empty(t, stored)
+				return
+			}
+
+			// the auth mode is detected in the background
+			select {
+			case mode := <-stored:
+				assert.Equal(t, test.expectedStored, mode)
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "the auth mode was not detected")
+			}
+		})
+	}
+}
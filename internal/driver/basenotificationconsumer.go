@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/edgexfoundry/go-mod-core-contracts/v2/clients/logger"
@@ -68,7 +69,7 @@ func (consumer *Consumer) StartRenewLoop() {
 				return
 			}
 
-			servResp, err := consumer.onvifClient.onvifDevice.SendSoap(consumer.SubscriptionAddress, string(renewRequestData))
+			servResp, err := consumer.onvifClient.getOnvifDevice().SendSoap(consumer.SubscriptionAddress, string(renewRequestData))
 			if err != nil {
 				consumer.lc.Warnf("Failed to send the renew request from '%s' for resource '%s', %v. The pull point expired or dropped, try to create a new one.", consumer.SubscriptionAddress, consumer.Name, err)
 				err = consumer.subscribe()
@@ -153,6 +154,10 @@ func (consumer *Consumer) subscribeRequest() *event.Subscribe {
 
 	address := fmt.Sprintf("%s%s/%s/%s/%s",
 		baseNotificationURL, common.ApiBase, OnvifEventRestPath, consumer.onvifClient.DeviceName, consumer.onvifClient.CameraEventResource.Name)
+	if consumer.subscriptionRequest.SnapshotOnEvent != nil && *consumer.subscriptionRequest.SnapshotOnEvent {
+		// the notification handler looks up the snapshot settings of the subscription
+		address = fmt.Sprintf("%s?%s=%s", address, subscriptionQueryParam, url.QueryEscape(consumer.Name))
+	}
 	consumerReference := &event.EndpointReferenceType{
 		Address: event.AttributedURIType(address),
 	}
@@ -68,6 +68,14 @@ func (manager *BaseNotificationManager) addConsumer(consumer *Consumer) {
 	manager.consumers[consumer.Name] = consumer
 }
 
+// consumer returns the consumer of the resource
+func (manager *BaseNotificationManager) consumer(resourceName string) (*Consumer, bool) {
+	manager.lock.RLock()
+	defer manager.lock.RUnlock()
+	consumer, ok := manager.consumers[resourceName]
+	return consumer, ok
+}
+
 func (manager *BaseNotificationManager) removeConsumer(consumer *Consumer) {
 	manager.lock.Lock()
 	defer manager.lock.Unlock()
@@ -27,22 +27,32 @@ import (
 
 const (
 	OnvifEventRestPath = "onvifevent"
-	apiResourceRoute   = common.ApiBase + "/" + OnvifEventRestPath + "/{" + common.DeviceName + "}/{" + common.ResourceName + "}"
+	// subscriptionQueryParam is the query parameter of the notification url which holds the subscription name
+	subscriptionQueryParam = "subscription"
+	apiResourceRoute       = common.ApiBase + "/" + OnvifEventRestPath + "/{" + common.DeviceName + "}/{" + common.ResourceName + "}"
 )
 
+// cameraEventPublisher publishes the notification of a subscription, along with the snapshot configured for the
+// subscription
+type cameraEventPublisher interface {
+	publishBaseNotification(deviceName string, subscriptionName string, cv *models.CommandValue) bool
+}
+
 // RestNotificationHandler handle the notification from the camera and send to async value channel
 type RestNotificationHandler struct {
 	sdkService  interfaces.DeviceServiceSDK
 	lc          logger.LoggingClient
 	asyncValues chan<- *models.AsyncValues
+	publisher   cameraEventPublisher
 }
 
 // NewRestNotificationHandler create a new RestNotificationHandler entity
-func NewRestNotificationHandler(service interfaces.DeviceServiceSDK, logger logger.LoggingClient, asyncValues chan<- *models.AsyncValues) *RestNotificationHandler {
+func NewRestNotificationHandler(service interfaces.DeviceServiceSDK, logger logger.LoggingClient, asyncValues chan<- *models.AsyncValues, publisher cameraEventPublisher) *RestNotificationHandler {
 	handler := RestNotificationHandler{
 		sdkService:  service,
 		lc:          logger,
 		asyncValues: asyncValues,
+		publisher:   publisher,
 	}
 
 	return &handler
@@ -102,13 +112,16 @@ func (handler RestNotificationHandler) processAsyncRequest(writer http.ResponseW
 		http.Error(writer, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	handler.lc.Debugf("Incoming reading received: Device=%s Resource=%s", deviceName, resourceName)
+
+	subscriptionName := request.URL.Query().Get(subscriptionQueryParam)
+	if subscriptionName != "" && handler.publisher != nil && handler.publisher.publishBaseNotification(deviceName, subscriptionName, cv) {
+		return
+	}
 	asyncValues := &models.AsyncValues{
 		DeviceName:    deviceName,
 		CommandValues: []*models.CommandValue{cv},
 	}
-
-	handler.lc.Debugf("Incoming reading received: Device=%s Resource=%s", deviceName, resourceName)
-
 	handler.asyncValues <- asyncValues
 }
 
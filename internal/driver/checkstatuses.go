@@ -7,7 +7,9 @@
 package driver
 
 import (
+	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -39,8 +41,11 @@ func (d *Driver) checkStatuses() {
 				}
 			}
 
-			status := d.testConnectionMethods(device)
-			if statusChanged, updateDeviceStatusErr := d.updateDeviceStatus(device.Name, status); updateDeviceStatusErr != nil {
+			status, properties := d.testConnectionMethods(device)
+			if (status == UpWithAuth || status == UpWithoutAuth) && d.circuitBreaker.halfOpen(device.Name) {
+				d.lc.Infof("Device %s is reachable again, the circuit breaker is now %s.", device.Name, CircuitHalfOpen)
+			}
+			if statusChanged, updateDeviceStatusErr := d.updateDeviceStatus(device.Name, status, properties); updateDeviceStatusErr != nil {
 				d.lc.Warnf("Could not update device status for device %s: %s", device.Name, updateDeviceStatusErr.Error())
 
 			} else if statusChanged && status == UpWithAuth {
@@ -51,68 +56,169 @@ func (d *Driver) checkStatuses() {
 							device.Name, refreshErr.Error())
 					}
 				}()
+			} else if status == UpWithoutAuth && d.credentialProbingEnabled() {
+				// probe the credentials in the background, as the attempts are rate limited
+				go d.probeCredentials(device)
 			}
 		}()
 	}
 	wg.Wait()
 }
 
+// credentialProbingEnabled returns true if the credentials of UpWithoutAuth devices should be probed
+func (d *Driver) credentialProbingEnabled() bool {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.config.AppCustom.EnableCredentialProbing
+}
+
 // testConnectionMethods will try to determine the state using different device calls
 // and return the most accurate status
 // Higher degrees of connection are tested first, because if they
 // succeed, the lower levels of connection will too
-func (d *Driver) testConnectionMethods(device models.Device) (status string) {
+// The outcome and latency of each method is recorded in the health details of the device.
+// Any additional Onvif protocol properties determined while testing the connection are returned as well, with an
+// empty value for the ones which could not be determined.
+func (d *Driver) testConnectionMethods(device models.Device) (status string, properties map[string]string) {
+	// the properties measured by the status check are removed from the device when they are not measured, so the
+	// device does not keep the result of an earlier check
+	properties = map[string]string{
+		ClockDrift:          "",
+		ClockDriftExceeded:  "",
+		StreamHealthy:       "",
+		StreamLatencyMillis: "",
+	}
+
+	health := DeviceHealth{
+		DeviceName: device.Name,
+		LastCheck:  time.Now(),
+		SecretPath: d.secretPathForDevice(device),
+	}
+	defer func() {
+		health.Status = status
+		d.healthTracker.RecordCheck(health)
+	}()
+
+	start := time.Now()
+	credential, edgexErr := d.tryGetCredentials(health.SecretPath)
+	health.addResult(methodCredentialLookup, start, edgexErr)
+	if edgexErr != nil {
+		// if credentials are not found, instead of returning an error, set the AuthMode to NoAuth
+		// and allow the unauthenticated endpoints to be tested
+		d.lc.Debugf("Failed to get credentials for device %s from secret path %s, testing without authentication", device.Name, health.SecretPath)
+		credential = noAuthCredentials
+	}
 
 	// sends get capabilities command to device (does not require credentials)
-	devClient, edgexErr := d.newTemporaryOnvifClient(device)
+	start = time.Now()
+	devClient, edgexErr := d.newTemporaryOnvifClientWithCredentials(device, credential)
+	health.addResult(methodGetCapabilities, start, edgexErr)
 	if edgexErr != nil {
 		d.lc.Debugf("Connection to %s failed when creating client: %s", device.Name, edgexErr.Message())
 		// onvif connection failed, so lets probe it
-		if d.tcpProbe(device) {
-			return Reachable
+		start = time.Now()
+		err := d.tcpProbe(device)
+		health.addResult(methodTCPProbe, start, err)
+		if err == nil {
+			return Reachable, properties
 		}
-		return Unreachable
 
+		// the onvif service is not listening, so check whether the network stack of the device is up
+		start = time.Now()
+		err = d.icmpProbe(device)
+		health.addResult(methodICMPProbe, start, err)
+		if err == nil {
+			return NetworkOnly, properties
+		}
+		start = time.Now()
+		err = d.arpLookup(device)
+		health.addResult(methodARPLookup, start, err)
+		if err == nil {
+			return NetworkOnly, properties
+		}
+		return Unreachable, properties
+
+	}
+
+	// sends get system date and time command to device (does not require credentials)
+	start = time.Now()
+	drift, edgexErr := devClient.getClockDrift()
+	health.addResult(methodGetSystemDateAndTime, start, edgexErr)
+	if edgexErr != nil {
+		d.lc.Debugf("Unable to determine the clock drift of device %s: %s", device.Name, edgexErr.Message())
+	} else {
+		d.configMu.RLock()
+		threshold := time.Duration(d.config.AppCustom.ClockDriftThresholdSeconds) * time.Second
+		d.configMu.RUnlock()
+
+		exceeded := clockDriftExceeded(drift, threshold)
+		if exceeded {
+			d.lc.Warnf("Clock of device %s has drifted by %v from the host, which exceeds the threshold of %v. UsernameToken authentication may fail.",
+				device.Name, drift.Round(time.Second), threshold)
+		}
+		properties[ClockDrift] = strconv.FormatInt(int64(drift.Round(time.Second)/time.Second), 10)
+		properties[ClockDriftExceeded] = strconv.FormatBool(exceeded)
 	}
 
 	// sends get device information command to device (requires credentials)
+	start = time.Now()
 	_, edgexErr = devClient.callOnvifFunction(onvif.DeviceWebService, onvif.GetDeviceInformation, []byte{})
+	health.addResult(methodGetDeviceInfo, start, edgexErr)
+	usedAuthMode := devClient.getOnvifDevice().GetDeviceParams().AuthMode
 	if edgexErr != nil {
 		d.lc.Debugf("%s command failed for device %s when using authentication: %s", onvif.GetDeviceInformation, device.Name, edgexErr.Message())
-		return UpWithoutAuth
+		if credential.AuthMode != AuthModeAuto {
+			return UpWithoutAuth, properties
+		}
+
+		// the auth mode was either not detected yet, or is no longer accepted, so it is detected again in the
+		// background, and used by the next status check
+		start = time.Now()
+		if !d.startAuthModeDetection(device, credential, usedAuthMode) {
+			health.addResult(methodAuthModeDetection, start, fmt.Errorf("skipped, the credentials of the device are already being probed"))
+		}
+		return UpWithoutAuth, properties
 	}
 
-	return UpWithAuth
+	if credential.AuthMode == AuthModeAuto {
+		properties[DetectedAuthMode] = usedAuthMode
+	}
+	if d.streamHealthCheckEnabled() {
+		d.checkStreamHealth(devClient, &health, properties)
+	}
+	return UpWithAuth, properties
 }
 
 // tcpProbe attempts to make a connection to a specific ip and port list to determine
-// if there is a service listening at that ip+port.
-func (d *Driver) tcpProbe(device models.Device) bool {
+// if there is a service listening at that ip+port. Returns nil if the connection succeeded.
+func (d *Driver) tcpProbe(device models.Device) error {
 	proto, ok := device.Protocols[OnvifProtocol]
 	if !ok {
 		d.lc.Warnf("Device %s is missing required %s protocol info, cannot send probe.", device.Name, OnvifProtocol)
-		return false
+		return fmt.Errorf("device is missing required %s protocol info", OnvifProtocol)
 	}
 	addr := proto[Address]
 	port := proto[Port]
 
 	if addr == "" || port == "" {
 		d.lc.Warnf("Device %s has no network address, cannot send probe.", device.Name)
-		return false
+		return fmt.Errorf("device has no network address")
 	}
 	host := addr + ":" + port
 
 	conn, err := net.DialTimeout("tcp", host, time.Duration(d.config.AppCustom.ProbeTimeoutMillis)*time.Millisecond)
 	if err != nil {
 		d.lc.Debugf("Connection to %s failed when using simple tcp dial, Error: %s ", device.Name, err.Error())
-		return false
+		return err
 	}
 	defer conn.Close()
-	return true
+	return nil
 }
 
-// updateDeviceStatus updates the status of a device in the cache. Returns true if the status changed. Returns any errors that occur if failure.
-func (d *Driver) updateDeviceStatus(deviceName string, status string) (bool, error) {
+// updateDeviceStatus updates the status of a device in the cache, along with any additional Onvif protocol properties.
+// The properties with an empty value are removed from the device.
+// Returns true if the status changed. Returns any errors that occur if failure.
+func (d *Driver) updateDeviceStatus(deviceName string, status string, properties map[string]string) (bool, error) {
 	// todo: maybe have connection levels known as ints, so that way we can log at different levels based on
 	//       if the connection level went up or down
 	shouldUpdate := false
@@ -132,6 +238,23 @@ func (d *Driver) updateDeviceStatus(deviceName string, status string) (bool, err
 		device.Protocols[OnvifProtocol][DeviceStatus] = status
 		shouldUpdate = true
 		statusChanged = true
+		d.healthTracker.RecordTransition(device.Name, oldStatus, status)
+	}
+
+	for key, value := range properties {
+		current, found := device.Protocols[OnvifProtocol][key]
+		if value == "" {
+			// an empty value removes the property, which could not be determined
+			if found {
+				delete(device.Protocols[OnvifProtocol], key)
+				shouldUpdate = true
+			}
+			continue
+		}
+		if current != value {
+			device.Protocols[OnvifProtocol][key] = value
+			shouldUpdate = true
+		}
 	}
 
 	if status != Unreachable {
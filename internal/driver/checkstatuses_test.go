@@ -7,10 +7,12 @@
 package driver
 
 import (
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
-	"testing"
 )
 
 func TestUpdateDeviceStatus_update(t *testing.T) {
@@ -20,7 +22,7 @@ func TestUpdateDeviceStatus_update(t *testing.T) {
 	mockService.On("UpdateDevice", mock.AnythingOfType("models.Device")).
 		Return(nil).Once()
 
-	changed, err := driver.updateDeviceStatus(testDeviceName, UpWithAuth)
+	changed, err := driver.updateDeviceStatus(testDeviceName, UpWithAuth, nil)
 	mockService.AssertExpectations(t)
 	require.NoError(t, err)
 	assert.True(t, changed)
@@ -31,8 +33,28 @@ func TestUpdateDeviceStatus_noUpdate(t *testing.T) {
 	mockService.On("GetDeviceByName", testDeviceName).
 		Return(createTestDevice(), nil).Once()
 
-	changed, err := driver.updateDeviceStatus(testDeviceName, Unreachable)
+	changed, err := driver.updateDeviceStatus(testDeviceName, Unreachable, nil)
 	mockService.AssertExpectations(t)
 	require.NoError(t, err)
 	assert.False(t, changed)
 }
+
+func TestUpdateDeviceStatus_removeProperties(t *testing.T) {
+	driver, mockService := createDriverWithMockService()
+	device := createTestDevice()
+	device.Protocols[OnvifProtocol][DeviceStatus] = UpWithAuth
+	device.Protocols[OnvifProtocol][StreamHealthy] = "true"
+	device.Protocols[OnvifProtocol][StreamLatencyMillis] = "42"
+	mockService.On("GetDeviceByName", testDeviceName).Return(device, nil).Once()
+	mockService.On("UpdateDevice", mock.AnythingOfType("models.Device")).Return(nil).Once()
+
+	changed, err := driver.updateDeviceStatus(testDeviceName, UpWithAuth, map[string]string{
+		StreamHealthy:       "false",
+		StreamLatencyMillis: "",
+	})
+	require.NoError(t, err)
+	assert.False(t, changed)
+	updated := mockService.Calls[1].Arguments.Get(0).(models.Device)
+	assert.Equal(t, "false", updated.Protocols[OnvifProtocol][StreamHealthy])
+	assert.NotContains(t, updated.Protocols[OnvifProtocol], StreamLatencyMillis)
+}
@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+)
+
+const (
+	// CircuitClosed indicates the requests are sent to the device
+	CircuitClosed = "closed"
+	// CircuitOpen indicates the requests to the device fail immediately, until the device is reachable again
+	CircuitOpen = "open"
+	// CircuitHalfOpen indicates the device was reachable again, and the next request decides whether the
+	// circuit is closed or opened again
+	CircuitHalfOpen = "half-open"
+)
+
+// circuitBreakerState keeps track of the circuit breaker of a single device
+type circuitBreakerState struct {
+	state               string
+	consecutiveFailures int
+	openedAt            time.Time
+}
+
+// CircuitBreaker keeps track of the consecutive transport failures of every device. Once a device reaches the
+// failure threshold, its circuit is opened and the requests to the device fail immediately instead of waiting
+// for the request timeout. The circuit is half-opened when the status check is able to reach the device again.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	states    map[string]*circuitBreakerState
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker which opens after the threshold of consecutive transport failures.
+// A threshold of 0 disables the circuit breaker.
+func NewCircuitBreaker(threshold int) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		states:    make(map[string]*circuitBreakerState),
+	}
+}
+
+// SetThreshold updates the amount of consecutive transport failures which opens the circuit of a device.
+// The circuits of all devices are closed when the circuit breaker is disabled.
+func (b *CircuitBreaker) SetThreshold(threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.threshold = threshold
+	if threshold <= 0 {
+		b.states = make(map[string]*circuitBreakerState)
+	}
+}
+
+// allow returns an error if the circuit of the device is open
+func (b *CircuitBreaker) allow(deviceName string) errors.EdgeX {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, found := b.states[deviceName]
+	if !found || state.state != CircuitOpen {
+		return nil
+	}
+	return errors.NewCommonEdgeX(errors.KindServiceUnavailable,
+		fmt.Sprintf("device %s is unavailable, the circuit breaker opened at %s after %d consecutive failures",
+			deviceName, state.openedAt.Format(time.RFC3339), state.consecutiveFailures), nil)
+}
+
+// recordSuccess closes the circuit of the device. Returns true if the circuit was not closed before.
+func (b *CircuitBreaker) recordSuccess(deviceName string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, found := b.states[deviceName]
+	if !found {
+		return false
+	}
+	delete(b.states, deviceName)
+	return state.state != CircuitClosed
+}
+
+// recordFailure records a transport failure of the device. Returns true if the circuit was opened.
+func (b *CircuitBreaker) recordFailure(deviceName string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return false
+	}
+	state, found := b.states[deviceName]
+	if !found {
+		state = &circuitBreakerState{state: CircuitClosed}
+		b.states[deviceName] = state
+	}
+	state.consecutiveFailures++
+	// the device failed again after it was reachable, so there is no need to wait for the threshold
+	if state.state == CircuitHalfOpen || (state.state == CircuitClosed && state.consecutiveFailures >= b.threshold) {
+		state.state = CircuitOpen
+		state.openedAt = now
+		return true
+	}
+	return false
+}
+
+// halfOpen half-opens the circuit of the device, so the next request is sent to the device.
+// Returns true if the circuit was open.
+func (b *CircuitBreaker) halfOpen(deviceName string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, found := b.states[deviceName]
+	if !found || state.state != CircuitOpen {
+		return false
+	}
+	state.state = CircuitHalfOpen
+	return true
+}
+
+// State returns the state of the circuit of the device
+func (b *CircuitBreaker) State(deviceName string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if state, found := b.states[deviceName]; found {
+		return state.state
+	}
+	return CircuitClosed
+}
+
+// Remove deletes the circuit breaker state of the device
+func (b *CircuitBreaker) Remove(deviceName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.states, deviceName)
+}
+
+// circuitBreaker returns the circuit breaker of the client, or nil for the temporary clients, as they are used by
+// the status check to find out whether the device is reachable again
+func (onvifClient *OnvifClient) circuitBreaker() *CircuitBreaker {
+	if onvifClient.driver == nil {
+		return nil
+	}
+	return onvifClient.driver.circuitBreaker
+}
+
+// recordTransportResult records the result of sending a request to the device in the circuit breaker
+func (onvifClient *OnvifClient) recordTransportResult(err error) {
+	breaker := onvifClient.circuitBreaker()
+	if breaker == nil {
+		return
+	}
+	if err == nil {
+		if breaker.recordSuccess(onvifClient.DeviceName) {
+			onvifClient.lc.Infof("Device %s is responding again, the circuit breaker is now %s.", onvifClient.DeviceName, CircuitClosed)
+		}
+		return
+	}
+	if breaker.recordFailure(onvifClient.DeviceName, time.Now()) {
+		onvifClient.lc.Warnf("Device %s is failing to respond, the circuit breaker is now %s. Requests will fail immediately until the device is reachable again.",
+			onvifClient.DeviceName, CircuitOpen)
+	}
+}
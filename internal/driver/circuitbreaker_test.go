@@ -0,0 +1,92 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := NewCircuitBreaker(3)
+	now := time.Now()
+
+	assert.False(t, breaker.recordFailure(testDeviceName, now))
+	assert.False(t, breaker.recordFailure(testDeviceName, now))
+	// a success resets the consecutive failures
+	assert.False(t, breaker.recordSuccess(testDeviceName))
+	assert.False(t, breaker.recordFailure(testDeviceName, now))
+	assert.False(t, breaker.recordFailure(testDeviceName, now))
+	assert.Equal(t, CircuitClosed, breaker.State(testDeviceName))
+	assert.NoError(t, breaker.allow(testDeviceName))
+
+	assert.True(t, breaker.recordFailure(testDeviceName, now))
+	assert.Equal(t, CircuitOpen, breaker.State(testDeviceName))
+	err := breaker.allow(testDeviceName)
+	require.Error(t, err)
+	assert.Equal(t, errors.KindServiceUnavailable, errors.Kind(err))
+	assert.NoError(t, breaker.allow("other"))
+
+	// a half-open circuit opens again after a single failure
+	assert.True(t, breaker.halfOpen(testDeviceName))
+	assert.False(t, breaker.halfOpen(testDeviceName))
+	assert.NoError(t, breaker.allow(testDeviceName))
+	assert.True(t, breaker.recordFailure(testDeviceName, now))
+	assert.Equal(t, CircuitOpen, breaker.State(testDeviceName))
+
+	assert.True(t, breaker.halfOpen(testDeviceName))
+	assert.True(t, breaker.recordSuccess(testDeviceName))
+	assert.Equal(t, CircuitClosed, breaker.State(testDeviceName))
+
+	// disabling the circuit breaker closes every circuit
+	breaker.recordFailure(testDeviceName, now)
+	breaker.recordFailure(testDeviceName, now)
+	breaker.recordFailure(testDeviceName, now)
+	breaker.SetThreshold(0)
+	assert.Equal(t, CircuitClosed, breaker.State(testDeviceName))
+	assert.False(t, breaker.recordFailure(testDeviceName, now))
+	assert.NoError(t, breaker.allow(testDeviceName))
+}
+
+func TestOnvifClient_callOnvifFunction_circuitBreaker(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	driver.circuitBreaker.SetThreshold(2)
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	mockDevice.On("GetEndpointByRequestStruct", mock.Anything).Return("http://camera/onvif/device_service", nil)
+	mockDevice.On("SendSoap", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("i/o timeout")).Twice()
+
+	for i := 0; i < 2; i++ {
+		_, err := onvifClient.callOnvifFunction(onvif.DeviceWebService, onvif.GetDeviceInformation, nil)
+		require.Error(t, err)
+		assert.Equal(t, errors.KindServerError, errors.Kind(err))
+	}
+
+	// the requests fail immediately while the circuit is open
+	_, err := onvifClient.callOnvifFunction(onvif.DeviceWebService, onvif.GetDeviceInformation, nil)
+	require.Error(t, err)
+	assert.Equal(t, errors.KindServiceUnavailable, errors.Kind(err))
+	mockDevice.AssertNumberOfCalls(t, "SendSoap", 2)
+
+	response := fmt.Sprintf(testRotationEnvelope, `<GetDeviceInformationResponse><Model>test</Model></GetDeviceInformationResponse>`)
+	mockDevice.On("SendSoap", mock.Anything, mock.Anything).
+		Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(response))}, nil).Once()
+
+	require.True(t, driver.circuitBreaker.halfOpen(testDeviceName))
+	_, err = onvifClient.callOnvifFunction(onvif.DeviceWebService, onvif.GetDeviceInformation, nil)
+	require.NoError(t, err)
+	assert.Equal(t, CircuitClosed, driver.circuitBreaker.State(testDeviceName))
+}
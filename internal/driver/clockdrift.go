@@ -0,0 +1,63 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+
+	"github.com/IOTechSystems/onvif"
+	onvifdevice "github.com/IOTechSystems/onvif/device"
+	"github.com/IOTechSystems/onvif/xsd"
+)
+
+// getClockDrift queries the camera's clock using the unauthenticated GetSystemDateAndTime function and returns
+// the difference between the camera's clock and the host's clock. A positive drift means the camera's clock is ahead.
+func (onvifClient *OnvifClient) getClockDrift() (time.Duration, errors.EdgeX) {
+	sent := time.Now()
+	respContent, edgexErr := onvifClient.callOnvifFunction(onvif.DeviceWebService, onvif.GetSystemDateAndTime, []byte{})
+	if edgexErr != nil {
+		return 0, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	received := time.Now()
+
+	resp, ok := respContent.(*onvifdevice.GetSystemDateAndTimeResponse)
+	if !ok {
+		return 0, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetSystemDateAndTimeResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+	}
+
+	cameraTime := utcDateTimeToTime(resp.SystemDateAndTime.UTCDateTime)
+	if cameraTime.Year() <= 1 {
+		return 0, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("camera %s did not report its UTC date and time", onvifClient.DeviceName), nil)
+	}
+
+	// compare against the midpoint of the request to account for the network latency
+	hostTime := sent.Add(received.Sub(sent) / 2)
+	return cameraTime.Sub(hostTime), nil
+}
+
+// utcDateTimeToTime converts the UTC date and time reported by the camera into a time.Time
+func utcDateTimeToTime(dateTime xsd.DateTime) time.Time {
+	return time.Date(
+		int(dateTime.Date.Year), time.Month(dateTime.Date.Month), int(dateTime.Date.Day),
+		int(dateTime.Time.Hour), int(dateTime.Time.Minute), int(dateTime.Time.Second),
+		0, time.UTC)
+}
+
+// clockDriftExceeded returns true if the absolute value of the drift is larger than the threshold.
+// A threshold of zero or less disables the check.
+func clockDriftExceeded(drift time.Duration, threshold time.Duration) bool {
+	if threshold <= 0 {
+		return false
+	}
+	if drift < 0 {
+		drift = -drift
+	}
+	return drift > threshold
+}
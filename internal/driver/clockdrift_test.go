@@ -0,0 +1,130 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnvifClient_getClockDrift(t *testing.T) {
+	cameraTime := time.Now().UTC().Add(-2 * time.Minute)
+	resp := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://www.w3.org/2003/05/soap-envelope">
+  <Header />
+  <Body>
+    <Content>
+      <SystemDateAndTime>
+        <DateTimeType>Manual</DateTimeType>
+        <DaylightSavings>false</DaylightSavings>
+        <UTCDateTime>
+          <Time><Hour>%d</Hour><Minute>%d</Minute><Second>%d</Second></Time>
+          <Date><Year>%d</Year><Month>%d</Month><Day>%d</Day></Date>
+        </UTCDateTime>
+      </SystemDateAndTime>
+    </Content>
+  </Body>
+</Envelope>`, cameraTime.Hour(), cameraTime.Minute(), cameraTime.Second(),
+		cameraTime.Year(), cameraTime.Month(), cameraTime.Day())
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, err := writer.Write([]byte(resp))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	driver, _ := createDriverWithMockService()
+	client, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	mockDevice.On("GetEndpointByRequestStruct", mock.Anything).Return(server.URL, nil)
+	sendSoap := mockDevice.On("SendSoap", mock.Anything, mock.Anything)
+	sendSoap.Run(func(args mock.Arguments) {
+		resp, err := http.Post(server.URL, "application/soap+xml; charset=utf-8", strings.NewReader(args.String(1)))
+		sendSoap.Return(resp, err)
+	})
+
+	drift, err := client.getClockDrift()
+	require.NoError(t, err)
+	assert.InDelta(t, -2*time.Minute, drift, float64(2*time.Second))
+}
+
+func TestClockDriftExceeded(t *testing.T) {
+	tests := []struct {
+		name      string
+		drift     time.Duration
+		threshold time.Duration
+		expected  bool
+	}{
+		{
+			name:      "within threshold",
+			drift:     10 * time.Second,
+			threshold: 30 * time.Second,
+			expected:  false,
+		},
+		{
+			name:      "ahead of host",
+			drift:     31 * time.Second,
+			threshold: 30 * time.Second,
+			expected:  true,
+		},
+		{
+			name:      "behind host",
+			drift:     -5 * time.Minute,
+			threshold: 30 * time.Second,
+			expected:  true,
+		},
+		{
+			name:      "check disabled",
+			drift:     time.Hour,
+			threshold: 0,
+			expected:  false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, clockDriftExceeded(test.drift, test.threshold))
+		})
+	}
+}
+
+func TestTestConnectionMethods_clockDrift(t *testing.T) {
+	camera := newTestCamera(t)
+	defer camera.server.Close()
+	camera.systemDateTime = time.Now().UTC().Add(-2 * time.Minute)
+	device := camera.device(t, "cam1")
+
+	driver, _ := createTestCameraDriver(t, AuthModeUsernameToken, []models.Device{device})
+	driver.config.AppCustom.ClockDriftThresholdSeconds = 30
+	_, properties := driver.testConnectionMethods(device)
+	assert.Equal(t, "true", properties[ClockDriftExceeded])
+	drift, err := strconv.Atoi(properties[ClockDrift])
+	require.NoError(t, err)
+	assert.InDelta(t, -120, drift, 2)
+
+	// the drift properties are cleared once the drift can no longer be measured
+	camera.mu.Lock()
+	camera.systemDateTime = time.Time{}
+	camera.mu.Unlock()
+	_, properties = driver.testConnectionMethods(device)
+	value, found := properties[ClockDrift]
+	assert.True(t, found)
+	assert.Equal(t, "", value)
+	value, found = properties[ClockDriftExceeded]
+	assert.True(t, found)
+	assert.Equal(t, "", value)
+}
@@ -16,6 +16,11 @@ import (
 type CustomConfig struct {
 	// RequestTimeout is the number of seconds to wait when making an Onvif request before timing out.
 	RequestTimeout int
+	// RequestRetries is the maximum amount of times a failed Onvif request of a Get function is retried.
+	RequestRetries int
+	// RequestRetryBackoffMillis is the number of milliseconds to wait before the first retry of a failed Onvif request.
+	// The wait time is doubled for every following retry.
+	RequestRetryBackoffMillis int
 	// DefaultSecretPath indicates the secret path to retrieve username and password from secret store.
 	DefaultSecretPath string
 	// DiscoveryEthernetInterface indicates the target EthernetInterface for multicast discovering.
@@ -38,12 +43,115 @@ type CustomConfig struct {
 	EnableStatusCheck bool
 	// CheckStatusInterval indicates the interval in seconds at which the device service will check device statuses
 	CheckStatusInterval int
+	// ClockDriftThresholdSeconds indicates the maximum clock drift in seconds between a camera and the host before
+	// the camera is flagged. A value of 0 disables the check.
+	ClockDriftThresholdSeconds int
+
+	// EnableStreamHealthCheck indicates if the status check should probe the RTSP stream of UpWithAuth cameras
+	EnableStreamHealthCheck bool
+	// StreamHealthTimeoutMillis indicates the maximum amount of milliseconds to wait for the RTSP requests of the stream
+	// health check
+	StreamHealthTimeoutMillis int
+
+	// EnableTimeSync indicates if the clocks of UpWithAuth cameras should be synchronized periodically
+	EnableTimeSync bool
+	// TimeSyncIntervalSeconds indicates the interval in seconds at which the device service will synchronize camera clocks
+	TimeSyncIntervalSeconds int
+	// TimeSyncThresholdSeconds indicates the minimum clock drift in seconds before a camera's clock is corrected
+	TimeSyncThresholdSeconds int
+	// TimeSyncMode indicates how the camera clocks are corrected. The value should be manual or ntp.
+	TimeSyncMode string
+	// TimeSyncNTPServer indicates the NTP server cameras are configured with when using the ntp TimeSyncMode
+	TimeSyncNTPServer string
+
+	// EnableCredentialProbing indicates if the credentials of every credential group should be tried against
+	// UpWithoutAuth devices, and the MAC address of the device assigned to the group whose credentials are accepted
+	EnableCredentialProbing bool
+	// CredentialProbeDelayMillis indicates the amount of milliseconds to wait between attempts against the same device
+	CredentialProbeDelayMillis int
+	// CredentialProbeMaxAttempts indicates the maximum amount of failed attempts against a device within the lockout window
+	CredentialProbeMaxAttempts int
+	// CredentialProbeLockoutSeconds indicates the length in seconds of the lockout window
+	CredentialProbeLockoutSeconds int
 
 	// ProvisionWatcherDir is the location of Provision Watchers
 	ProvisionWatcherDir string
 
 	// CredentialsMap is a map of SecretPath -> Comma separated list of mac addresses
 	CredentialsMap map[string]string
+	// EndpointRefCredentialsMap is a map of SecretPath -> Comma separated list of endpoint reference addresses
+	EndpointRefCredentialsMap map[string]string
+	// SerialNumberCredentialsMap is a map of SecretPath -> Comma separated list of serial numbers
+	SerialNumberCredentialsMap map[string]string
+	// IPCredentialsMap is a map of SecretPath -> Comma separated list of IP addresses or CIDR ranges
+	IPCredentialsMap map[string]string
+
+	// MaxConcurrentRequestsPerDevice indicates the maximum amount of requests sent to a device at the same time.
+	// A value of 0 means no limit.
+	MaxConcurrentRequestsPerDevice int
+	// CircuitBreakerFailureThreshold indicates the amount of consecutive transport failures after which the requests
+	// to a device fail immediately, until the status check is able to reach the device again. A value of 0 disables
+	// the circuit breaker.
+	CircuitBreakerFailureThreshold int
+
+	// HTTPMaxIdleConnsPerHost indicates the maximum amount of idle connections kept alive to each camera
+	HTTPMaxIdleConnsPerHost int
+	// HTTPMaxConnsPerHost indicates the maximum amount of connections to each camera. A value of 0 means no limit.
+	HTTPMaxConnsPerHost int
+	// HTTPIdleConnTimeoutSeconds indicates the amount of seconds an idle connection to a camera is kept alive
+	HTTPIdleConnTimeoutSeconds int
+	// HTTPDialTimeoutMillis indicates the maximum amount of milliseconds to wait when connecting to a camera
+	HTTPDialTimeoutMillis int
+	// HTTPTLSHandshakeTimeoutMillis indicates the maximum amount of milliseconds to wait for the TLS handshake with a camera
+	HTTPTLSHandshakeTimeoutMillis int
+	// HTTPProxyURL is the url of the http proxy used to connect to the cameras. When empty, the proxy is taken from
+	// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	HTTPProxyURL string
+
+	// EnableSnapshotSchedule indicates if the snapshots of the scheduled devices should be captured periodically
+	EnableSnapshotSchedule bool
+	// SnapshotScheduleLabels is a map of device label -> interval in seconds of the scheduled snapshots
+	SnapshotScheduleLabels map[string]int
+	// SnapshotScheduleParameters is the json object of the GetSnapshot parameters used for the scheduled snapshots
+	SnapshotScheduleParameters string
+	// SnapshotStorage indicates where the scheduled snapshots are stored. The value should be local or s3.
+	SnapshotStorage string
+	// SnapshotDirectory is the directory the scheduled snapshots are written to when using the local SnapshotStorage
+	SnapshotDirectory string
+	// SnapshotRetentionCount indicates the maximum amount of local snapshots kept per device. A value of 0 means no limit.
+	SnapshotRetentionCount int
+	// SnapshotRetentionHours indicates the maximum age in hours of the local snapshots. A value of 0 means no limit.
+	SnapshotRetentionHours int
+	// SnapshotS3Endpoint is the url of the S3-compatible object store used by the s3 SnapshotStorage
+	SnapshotS3Endpoint string
+	// SnapshotS3Bucket is the bucket the scheduled snapshots are uploaded to
+	SnapshotS3Bucket string
+	// SnapshotS3Region is the region of the bucket
+	SnapshotS3Region string
+	// SnapshotS3SecretPath is the secret path of the accessKey and secretKey of the object store
+	SnapshotS3SecretPath string
+
+	// DefaultTLSPolicy is the name of the TLS policy used by devices which are not assigned to any other TLS policy
+	DefaultTLSPolicy string
+	// TLSPolicies is a map of policy name -> TLS settings used when connecting to the cameras
+	TLSPolicies map[string]TLSPolicy
+}
+
+// TLSPolicy holds the TLS settings used when connecting to a camera
+type TLSPolicy struct {
+	// UseHTTPS indicates the camera should be connected to using https, even when its XAddr uses http
+	UseHTTPS bool
+	// CACertFile is the path of a PEM bundle of certificate authorities trusted in addition to the system roots
+	CACertFile string
+	// PinnedSHA256 is a comma separated list of hex encoded SHA-256 fingerprints of trusted camera certificates.
+	// When set, the certificate of the camera must match one of the fingerprints instead of being verified.
+	PinnedSHA256 string
+	// InsecureSkipVerify indicates the certificate of the camera should not be verified
+	InsecureSkipVerify bool
+	// ClientCertFile is the path of the PEM encoded client certificate presented to the camera
+	ClientCertFile string
+	// ClientKeyFile is the path of the PEM encoded private key of the client certificate
+	ClientKeyFile string
 }
 
 // ServiceConfig a struct that wraps CustomConfig which holds the values for driver configuration
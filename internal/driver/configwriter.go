@@ -0,0 +1,197 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/edgexfoundry/device-sdk-go/v2/pkg/interfaces"
+	bootstrapConfig "github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/config"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/environment"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/flags"
+	"github.com/edgexfoundry/go-mod-configuration/v2/configuration"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+)
+
+// configProviderArgRegex matches the -cp/--configProvider command-line argument, with its optional value
+var configProviderArgRegex = regexp.MustCompile(`^--?(?:cp|configProvider)(?:(=)(.*))?$`)
+
+const (
+	// credentialsMapConfigKey is the key of the CredentialsMap within the Configuration Provider,
+	// relative to the base path of the service
+	credentialsMapConfigKey = "AppCustom/CredentialsMap"
+)
+
+// ConfigWriter persists configuration values into the Configuration Provider
+type ConfigWriter interface {
+	// PutConfigurationValue puts a single configuration value, whose name is relative to the base path of the service
+	PutConfigurationValue(name string, value []byte) error
+}
+
+// newConfigProviderWriter creates a ConfigWriter for the Configuration Provider the service was started with.
+// Returns nil if the service is not using a Configuration Provider.
+//
+// Note: the Device SDK does not expose its configuration client, so the provider information is resolved
+// the same way the SDK does on startup, from the command-line arguments and the environment variables.
+func newConfigProviderWriter(sdkService interfaces.DeviceServiceSDK, lc logger.LoggingClient) (ConfigWriter, error) {
+	providerInfo, err := bootstrapConfig.NewProviderInfo(environment.NewVariables(lc), configProviderURL(os.Args[1:]))
+	if err != nil {
+		return nil, err
+	}
+	if !providerInfo.UseProvider() {
+		return nil, nil
+	}
+
+	serviceConfig := providerInfo.ServiceConfig()
+	serviceKey := sdkService.Name()
+	serviceConfig.BasePath = fmt.Sprintf("%s%s/%s", common.ConfigStemDevice, bootstrapConfig.ConfigVersion, serviceKey)
+	serviceConfig.GetAccessToken = func() (string, error) {
+		return sdkService.GetSecretProvider().GetAccessToken(serviceConfig.Type, serviceKey)
+	}
+	if serviceConfig.AccessToken, err = serviceConfig.GetAccessToken(); err != nil {
+		return nil, fmt.Errorf("failed to get Configuration Provider (%s) access token: %w", serviceConfig.Type, err)
+	}
+
+	return configuration.NewConfigurationClient(serviceConfig)
+}
+
+// configProviderURL returns the url of the -cp/--configProvider command-line argument, or the default url of the
+// Configuration Provider when the argument has no value, like the Device SDK does. The other arguments are ignored,
+// as parsing them with the flags of the SDK would exit the service on any flag they do not know about.
+func configProviderURL(args []string) string {
+	url := ""
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		match := configProviderArgRegex.FindStringSubmatch(arg)
+		if match == nil {
+			continue
+		}
+		url = match[2]
+		if match[1] == "" {
+			url = flags.DefaultConfigProvider
+		}
+	}
+	return url
+}
+
+// assignMACAddress moves the MAC address into the credential group of the secret path, removing it from any
+// other group. If the secret path is empty, the MAC address is only removed from its group.
+// The changes are applied to the in-memory configuration, and persisted to the Configuration Provider if one is in use.
+func (d *Driver) assignMACAddress(mac string, secretPath string) errors.EdgeX {
+	sanitized, err := SanitizeMACAddress(mac)
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid mac address '%s'", mac), err)
+	}
+
+	d.configMu.Lock()
+	credsMap, changed := reassignMACAddress(d.config.AppCustom.CredentialsMap, sanitized, secretPath)
+	d.config.AppCustom.CredentialsMap = credsMap
+	d.configMu.Unlock()
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	d.macAddressMapper.UpdateMappings(credsMap)
+	return d.persistCredentialGroups(changed)
+}
+
+// addCredentialGroup adds an empty credential group for the secret path to the CredentialsMap if it does not exist.
+// The changes are applied to the in-memory configuration, and persisted to the Configuration Provider if one is in use.
+func (d *Driver) addCredentialGroup(secretPath string) errors.EdgeX {
+	d.configMu.Lock()
+	if _, exists := d.config.AppCustom.CredentialsMap[secretPath]; exists {
+		d.configMu.Unlock()
+		return nil
+	}
+	credsMap := make(map[string]string, len(d.config.AppCustom.CredentialsMap)+1)
+	for group, macs := range d.config.AppCustom.CredentialsMap {
+		credsMap[group] = macs
+	}
+	credsMap[secretPath] = ""
+	d.config.AppCustom.CredentialsMap = credsMap
+	d.configMu.Unlock()
+
+	return d.persistCredentialGroups(map[string]string{secretPath: ""})
+}
+
+// persistCredentialGroups writes the values of the credential groups to the Configuration Provider if one is in use
+func (d *Driver) persistCredentialGroups(groups map[string]string) errors.EdgeX {
+	if d.configWriter == nil {
+		d.lc.Infof("Not using a Configuration Provider, the changes to the credential groups %v will not be persisted.", credentialGroups(groups))
+		return nil
+	}
+
+	for group, macs := range groups {
+		key := credentialsMapConfigKey + "/" + group
+		if err := d.configWriter.PutConfigurationValue(key, []byte(macs)); err != nil {
+			return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to persist the configuration value %s", key), err)
+		}
+	}
+
+	return nil
+}
+
+// reassignMACAddress returns a copy of the raw credentials map where the sanitized MAC address has been moved into
+// the group of the secret path, along with the new values of the groups which were changed.
+func reassignMACAddress(raw map[string]string, mac string, secretPath string) (map[string]string, map[string]string) {
+	credsMap := make(map[string]string, len(raw)+1)
+	changed := make(map[string]string)
+
+	for group, macs := range raw {
+		credsMap[group] = macs
+
+		var kept []string
+		found := false
+		for _, existing := range strings.Split(macs, ",") {
+			if strings.TrimSpace(existing) == "" {
+				continue
+			}
+			if sanitized, err := SanitizeMACAddress(existing); err == nil && sanitized == mac {
+				found = true
+				continue
+			}
+			kept = append(kept, existing)
+		}
+
+		if group == secretPath {
+			if !found {
+				kept = append(kept, mac)
+				credsMap[group] = strings.Join(kept, ",")
+				changed[group] = credsMap[group]
+			}
+		} else if found {
+			credsMap[group] = strings.Join(kept, ",")
+			changed[group] = credsMap[group]
+		}
+	}
+
+	if _, exists := raw[secretPath]; !exists && secretPath != "" {
+		credsMap[secretPath] = mac
+		changed[secretPath] = mac
+	}
+
+	return credsMap, changed
+}
+
+// credentialGroups returns the sorted secret paths of all credential groups
+func credentialGroups(raw map[string]string) []string {
+	groups := make([]string, 0, len(raw))
+	for group := range raw {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
@@ -0,0 +1,117 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReassignMACAddress(t *testing.T) {
+	tests := []struct {
+		name            string
+		raw             map[string]string
+		mac             string
+		secretPath      string
+		expectedMap     map[string]string
+		expectedChanged map[string]string
+	}{
+		{
+			name:            "add to existing group",
+			raw:             map[string]string{"creds1": "11:22:33:44:55:66", "NoAuth": ""},
+			mac:             "aa:bb:cc:dd:ee:ff",
+			secretPath:      "creds1",
+			expectedMap:     map[string]string{"creds1": "11:22:33:44:55:66,aa:bb:cc:dd:ee:ff", "NoAuth": ""},
+			expectedChanged: map[string]string{"creds1": "11:22:33:44:55:66,aa:bb:cc:dd:ee:ff"},
+		},
+		{
+			name:            "add to new group",
+			raw:             map[string]string{"NoAuth": ""},
+			mac:             "aa:bb:cc:dd:ee:ff",
+			secretPath:      "creds1",
+			expectedMap:     map[string]string{"creds1": "aa:bb:cc:dd:ee:ff", "NoAuth": ""},
+			expectedChanged: map[string]string{"creds1": "aa:bb:cc:dd:ee:ff"},
+		},
+		{
+			name:            "move between groups",
+			raw:             map[string]string{"creds1": "AA-BB-CC-DD-EE-FF,11:22:33:44:55:66", "creds2": ""},
+			mac:             "aa:bb:cc:dd:ee:ff",
+			secretPath:      "creds2",
+			expectedMap:     map[string]string{"creds1": "11:22:33:44:55:66", "creds2": "aa:bb:cc:dd:ee:ff"},
+			expectedChanged: map[string]string{"creds1": "11:22:33:44:55:66", "creds2": "aa:bb:cc:dd:ee:ff"},
+		},
+		{
+			name:            "already assigned",
+			raw:             map[string]string{"creds1": "aa:bb:cc:dd:ee:ff"},
+			mac:             "aa:bb:cc:dd:ee:ff",
+			secretPath:      "creds1",
+			expectedMap:     map[string]string{"creds1": "aa:bb:cc:dd:ee:ff"},
+			expectedChanged: map[string]string{},
+		},
+		{
+			name:            "unassign",
+			raw:             map[string]string{"creds1": "aa:bb:cc:dd:ee:ff,11:22:33:44:55:66"},
+			mac:             "aa:bb:cc:dd:ee:ff",
+			secretPath:      "",
+			expectedMap:     map[string]string{"creds1": "11:22:33:44:55:66"},
+			expectedChanged: map[string]string{"creds1": "11:22:33:44:55:66"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			credsMap, changed := reassignMACAddress(test.raw, test.mac, test.secretPath)
+			assert.Equal(t, test.expectedMap, credsMap)
+			assert.Equal(t, test.expectedChanged, changed)
+		})
+	}
+}
+
+func TestConfigProviderURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name: "no config provider",
+			args: []string{"-o", "--registry"},
+		},
+		{
+			name:     "config provider with url",
+			args:     []string{"--registry", "-cp=consul.http://edgex-core-consul:8500"},
+			expected: "consul.http://edgex-core-consul:8500",
+		},
+		{
+			name:     "config provider without url",
+			args:     []string{"--configProvider", "--registry"},
+			expected: "consul.http://localhost:8500",
+		},
+		{
+			name:     "unknown flags",
+			args:     []string{"--unknown", "value", "--configProvider=consul.http://consul:8500", "-x"},
+			expected: "consul.http://consul:8500",
+		},
+		{
+			name: "similar flag",
+			args: []string{"-cpu=2"},
+		},
+		{
+			name: "after the terminator",
+			args: []string{"--", "-cp"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, configProviderURL(test.args))
+		})
+	}
+}
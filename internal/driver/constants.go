@@ -11,6 +11,7 @@ const (
 	UpWithAuth    = "UpWithAuth"
 	UpWithoutAuth = "UpWithoutAuth"
 	Reachable     = "Reachable"
+	NetworkOnly   = "NetworkOnly"
 	Unreachable   = "Unreachable"
 )
 
@@ -28,6 +29,43 @@ const (
 	SetFriendlyName = "SetFriendlyName"
 	GetMACAddress   = "GetMACAddress"
 	SetMACAddress   = "SetMACAddress"
+	// SecretPath is the secret path of the device's credentials, which takes precedence over the credential mappings
+	SecretPath    = "SecretPath"
+	GetSecretPath = "GetSecretPath"
+	SetSecretPath = "SetSecretPath"
+	// RotatePassword generates a new password for the user of the device's credentials
+	RotatePassword = "RotatePassword"
+)
+
+const (
+	// DeviceRequestTimeout overrides the RequestTimeout of the device, in seconds
+	DeviceRequestTimeout = "RequestTimeout"
+	// DeviceRequestRetries overrides the RequestRetries of the device
+	DeviceRequestRetries = "RequestRetries"
+	// DeviceRequestRetryBackoffMillis overrides the RequestRetryBackoffMillis of the device
+	DeviceRequestRetryBackoffMillis = "RequestRetryBackoffMillis"
+	// DeviceMaxConcurrentRequests overrides the MaxConcurrentRequestsPerDevice of the device
+	DeviceMaxConcurrentRequests = "MaxConcurrentRequests"
+)
+
+const (
+	// SnapshotMediaType is the event tag holding the media type of the snapshot image
+	SnapshotMediaType = "SnapshotMediaType"
+	// SnapshotOriginalMediaType is the event tag holding the media type of the image sent by the camera
+	SnapshotOriginalMediaType = "SnapshotOriginalMediaType"
+	// SnapshotIntervalSeconds overrides the interval of the scheduled snapshots of the device, 0 disables them
+	SnapshotIntervalSeconds = "SnapshotIntervalSeconds"
+)
+
+const (
+	// TLSPolicyName is the name of the TLS policy used by the device, which takes precedence over the other TLS policies
+	TLSPolicyName = "TLSPolicy"
+	// UseHTTPS overrides the UseHTTPS setting of the device's TLS policy
+	UseHTTPS = "UseHTTPS"
+	// TLSPinnedSHA256 overrides the PinnedSHA256 setting of the device's TLS policy
+	TLSPinnedSHA256 = "TLSPinnedSHA256"
+	// TLSInsecureSkipVerify overrides the InsecureSkipVerify setting of the device's TLS policy
+	TLSInsecureSkipVerify = "TLSInsecureSkipVerify"
 )
 
 const (
@@ -37,9 +75,26 @@ const (
 	EndpointRefAddress = "EndpointRefAddress"
 	LastSeen           = "LastSeen"
 	DeviceStatus       = "DeviceStatus"
+	// ClockDrift is the number of seconds the camera's clock is ahead (positive) or behind (negative) the host's clock
+	ClockDrift = "ClockDrift"
+	// ClockDriftExceeded indicates the clock drift of the camera is larger than the ClockDriftThresholdSeconds
+	ClockDriftExceeded = "ClockDriftExceeded"
+	// StreamHealthy indicates the camera describes the video stream of its first media profile over RTSP, with the
+	// codec of the video encoder of the profile. Only set when the stream health check is enabled.
+	StreamHealthy = "StreamHealthy"
+	// StreamLatencyMillis is the amount of milliseconds taken by the RTSP requests of the latest stream health check
+	StreamLatencyMillis = "StreamLatencyMillis"
+	// DetectedAuthMode is the auth mode detected for the device when its credentials use the "auto" auth mode
+	DetectedAuthMode = "DetectedAuthMode"
+	// LastTimeSync is the time at which the camera's clock was last corrected by the device service
+	LastTimeSync = "LastTimeSync"
+	// LastTimeSyncCorrection is the clock drift in seconds which was corrected by the last time sync
+	LastTimeSyncCorrection = "LastTimeSyncCorrection"
 
 	// Maximum interval for checkStatus interval
 	maxStatusInterval = 300
+	// Maximum number of status transitions kept in the health details of each device
+	maxStatusHistory = 10
 
 	// Service is resource attribute and indicates the web service for the Onvif
 	Service = "service"
@@ -66,6 +121,10 @@ const (
 	DefaultMessageTimeout = "defaultMessageTimeout"
 	// DefaultMessageLimit specify the MessageLimit for PullMessage. Upper limit for the number of messages to return at once, For example, 10
 	DefaultMessageLimit = "defaultMessageLimit"
+	// DefaultSnapshotOnEvent indicates a snapshot of the camera is captured for every event. For example, true or false.
+	DefaultSnapshotOnEvent = "defaultSnapshotOnEvent"
+	// DefaultSnapshotParameters specify the GetSnapshot json parameters of the event snapshot. For example, {"MaxWidth":640}
+	DefaultSnapshotParameters = "defaultSnapshotParameters"
 	// DefaultConsumerURL point to the consumer's network location
 	DefaultConsumerURL = "defaultConsumerURL"
 
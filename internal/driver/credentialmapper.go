@@ -0,0 +1,190 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/edgexfoundry/device-sdk-go/v2/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+)
+
+const (
+	// endpointRefURNPrefix is the optional prefix of endpoint reference addresses
+	endpointRefURNPrefix = "urn:uuid:"
+)
+
+// ipCredentialsMapping maps a range of IP addresses to a secret path
+type ipCredentialsMapping struct {
+	ipNet      *net.IPNet
+	secretPath string
+}
+
+// CredentialsMapper maps devices to secret paths based on identifiers other than the MAC address,
+// which is useful for cameras which are behind NAT or do not report a MAC address.
+type CredentialsMapper struct {
+	// mu is for locking access to the mappings
+	mu sync.RWMutex
+	// endpointRefs is a map between normalized endpoint reference address and secretPath
+	endpointRefs map[string]string
+	// serialNumbers is a map between normalized serial number and secretPath
+	serialNumbers map[string]string
+	// ipRanges is a list of IP ranges and their secretPath
+	ipRanges []ipCredentialsMapping
+
+	sdkService interfaces.DeviceServiceSDK
+}
+
+// NewCredentialsMapper creates a new CredentialsMapper object
+func NewCredentialsMapper(sdkService interfaces.DeviceServiceSDK) *CredentialsMapper {
+	return &CredentialsMapper{
+		endpointRefs:  make(map[string]string),
+		serialNumbers: make(map[string]string),
+		sdkService:    sdkService,
+	}
+}
+
+// UpdateMappings takes the raw maps of secret path to csv list of endpoint reference addresses, serial numbers
+// and IP addresses or CIDR ranges, and inverts them into quick lookup maps.
+func (m *CredentialsMapper) UpdateMappings(endpointRefRaw, serialNumberRaw, ipRaw map[string]string) {
+	lc := m.sdkService.GetLoggingClient()
+
+	endpointRefs := m.invert(endpointRefRaw, normalizeEndpointRefAddress)
+	serialNumbers := m.invert(serialNumberRaw, normalizeSerialNumber)
+
+	var ipRanges []ipCredentialsMapping
+	for secretPath, values := range ipRaw {
+		m.checkSecretPath(secretPath)
+		for _, value := range splitCSV(values) {
+			ipNet, err := parseIPOrCIDR(value)
+			if err != nil {
+				lc.Warnf("Skipping invalid IP address or CIDR range %s: %s", value, err.Error())
+				continue
+			}
+			ipRanges = append(ipRanges, ipCredentialsMapping{ipNet: ipNet, secretPath: secretPath})
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endpointRefs = endpointRefs
+	m.serialNumbers = serialNumbers
+	m.ipRanges = ipRanges
+}
+
+// invert converts the raw map of secret path to csv list of identifiers into a map of identifier to secret path
+func (m *CredentialsMapper) invert(raw map[string]string, normalize func(string) string) map[string]string {
+	lc := m.sdkService.GetLoggingClient()
+
+	inverted := make(map[string]string)
+	for secretPath, values := range raw {
+		m.checkSecretPath(secretPath)
+		for _, value := range splitCSV(values) {
+			normalized := normalize(value)
+			// note: if the identifier already has a mapping, we do not overwrite it
+			if existing, found := inverted[normalized]; found {
+				lc.Warnf("Unable to set credential group to %s. '%s' already belongs to credential group %s.", secretPath, value, existing)
+			} else {
+				inverted[normalized] = secretPath
+			}
+		}
+	}
+	return inverted
+}
+
+// checkSecretPath logs a warning if the secret path does not exist in the Secret Store
+func (m *CredentialsMapper) checkSecretPath(secretPath string) {
+	if strings.ToLower(secretPath) == noAuthSecretPath { // do not check for noAuth
+		return
+	}
+	if _, err := m.sdkService.GetSecretProvider().GetSecret(secretPath, UsernameKey, PasswordKey, AuthModeKey); err != nil {
+		m.sdkService.GetLoggingClient().Warnf("One or more credential mappings exist for the secret path '%s' which does not exist in the Secret Store!", secretPath)
+	}
+}
+
+// TryGetSecretPathForDevice returns the secret path mapped to the device's endpoint reference address,
+// serial number or IP address, checked in that order. Returns false if no mapping exists.
+// When multiple IP ranges contain the device's IP address, the most specific range is used.
+func (m *CredentialsMapper) TryGetSecretPathForDevice(device models.Device) (string, bool) {
+	protocol := device.Protocols[OnvifProtocol]
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if endpointRef := protocol[EndpointRefAddress]; endpointRef != "" {
+		if secretPath, found := m.endpointRefs[normalizeEndpointRefAddress(endpointRef)]; found {
+			return secretPath, true
+		}
+	}
+
+	if serialNumber := protocol[SerialNumber]; serialNumber != "" {
+		if secretPath, found := m.serialNumbers[normalizeSerialNumber(serialNumber)]; found {
+			return secretPath, true
+		}
+	}
+
+	// note: host names are not resolved, only IP addresses are matched
+	if ip := net.ParseIP(protocol[Address]); ip != nil {
+		bestSize := -1
+		secretPath := ""
+		for _, mapping := range m.ipRanges {
+			if !mapping.ipNet.Contains(ip) {
+				continue
+			}
+			if size, _ := mapping.ipNet.Mask.Size(); size > bestSize {
+				bestSize = size
+				secretPath = mapping.secretPath
+			}
+		}
+		if bestSize >= 0 {
+			return secretPath, true
+		}
+	}
+
+	return "", false
+}
+
+// normalizeEndpointRefAddress returns the endpoint reference address in lower case without the urn:uuid: prefix
+func normalizeEndpointRefAddress(endpointRef string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(endpointRef)), endpointRefURNPrefix)
+}
+
+// normalizeSerialNumber returns the serial number in lower case
+func normalizeSerialNumber(serialNumber string) string {
+	return strings.ToLower(strings.TrimSpace(serialNumber))
+}
+
+// parseIPOrCIDR parses either a single IP address or a CIDR range. A single IP address is treated as a range
+// containing only that address.
+func parseIPOrCIDR(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, ipNet, err := net.ParseCIDR(value)
+		return ipNet, err
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address", Text: value}
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// splitCSV splits the comma separated values and removes any empty values
+func splitCSV(values string) []string {
+	result := []string{}
+	for _, value := range strings.Split(values, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,146 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCredentialsMapper_TryGetSecretPathForDevice(t *testing.T) {
+	driver, mockService := createDriverWithMockService()
+	mockSecretProvider := &mocks.SecretProvider{}
+	mockSecretProvider.On("GetSecret", mock.Anything, UsernameKey, PasswordKey, AuthModeKey).Return(nil, nil)
+	mockService.On("GetSecretProvider").Return(mockSecretProvider)
+	mockService.On("GetLoggingClient").Return(logger.NewMockClient())
+
+	driver.credentialsMapper.UpdateMappings(
+		map[string]string{"endpointCreds": "urn:uuid:3FA1FE68-B915-4053-A3E1-CC32E5000688"},
+		map[string]string{"serialCreds": "SN-1234, SN-5678"},
+		map[string]string{
+			"subnetCreds": "192.168.1.0/24,invalid",
+			"hostCreds":   "192.168.1.50",
+			"ipv6Creds":   "fd00::/64",
+		})
+
+	tests := []struct {
+		name       string
+		protocol   models.ProtocolProperties
+		secretPath string
+		found      bool
+	}{
+		{
+			name:       "endpoint ref without prefix",
+			protocol:   models.ProtocolProperties{EndpointRefAddress: "3fa1fe68-b915-4053-a3e1-cc32e5000688"},
+			secretPath: "endpointCreds",
+			found:      true,
+		},
+		{
+			name:       "serial number",
+			protocol:   models.ProtocolProperties{SerialNumber: "sn-5678", Address: "192.168.1.10"},
+			secretPath: "serialCreds",
+			found:      true,
+		},
+		{
+			name:       "cidr range",
+			protocol:   models.ProtocolProperties{Address: "192.168.1.10"},
+			secretPath: "subnetCreds",
+			found:      true,
+		},
+		{
+			name:       "most specific ip range",
+			protocol:   models.ProtocolProperties{Address: "192.168.1.50"},
+			secretPath: "hostCreds",
+			found:      true,
+		},
+		{
+			name:       "ipv6 range",
+			protocol:   models.ProtocolProperties{Address: "fd00::1234"},
+			secretPath: "ipv6Creds",
+			found:      true,
+		},
+		{
+			name:     "no match",
+			protocol: models.ProtocolProperties{Address: "10.0.0.1", SerialNumber: "other"},
+		},
+		{
+			name:     "host names are not resolved",
+			protocol: models.ProtocolProperties{Address: "localhost"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			device := createTestDeviceWithProtocols(map[string]models.ProtocolProperties{OnvifProtocol: test.protocol})
+			secretPath, found := driver.credentialsMapper.TryGetSecretPathForDevice(device)
+			assert.Equal(t, test.found, found)
+			assert.Equal(t, test.secretPath, secretPath)
+		})
+	}
+}
+
+func TestSecretPathForDevice(t *testing.T) {
+	driver, mockService := createDriverWithMockService()
+	driver.macAddressMapper = NewMACAddressMapper(mockService)
+	driver.macAddressMapper.credsMap = map[string]string{"aa:bb:cc:dd:ee:ff": "macCreds"}
+	driver.credentialsMapper.serialNumbers = map[string]string{"sn-1234": "serialCreds"}
+	driver.configMu = new(sync.RWMutex)
+	driver.config = &ServiceConfig{AppCustom: CustomConfig{DefaultSecretPath: "defaultCreds"}}
+	mockService.On("GetLoggingClient").Return(logger.NewMockClient())
+
+	tests := []struct {
+		name     string
+		protocol models.ProtocolProperties
+		expected string
+	}{
+		{
+			name:     "secret path override",
+			protocol: models.ProtocolProperties{SecretPath: "deviceCreds", MACAddress: "aa:bb:cc:dd:ee:ff", SerialNumber: "SN-1234"},
+			expected: "deviceCreds",
+		},
+		{
+			name:     "mac mapping before other mappings",
+			protocol: models.ProtocolProperties{MACAddress: "aa:bb:cc:dd:ee:ff", SerialNumber: "SN-1234"},
+			expected: "macCreds",
+		},
+		{
+			name:     "serial number mapping without mac",
+			protocol: models.ProtocolProperties{SerialNumber: "SN-1234"},
+			expected: "serialCreds",
+		},
+		{
+			name:     "serial number mapping with unmapped mac",
+			protocol: models.ProtocolProperties{MACAddress: "11:22:33:44:55:66", SerialNumber: "SN-1234"},
+			expected: "serialCreds",
+		},
+		{
+			name:     "default",
+			protocol: models.ProtocolProperties{MACAddress: "11:22:33:44:55:66"},
+			expected: "defaultCreds",
+		},
+		{
+			name:     "invalid mac",
+			protocol: models.ProtocolProperties{MACAddress: "invalid"},
+			expected: noAuthSecretPath,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			device := createTestDeviceWithProtocols(map[string]models.ProtocolProperties{OnvifProtocol: test.protocol})
+			assert.Equal(t, test.expected, driver.secretPathForDevice(device))
+		})
+	}
+}
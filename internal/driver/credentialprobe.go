@@ -0,0 +1,208 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+)
+
+const (
+	// defaultCredentialProbeMaxAttempts is used when the CredentialProbeMaxAttempts is not a positive value
+	defaultCredentialProbeMaxAttempts = 3
+)
+
+// credentialProbeState keeps track of the credential probing of a single device
+type credentialProbeState struct {
+	// running is true while a probe of the device is in progress
+	running bool
+	// failedAttempts is the amount of failed attempts since the windowStart
+	failedAttempts int
+	// windowStart is the time of the first failed attempt of the current lockout window
+	windowStart time.Time
+}
+
+// CredentialProber keeps track of the credential probing of every device, in order to rate limit the attempts
+// and avoid triggering the lockout protection of the cameras
+type CredentialProber struct {
+	mu     sync.Mutex
+	states map[string]*credentialProbeState
+}
+
+// NewCredentialProber creates a new CredentialProber
+func NewCredentialProber() *CredentialProber {
+	return &CredentialProber{
+		states: make(map[string]*credentialProbeState),
+	}
+}
+
+// start marks the probe of the device as running. Returns false if a probe of the device is already running.
+func (p *CredentialProber) start(deviceName string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state, found := p.states[deviceName]
+	if !found {
+		state = &credentialProbeState{}
+		p.states[deviceName] = state
+	}
+	if state.running {
+		return false
+	}
+	state.running = true
+	return true
+}
+
+// finish marks the probe of the device as no longer running
+func (p *CredentialProber) finish(deviceName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if state, found := p.states[deviceName]; found {
+		state.running = false
+	}
+}
+
+// allowAttempt returns true if another attempt may be made against the device. Once the device has reached the
+// maximum amount of failed attempts, no more attempts are allowed until the lockout window has elapsed.
+func (p *CredentialProber) allowAttempt(deviceName string, maxAttempts int, lockout time.Duration, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state, found := p.states[deviceName]
+	if !found || state.failedAttempts == 0 {
+		return true
+	}
+	if now.Sub(state.windowStart) >= lockout {
+		state.failedAttempts = 0
+		return true
+	}
+	return state.failedAttempts < maxAttempts
+}
+
+// recordFailure records a failed attempt against the device
+func (p *CredentialProber) recordFailure(deviceName string, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state, found := p.states[deviceName]
+	if !found {
+		state = &credentialProbeState{}
+		p.states[deviceName] = state
+	}
+	if state.failedAttempts == 0 {
+		state.windowStart = now
+	}
+	state.failedAttempts++
+}
+
+// Remove deletes the probe state of the device
+func (p *CredentialProber) Remove(deviceName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.states, deviceName)
+}
+
+// credentialProbeLimits returns the delay between the attempts against the same device, the maximum amount of
+// failed attempts and the lockout window, which limit the failed logins of the credential probing and the auth mode
+// detection
+func (d *Driver) credentialProbeLimits() (delay time.Duration, maxAttempts int, lockout time.Duration) {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+
+	delay = time.Duration(d.config.AppCustom.CredentialProbeDelayMillis) * time.Millisecond
+	maxAttempts = d.config.AppCustom.CredentialProbeMaxAttempts
+	lockout = time.Duration(d.config.AppCustom.CredentialProbeLockoutSeconds) * time.Second
+	if maxAttempts <= 0 {
+		maxAttempts = defaultCredentialProbeMaxAttempts
+	}
+	return delay, maxAttempts, lockout
+}
+
+// probeCredentials tries the credentials of every credential group against an UpWithoutAuth device, and
+// assigns the MAC address of the device to the first group whose credentials are accepted.
+// Returns the secret path which was assigned, or empty string if no credentials were accepted.
+func (d *Driver) probeCredentials(device models.Device) string {
+	mac := device.Protocols[OnvifProtocol][MACAddress]
+	if mac == "" {
+		d.lc.Debugf("Device %s is missing MAC Address, unable to probe credentials.", device.Name)
+		return ""
+	}
+
+	if device.Protocols[OnvifProtocol][SecretPath] != "" {
+		d.lc.Debugf("Device %s has a SecretPath set, skipping credential probing.", device.Name)
+		return ""
+	}
+
+	if !d.credentialProber.start(device.Name) {
+		return ""
+	}
+	defer d.credentialProber.finish(device.Name)
+
+	d.configMu.RLock()
+	groups := credentialGroups(d.config.AppCustom.CredentialsMap)
+	d.configMu.RUnlock()
+	delay, maxAttempts, lockout := d.credentialProbeLimits()
+
+	currentSecretPath := d.secretPathForDevice(device)
+	attempted := false
+	for _, secretPath := range groups {
+		// the current credentials have already been rejected by the device
+		if secretPath == currentSecretPath {
+			continue
+		}
+
+		credential, edgexErr := d.tryGetCredentials(secretPath)
+		if edgexErr != nil {
+			d.lc.Debugf("Skipping secret path %s while probing credentials for device %s: %s", secretPath, device.Name, edgexErr.Error())
+			continue
+		}
+
+		if !d.credentialProber.allowAttempt(device.Name, maxAttempts, lockout, time.Now()) {
+			d.lc.Debugf("Maximum credential probe attempts reached for device %s, will try again in the next lockout window.", device.Name)
+			return ""
+		}
+
+		// rate limit the attempts against the same camera
+		if attempted {
+			select {
+			case <-d.taskCh:
+				return ""
+			case <-time.After(delay):
+			}
+		}
+		attempted = true
+
+		if !d.tryCredentials(device, credential) {
+			d.credentialProber.recordFailure(device.Name, time.Now())
+			continue
+		}
+
+		d.lc.Infof("Credentials from secret path %s were accepted by device %s, assigning MAC address %s to the credential group.",
+			secretPath, device.Name, mac)
+		if edgexErr = d.assignMACAddress(mac, secretPath); edgexErr != nil {
+			d.lc.Errorf("Failed to assign MAC address %s to the credential group %s: %s", mac, secretPath, edgexErr.Error())
+		}
+		return secretPath
+	}
+
+	return ""
+}
+
+// tryCredentials returns true if the device accepts the credentials when calling GetDeviceInformation
+func (d *Driver) tryCredentials(device models.Device, credential Credentials) bool {
+	devClient, edgexErr := d.newTemporaryOnvifClientWithCredentials(device, credential)
+	if edgexErr != nil {
+		return false
+	}
+	_, edgexErr = devClient.callOnvifFunction(onvif.DeviceWebService, onvif.GetDeviceInformation, []byte{})
+	return edgexErr == nil
+}
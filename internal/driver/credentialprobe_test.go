@@ -0,0 +1,43 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialProber_start(t *testing.T) {
+	prober := NewCredentialProber()
+
+	assert.True(t, prober.start(testDeviceName))
+	assert.False(t, prober.start(testDeviceName), "only one probe of a device may run at a time")
+	prober.finish(testDeviceName)
+	assert.True(t, prober.start(testDeviceName))
+}
+
+func TestCredentialProber_allowAttempt(t *testing.T) {
+	prober := NewCredentialProber()
+	lockout := 30 * time.Minute
+	now := time.Now()
+
+	assert.True(t, prober.allowAttempt(testDeviceName, 2, lockout, now))
+	prober.recordFailure(testDeviceName, now)
+	assert.True(t, prober.allowAttempt(testDeviceName, 2, lockout, now))
+	prober.recordFailure(testDeviceName, now)
+	assert.False(t, prober.allowAttempt(testDeviceName, 2, lockout, now), "max attempts reached")
+	assert.False(t, prober.allowAttempt(testDeviceName, 2, lockout, now.Add(lockout-time.Second)), "still within the lockout window")
+	assert.True(t, prober.allowAttempt(testDeviceName, 2, lockout, now.Add(lockout)), "lockout window elapsed")
+	assert.True(t, prober.allowAttempt("other", 2, lockout, now), "attempts are tracked per device")
+
+	prober.recordFailure(testDeviceName, now)
+	prober.recordFailure(testDeviceName, now)
+	prober.Remove(testDeviceName)
+	assert.True(t, prober.allowAttempt(testDeviceName, 2, lockout, now))
+}
@@ -15,7 +15,7 @@ import (
 )
 
 // Credentials encapsulates username, password, and AuthMode attributes.
-// Assign AuthMode to "digest" | "usernametoken" | "both" | "none"
+// Assign AuthMode to "digest" | "usernametoken" | "both" | "none" | "auto"
 type Credentials struct {
 	Username string
 	Password string
@@ -27,6 +27,8 @@ const (
 	AuthModeUsernameToken string = onvif.UsernameTokenAuth
 	AuthModeBoth          string = onvif.Both
 	AuthModeNone          string = onvif.NoAuth
+	// AuthModeAuto detects the auth mode of each device, see detectAuthMode
+	AuthModeAuto string = "auto"
 )
 
 const (
@@ -51,7 +53,8 @@ func IsAuthModeValid(mode string) bool {
 	return mode == AuthModeDigest ||
 		mode == AuthModeUsernameToken ||
 		mode == AuthModeBoth ||
-		mode == AuthModeNone
+		mode == AuthModeNone ||
+		mode == AuthModeAuto
 }
 
 // tryGetCredentials will attempt one time to get the credentials located at secretPath from
@@ -73,7 +76,8 @@ func (d *Driver) tryGetCredentials(secretPath string) (Credentials, errors.EdgeX
 		AuthMode: secretData[AuthModeKey],
 	}
 
-	if !IsAuthModeValid(secretData[AuthModeKey]) {
+	// auto is opt-in only, so the credentials without a valid auth mode keep using usernametoken
+	if !IsAuthModeValid(credentials.AuthMode) {
 		d.lc.Warnf("AuthMode is set to an invalid value: %s. setting value to '%s'.", credentials.AuthMode, AuthModeUsernameToken)
 		credentials.AuthMode = AuthModeUsernameToken
 	}
@@ -81,21 +85,12 @@ func (d *Driver) tryGetCredentials(secretPath string) (Credentials, errors.EdgeX
 	return credentials, nil
 }
 
-// tryGetCredentialsForDevice will attempt to use the device's MAC address to look up the credentials
-// from the Secret Store. If a mapping does not exist, or the device's MAC address is missing or invalid,
-// the default secret path will be used to look up the credentials. An error is returned if the secret path
-// does not exist in the Secret Store.
+// tryGetCredentialsForDevice will attempt to use the device's SecretPath, MAC address, endpoint reference address,
+// serial number or IP address to look up the credentials from the Secret Store. If no mapping exists, the default
+// secret path will be used to look up the credentials. An error is returned if the secret path does not exist
+// in the Secret Store.
 func (d *Driver) tryGetCredentialsForDevice(device models.Device) (Credentials, errors.EdgeX) {
-	d.configMu.RLock()
-	defaultSecretPath := d.config.AppCustom.DefaultSecretPath
-	d.configMu.RUnlock()
-
-	secretPath := defaultSecretPath
-	if mac := device.Protocols[OnvifProtocol][MACAddress]; mac != "" {
-		secretPath = d.macAddressMapper.TryGetSecretPathForMACAddress(mac, defaultSecretPath)
-	} else {
-		d.lc.Warnf("Device %s is missing MAC Address, using default secret path", device.Name)
-	}
+	secretPath := d.secretPathForDevice(device)
 
 	credentials, edgexErr := d.tryGetCredentials(secretPath)
 	if edgexErr != nil {
@@ -107,3 +102,89 @@ func (d *Driver) tryGetCredentialsForDevice(device models.Device) (Credentials,
 
 	return credentials, nil
 }
+
+// secretPathForDevice returns the secret path which should be used to look up the credentials of the device.
+// The lookup order is:
+//  1. The SecretPath protocol property of the device
+//  2. The MAC address mapping from the CredentialsMap
+//  3. The endpoint reference address, serial number and IP address mappings
+//  4. The default secret path (or no auth if the device has an invalid MAC address)
+func (d *Driver) secretPathForDevice(device models.Device) string {
+	d.configMu.RLock()
+	defaultSecretPath := d.config.AppCustom.DefaultSecretPath
+	d.configMu.RUnlock()
+
+	if secretPath := strings.TrimSpace(device.Protocols[OnvifProtocol][SecretPath]); secretPath != "" {
+		return secretPath
+	}
+
+	mac := device.Protocols[OnvifProtocol][MACAddress]
+	if mac != "" {
+		if secretPath, found := d.macAddressMapper.LookupSecretPathForMACAddress(mac); found {
+			return secretPath
+		}
+	}
+
+	if secretPath, found := d.credentialsMapper.TryGetSecretPathForDevice(device); found {
+		return secretPath
+	}
+
+	if mac != "" {
+		return d.macAddressMapper.TryGetSecretPathForMACAddress(mac, defaultSecretPath)
+	}
+
+	d.lc.Warnf("Device %s is missing MAC Address, using default secret path", device.Name)
+	return defaultSecretPath
+}
+
+// registerSecretUpdatedCallbacks registers a secret updated callback for every secret path which is in use,
+// so the Onvif clients are refreshed when their secret is updated in the Secret Store
+func (d *Driver) registerSecretUpdatedCallbacks() {
+	d.configMu.RLock()
+	secretPaths := []string{d.config.AppCustom.DefaultSecretPath}
+	for _, credsMap := range []map[string]string{
+		d.config.AppCustom.CredentialsMap,
+		d.config.AppCustom.EndpointRefCredentialsMap,
+		d.config.AppCustom.SerialNumberCredentialsMap,
+		d.config.AppCustom.IPCredentialsMap,
+	} {
+		secretPaths = append(secretPaths, credentialGroups(credsMap)...)
+	}
+	d.configMu.RUnlock()
+
+	for _, secretPath := range d.deviceSecretPaths() {
+		secretPaths = append(secretPaths, secretPath)
+	}
+
+	for _, secretPath := range secretPaths {
+		d.registerSecretUpdatedCallback(secretPath)
+	}
+}
+
+// registerSecretUpdatedCallback registers a secret updated callback for the secret path, if one is not registered yet
+func (d *Driver) registerSecretUpdatedCallback(secretPath string) {
+	if secretPath == "" || strings.ToLower(secretPath) == noAuthSecretPath {
+		return
+	}
+
+	d.secretCallbacksMu.Lock()
+	defer d.secretCallbacksMu.Unlock()
+
+	if d.secretCallbacks == nil {
+		d.secretCallbacks = make(map[string]struct{})
+	}
+	if _, found := d.secretCallbacks[secretPath]; found {
+		return
+	}
+	if err := d.sdkService.GetSecretProvider().RegisteredSecretUpdatedCallback(secretPath, d.secretUpdated); err != nil {
+		d.lc.Warnf("Unable to register the secret updated callback for the secret path %s: %s", secretPath, err.Error())
+		return
+	}
+	d.secretCallbacks[secretPath] = struct{}{}
+}
+
+// secretUpdated is the secret updated callback, which refreshes the Onvif clients of the devices using the secret path
+func (d *Driver) secretUpdated(secretPath string) {
+	d.lc.Infof("Secret %s was updated, refreshing the onvif clients which use it.", secretPath)
+	d.refreshOnvifClients(d.devicesUsingSecretPath(secretPath))
+}
@@ -7,6 +7,9 @@
 package driver
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"sync"
 	"testing"
 
@@ -41,6 +44,10 @@ func TestIsAuthModeValid(t *testing.T) {
 			input:    onvif.NoAuth,
 			expected: true,
 		},
+		{
+			input:    AuthModeAuto,
+			expected: true,
+		},
 		{
 			input:    "invalidValue",
 			expected: false,
@@ -222,3 +229,104 @@ func TestTryGetCredentialsForDevice(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshOnvifClients(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	driver, mockService := createDriverWithMockService()
+	driver.macAddressMapper = NewMACAddressMapper(mockService)
+	driver.clientsMu = new(sync.RWMutex)
+	driver.configMu = new(sync.RWMutex)
+	driver.config = &ServiceConfig{
+		AppCustom: CustomConfig{
+			DefaultSecretPath: "NoAuth",
+			RequestTimeout:    1,
+		},
+	}
+
+	devices := []models.Device{
+		createTestDeviceWithProtocols(map[string]models.ProtocolProperties{
+			OnvifProtocol: {Address: serverURL.Hostname(), Port: serverURL.Port()},
+		}),
+		{Name: "other", Protocols: map[string]models.ProtocolProperties{
+			OnvifProtocol: {Address: serverURL.Hostname(), Port: serverURL.Port()},
+		}},
+	}
+	mockService.On("Devices").Return(devices)
+	mockService.On("GetDeviceByName", testDeviceName).Return(devices[0], nil)
+
+	client, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	client.pullPointManager = newPullPointManager(driver.lc)
+	otherClient, otherMockDevice := createOnvifClientWithMockDevice(driver, "other")
+	driver.onvifClients = map[string]*OnvifClient{
+		testDeviceName: client,
+		"other":        otherClient,
+	}
+
+	// only the device whose secret path changed is refreshed
+	driver.refreshChangedOnvifClients(map[string]string{
+		testDeviceName: "credentials001",
+		"other":        "NoAuth",
+	})
+
+	assert.Same(t, client, driver.onvifClients[testDeviceName], "the existing client should be kept")
+	assert.NotEqual(t, mockDevice, client.getOnvifDevice())
+	assert.Equal(t, AuthModeNone, client.getOnvifDevice().GetDeviceParams().AuthMode)
+	assert.Equal(t, otherMockDevice, otherClient.getOnvifDevice())
+}
+
+func TestDriver_UpdateDevice_keepsSubscriptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	driver, mockService := createDriverWithMockService()
+	driver.macAddressMapper = NewMACAddressMapper(mockService)
+	driver.clientsMu = new(sync.RWMutex)
+	driver.configMu = new(sync.RWMutex)
+	driver.config = &ServiceConfig{
+		AppCustom: CustomConfig{
+			DefaultSecretPath: "NoAuth",
+			RequestTimeout:    1,
+		},
+	}
+
+	eventResource := models.DeviceResource{Name: "CameraEvent", Attributes: map[string]interface{}{GetFunction: CameraEvent}}
+	device := createTestDeviceWithProtocols(map[string]models.ProtocolProperties{
+		OnvifProtocol: {Address: serverURL.Hostname(), Port: serverURL.Port(), SecretPath: "NoAuth"},
+	})
+	device.ProfileName = "onvif-camera"
+	mockService.On("GetDeviceByName", testDeviceName).Return(device, nil)
+	mockService.On("GetProfileByName", "onvif-camera").Return(models.DeviceProfile{
+		DeviceResources: []models.DeviceResource{eventResource},
+	}, nil)
+
+	client, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	client.CameraEventResource = eventResource
+	client.pullPointManager = newPullPointManager(driver.lc)
+	pullPointManager := client.pullPointManager
+	driver.onvifClients = map[string]*OnvifClient{testDeviceName: client}
+
+	// only the onvif device is replaced, so the pull point manager and its subscriptions are kept
+	err = driver.UpdateDevice(testDeviceName, device.Protocols, models.Unlocked)
+	require.NoError(t, err)
+	assert.Same(t, client, driver.onvifClients[testDeviceName], "the existing client should be kept")
+	assert.Same(t, pullPointManager, client.pullPointManager)
+	assert.NotEqual(t, mockDevice, client.getOnvifDevice())
+	assert.Equal(t, AuthModeNone, client.getOnvifDevice().GetDeviceParams().AuthMode)
+
+	// the client is recreated when the camera event resource of the device profile changed
+	client.CameraEventResource = models.DeviceResource{Name: "OtherEvent"}
+	err = driver.UpdateDevice(testDeviceName, device.Protocols, models.Unlocked)
+	require.NoError(t, err)
+	assert.NotSame(t, client, driver.onvifClients[testDeviceName])
+	assert.Equal(t, eventResource, driver.onvifClients[testDeviceName].CameraEventResource)
+}
@@ -0,0 +1,384 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	CredentialsRestPath = "credentials"
+	secretPathVar       = "secretPath"
+	macAddressVar       = "mac"
+
+	apiCredentialsRoute       = common.ApiBase + "/" + CredentialsRestPath
+	apiCredentialsMACRoute    = apiCredentialsRoute + "/{" + secretPathVar + "}/mac/{" + macAddressVar + "}"
+	apiCredentialsDeviceRoute = apiCredentialsRoute + "/{" + secretPathVar + "}/device/{" + common.DeviceName + "}"
+	apiCredentialsRotateRoute = apiCredentialsRoute + "/{" + secretPathVar + "}/rotate"
+)
+
+// CredentialGroup describes a credential group and its members. The credentials themselves are never returned.
+type CredentialGroup struct {
+	SecretPath string `json:"secretPath"`
+	// Default is true if this is the DefaultSecretPath
+	Default bool `json:"default,omitempty"`
+	// MACAddresses are the MAC addresses mapped to the group in the CredentialsMap
+	MACAddresses []string `json:"macAddresses"`
+	// Devices are the names of the devices currently using the credentials of the group
+	Devices []string `json:"devices"`
+}
+
+// CredentialGroupRequest is the body of the request used to create or update a credential group
+type CredentialGroupRequest struct {
+	SecretPath string `json:"secretPath"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	AuthMode   string `json:"authMode"`
+}
+
+// CredentialsRestHandler exposes the management of the credential groups
+type CredentialsRestHandler struct {
+	driver *Driver
+}
+
+// NewCredentialsRestHandler creates a new CredentialsRestHandler entity
+func NewCredentialsRestHandler(driver *Driver) *CredentialsRestHandler {
+	return &CredentialsRestHandler{
+		driver: driver,
+	}
+}
+
+// AddRoutes adds the routes for managing the credential groups and their members
+func (handler CredentialsRestHandler) AddRoutes() errors.EdgeX {
+	routes := []struct {
+		route   string
+		handler func(http.ResponseWriter, *http.Request)
+		method  string
+	}{
+		{apiCredentialsRoute, handler.getCredentialGroups, http.MethodGet},
+		{apiCredentialsRoute, handler.putCredentialGroup, http.MethodPost},
+		{apiCredentialsMACRoute, handler.assignMACAddress, http.MethodPut},
+		{apiCredentialsMACRoute, handler.unassignMACAddress, http.MethodDelete},
+		{apiCredentialsDeviceRoute, handler.assignDevice, http.MethodPut},
+		{apiCredentialsDeviceRoute, handler.unassignDevice, http.MethodDelete},
+		{apiCredentialsRotateRoute, handler.rotatePassword, http.MethodPost},
+	}
+
+	for _, r := range routes {
+		if err := handler.driver.sdkService.AddRoute(r.route, r.handler, r.method); err != nil {
+			return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("unable to add required route: %s: %s", r.route, err.Error()), err)
+		}
+		handler.driver.lc.Infof("Route %s %s added.", r.method, r.route)
+	}
+
+	return nil
+}
+
+// getCredentialGroups returns every credential group along with its MAC addresses and the devices using it
+func (handler CredentialsRestHandler) getCredentialGroups(writer http.ResponseWriter, _ *http.Request) {
+	d := handler.driver
+
+	d.configMu.RLock()
+	defaultSecretPath := d.config.AppCustom.DefaultSecretPath
+	groups := make(map[string]*CredentialGroup)
+	for secretPath, macs := range d.config.AppCustom.CredentialsMap {
+		groups[secretPath] = &CredentialGroup{SecretPath: secretPath, MACAddresses: splitCSV(macs), Devices: []string{}}
+	}
+	d.configMu.RUnlock()
+
+	if _, found := groups[defaultSecretPath]; !found {
+		groups[defaultSecretPath] = &CredentialGroup{SecretPath: defaultSecretPath, MACAddresses: []string{}, Devices: []string{}}
+	}
+	groups[defaultSecretPath].Default = true
+
+	for _, device := range d.sdkService.Devices() {
+		secretPath := d.secretPathForDevice(device)
+		group, found := groups[secretPath]
+		if !found {
+			// the device uses a secret path which is not part of the CredentialsMap, such as a SecretPath override
+			group = &CredentialGroup{SecretPath: secretPath, MACAddresses: []string{}, Devices: []string{}}
+			groups[secretPath] = group
+		}
+		group.Devices = append(group.Devices, device.Name)
+	}
+
+	result := make([]CredentialGroup, 0, len(groups))
+	for _, group := range groups {
+		sort.Strings(group.Devices)
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SecretPath < result[j].SecretPath
+	})
+
+	handler.writeJSON(writer, result)
+}
+
+// putCredentialGroup stores the credentials of a credential group in the Secret Store, and adds the group
+// to the CredentialsMap if it does not exist. The clients of the devices using the group are refreshed.
+func (handler CredentialsRestHandler) putCredentialGroup(writer http.ResponseWriter, request *http.Request) {
+	d := handler.driver
+
+	defer request.Body.Close()
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req CredentialGroupRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		http.Error(writer, fmt.Sprintf("Unable to parse the credential group: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if edgexErr := validateCredentialGroupRequest(req); edgexErr != nil {
+		http.Error(writer, edgexErr.Error(), edgexErr.Code())
+		return
+	}
+
+	err = d.sdkService.GetSecretProvider().StoreSecret(req.SecretPath, map[string]string{
+		UsernameKey: req.Username,
+		PasswordKey: req.Password,
+		AuthModeKey: req.AuthMode,
+	})
+	if err != nil {
+		d.lc.Errorf("Failed to store the credentials for the secret path %s: %s", req.SecretPath, err.Error())
+		http.Error(writer, fmt.Sprintf("Failed to store the credentials: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	if edgexErr := d.addCredentialGroup(req.SecretPath); edgexErr != nil {
+		http.Error(writer, edgexErr.Error(), edgexErr.Code())
+		return
+	}
+
+	d.registerSecretUpdatedCallback(req.SecretPath)
+	d.refreshOnvifClients(d.devicesUsingSecretPath(req.SecretPath))
+	writer.WriteHeader(http.StatusCreated)
+}
+
+// assignMACAddress moves the MAC address into the credential group
+func (handler CredentialsRestHandler) assignMACAddress(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	secretPath, mac := vars[secretPathVar], vars[macAddressVar]
+
+	if edgexErr := handler.checkSecretPath(secretPath); edgexErr != nil {
+		http.Error(writer, edgexErr.Error(), edgexErr.Code())
+		return
+	}
+	if edgexErr := handler.driver.assignMACAddress(mac, secretPath); edgexErr != nil {
+		http.Error(writer, edgexErr.Error(), edgexErr.Code())
+		return
+	}
+
+	handler.driver.refreshOnvifClients(handler.driver.devicesWithMACAddress(mac))
+	writer.WriteHeader(http.StatusOK)
+}
+
+// unassignMACAddress removes the MAC address from the credential group
+func (handler CredentialsRestHandler) unassignMACAddress(writer http.ResponseWriter, request *http.Request) {
+	d := handler.driver
+	vars := mux.Vars(request)
+	secretPath, mac := vars[secretPathVar], vars[macAddressVar]
+
+	current, found := d.macAddressMapper.LookupSecretPathForMACAddress(mac)
+	if !found || current != secretPath {
+		http.Error(writer, fmt.Sprintf("MAC address '%s' does not belong to the credential group '%s'", mac, secretPath), http.StatusNotFound)
+		return
+	}
+	if edgexErr := d.assignMACAddress(mac, ""); edgexErr != nil {
+		http.Error(writer, edgexErr.Error(), edgexErr.Code())
+		return
+	}
+
+	d.refreshOnvifClients(d.devicesWithMACAddress(mac))
+	writer.WriteHeader(http.StatusOK)
+}
+
+// assignDevice assigns the device to the credential group. The MAC address of the device is moved into the group,
+// or if the device has no MAC address, the SecretPath of the device is set instead.
+func (handler CredentialsRestHandler) assignDevice(writer http.ResponseWriter, request *http.Request) {
+	d := handler.driver
+	vars := mux.Vars(request)
+	secretPath, deviceName := vars[secretPathVar], vars[common.DeviceName]
+
+	if edgexErr := handler.checkSecretPath(secretPath); edgexErr != nil {
+		http.Error(writer, edgexErr.Error(), edgexErr.Code())
+		return
+	}
+	device, err := d.sdkService.GetDeviceByName(deviceName)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("Device '%s' not found", deviceName), http.StatusNotFound)
+		return
+	}
+
+	protocol := device.Protocols[OnvifProtocol]
+	mac, macErr := SanitizeMACAddress(protocol[MACAddress])
+	updateDevice := false
+	if macErr == nil {
+		if edgexErr := d.assignMACAddress(mac, secretPath); edgexErr != nil {
+			http.Error(writer, edgexErr.Error(), edgexErr.Code())
+			return
+		}
+		// remove any override, so the MAC address mapping takes effect
+		if _, found := protocol[SecretPath]; found {
+			delete(protocol, SecretPath)
+			updateDevice = true
+		}
+	} else {
+		protocol[SecretPath] = secretPath
+		updateDevice = true
+	}
+
+	// note: updating the device will also refresh its client
+	if updateDevice {
+		if err = d.sdkService.UpdateDevice(device); err != nil {
+			http.Error(writer, fmt.Sprintf("Failed to update device '%s': %s", deviceName, err.Error()), http.StatusInternalServerError)
+			return
+		}
+	}
+	if macErr == nil {
+		d.refreshOnvifClients(d.devicesWithMACAddress(mac))
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+// unassignDevice removes the device from the credential group, by removing its SecretPath and its MAC address
+// from the group
+func (handler CredentialsRestHandler) unassignDevice(writer http.ResponseWriter, request *http.Request) {
+	d := handler.driver
+	vars := mux.Vars(request)
+	secretPath, deviceName := vars[secretPathVar], vars[common.DeviceName]
+
+	device, err := d.sdkService.GetDeviceByName(deviceName)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("Device '%s' not found", deviceName), http.StatusNotFound)
+		return
+	}
+
+	protocol := device.Protocols[OnvifProtocol]
+	removed := false
+	if mac := protocol[MACAddress]; mac != "" {
+		if current, found := d.macAddressMapper.LookupSecretPathForMACAddress(mac); found && current == secretPath {
+			if edgexErr := d.assignMACAddress(mac, ""); edgexErr != nil {
+				http.Error(writer, edgexErr.Error(), edgexErr.Code())
+				return
+			}
+			d.refreshOnvifClients(d.devicesWithMACAddress(mac))
+			removed = true
+		}
+	}
+	if protocol[SecretPath] == secretPath {
+		delete(protocol, SecretPath)
+		// note: updating the device will also refresh its client
+		if err = d.sdkService.UpdateDevice(device); err != nil {
+			http.Error(writer, fmt.Sprintf("Failed to update device '%s': %s", deviceName, err.Error()), http.StatusInternalServerError)
+			return
+		}
+		removed = true
+	}
+
+	if !removed {
+		http.Error(writer, fmt.Sprintf("Device '%s' is not assigned to the credential group '%s'", deviceName, secretPath), http.StatusNotFound)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+// rotatePassword generates a new password for the user of the credential group, and applies it to every device
+// using the group. Returns the names of the devices whose password was rotated.
+func (handler CredentialsRestHandler) rotatePassword(writer http.ResponseWriter, request *http.Request) {
+	secretPath := mux.Vars(request)[secretPathVar]
+
+	devices, edgexErr := handler.driver.rotateCredentialGroupPassword(secretPath)
+	if edgexErr != nil {
+		handler.driver.lc.Errorf("Failed to rotate the password of the credential group %s: %s", secretPath, edgexErr.Error())
+		http.Error(writer, edgexErr.Error(), edgexErr.Code())
+		return
+	}
+
+	handler.writeJSON(writer, devices)
+}
+
+// checkSecretPath returns an error if the credentials of the secret path do not exist in the Secret Store
+func (handler CredentialsRestHandler) checkSecretPath(secretPath string) errors.EdgeX {
+	if _, edgexErr := handler.driver.tryGetCredentials(secretPath); edgexErr != nil {
+		return errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("credential group '%s' does not exist in the Secret Store", secretPath), edgexErr)
+	}
+	return nil
+}
+
+func (handler CredentialsRestHandler) writeJSON(writer http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		handler.driver.lc.Errorf("Failed to marshal the credential groups: %s", err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set(common.ContentType, common.ContentTypeJSON)
+	if _, err = writer.Write(data); err != nil {
+		handler.driver.lc.Errorf("Failed to write the credential groups: %s", err.Error())
+	}
+}
+
+// validateCredentialGroupRequest returns an error if the credential group can not be stored
+func validateCredentialGroupRequest(req CredentialGroupRequest) errors.EdgeX {
+	if strings.TrimSpace(req.SecretPath) == "" {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "secretPath is required", nil)
+	}
+	if strings.ToLower(req.SecretPath) == noAuthSecretPath {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("the %s credential group can not store credentials", req.SecretPath), nil)
+	}
+	if !IsAuthModeValid(req.AuthMode) {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid authMode '%s'", req.AuthMode), nil)
+	}
+	if req.AuthMode != AuthModeNone && req.Username == "" {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "username is required", nil)
+	}
+	return nil
+}
+
+// devicesWithMACAddress returns the names of the devices with the MAC address
+func (d *Driver) devicesWithMACAddress(mac string) []string {
+	sanitized, err := SanitizeMACAddress(mac)
+	if err != nil {
+		return nil
+	}
+	return d.filterDevices(func(device models.Device) bool {
+		deviceMAC, err := SanitizeMACAddress(device.Protocols[OnvifProtocol][MACAddress])
+		return err == nil && deviceMAC == sanitized
+	})
+}
+
+// devicesUsingSecretPath returns the names of the devices using the credentials of the secret path
+func (d *Driver) devicesUsingSecretPath(secretPath string) []string {
+	return d.filterDevices(func(device models.Device) bool {
+		return d.secretPathForDevice(device) == secretPath
+	})
+}
+
+func (d *Driver) filterDevices(match func(device models.Device) bool) []string {
+	var names []string
+	for _, device := range d.sdkService.Devices() {
+		if match(device) {
+			names = append(names, device.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// testConfigWriter records the values written to the Configuration Provider
+type testConfigWriter struct {
+	values map[string]string
+}
+
+func (w *testConfigWriter) PutConfigurationValue(name string, value []byte) error {
+	w.values[name] = string(value)
+	return nil
+}
+
+func createCredentialsRestHandler(t *testing.T, devices []models.Device) (*CredentialsRestHandler, *testConfigWriter, *mocks.SecretProvider) {
+	driver, mockService := createDriverWithMockService()
+	driver.macAddressMapper = NewMACAddressMapper(mockService)
+	driver.configMu = new(sync.RWMutex)
+	driver.config = &ServiceConfig{AppCustom: CustomConfig{
+		DefaultSecretPath: "creds1",
+		CredentialsMap: map[string]string{
+			"NoAuth": "",
+			"creds1": "aa:bb:cc:dd:ee:ff",
+			"creds2": "",
+		},
+	}}
+	writer := &testConfigWriter{values: make(map[string]string)}
+	driver.configWriter = writer
+
+	mockSecretProvider := &mocks.SecretProvider{}
+	mockSecretProvider.On("GetSecret", mock.Anything, UsernameKey, PasswordKey, AuthModeKey).
+		Return(map[string]string{UsernameKey: "user", PasswordKey: "pass", AuthModeKey: AuthModeDigest}, nil)
+	mockSecretProvider.On("RegisteredSecretUpdatedCallback", mock.Anything, mock.Anything).Return(nil)
+	mockService.On("GetSecretProvider").Return(mockSecretProvider)
+	mockService.On("GetLoggingClient").Return(logger.NewMockClient())
+	mockService.On("Devices").Return(devices)
+
+	driver.macAddressMapper.UpdateMappings(driver.config.AppCustom.CredentialsMap)
+	require.NotNil(t, driver.macAddressMapper.credsMap)
+
+	return NewCredentialsRestHandler(driver), writer, mockSecretProvider
+}
+
+func TestCredentialsRestHandler_getCredentialGroups(t *testing.T) {
+	handler, _, _ := createCredentialsRestHandler(t, []models.Device{
+		{Name: "cam1", Protocols: map[string]models.ProtocolProperties{OnvifProtocol: {MACAddress: "AA-BB-CC-DD-EE-FF"}}},
+		{Name: "cam2", Protocols: map[string]models.ProtocolProperties{OnvifProtocol: {SecretPath: "override"}}},
+	})
+
+	recorder := httptest.NewRecorder()
+	handler.getCredentialGroups(recorder, httptest.NewRequest(http.MethodGet, apiCredentialsRoute, nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var groups []CredentialGroup
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &groups))
+	assert.Equal(t, []CredentialGroup{
+		{SecretPath: "NoAuth", MACAddresses: []string{}, Devices: []string{}},
+		{SecretPath: "creds1", Default: true, MACAddresses: []string{"aa:bb:cc:dd:ee:ff"}, Devices: []string{"cam1"}},
+		{SecretPath: "creds2", MACAddresses: []string{}, Devices: []string{}},
+		{SecretPath: "override", MACAddresses: []string{}, Devices: []string{"cam2"}},
+	}, groups)
+	assert.NotContains(t, recorder.Body.String(), "pass")
+}
+
+func TestCredentialsRestHandler_assignMACAddress(t *testing.T) {
+	handler, writer, _ := createCredentialsRestHandler(t, nil)
+
+	request := httptest.NewRequest(http.MethodPut, apiCredentialsRoute+"/creds2/mac/aa:bb:cc:dd:ee:ff", nil)
+	request = mux.SetURLVars(request, map[string]string{secretPathVar: "creds2", macAddressVar: "aa:bb:cc:dd:ee:ff"})
+	recorder := httptest.NewRecorder()
+	handler.assignMACAddress(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	assert.Equal(t, map[string]string{
+		credentialsMapConfigKey + "/creds1": "",
+		credentialsMapConfigKey + "/creds2": "aa:bb:cc:dd:ee:ff",
+	}, writer.values)
+	secretPath, found := handler.driver.macAddressMapper.LookupSecretPathForMACAddress("aa:bb:cc:dd:ee:ff")
+	assert.True(t, found)
+	assert.Equal(t, "creds2", secretPath)
+
+	// unassigning from the wrong group fails
+	request = mux.SetURLVars(request, map[string]string{secretPathVar: "creds1", macAddressVar: "aa:bb:cc:dd:ee:ff"})
+	recorder = httptest.NewRecorder()
+	handler.unassignMACAddress(recorder, request)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	request = mux.SetURLVars(request, map[string]string{secretPathVar: "creds2", macAddressVar: "aa:bb:cc:dd:ee:ff"})
+	recorder = httptest.NewRecorder()
+	handler.unassignMACAddress(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "", writer.values[credentialsMapConfigKey+"/creds2"])
+	_, found = handler.driver.macAddressMapper.LookupSecretPathForMACAddress("aa:bb:cc:dd:ee:ff")
+	assert.False(t, found)
+}
+
+func TestCredentialsRestHandler_putCredentialGroup(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{
+			name:         "valid",
+			body:         `{"secretPath":"creds3","username":"admin","password":"secret","authMode":"digest"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "invalid auth mode",
+			body:         `{"secretPath":"creds3","username":"admin","password":"secret","authMode":"invalid"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "missing username",
+			body:         `{"secretPath":"creds3","authMode":"digest"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "no auth",
+			body:         `{"secretPath":"NoAuth","authMode":"none"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid json",
+			body:         `{`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			handler, writer, mockSecretProvider := createCredentialsRestHandler(t, nil)
+			mockSecretProvider.On("StoreSecret", "creds3", map[string]string{
+				UsernameKey: "admin", PasswordKey: "secret", AuthModeKey: AuthModeDigest,
+			}).Return(nil)
+
+			recorder := httptest.NewRecorder()
+			handler.putCredentialGroup(recorder, httptest.NewRequest(http.MethodPost, apiCredentialsRoute, strings.NewReader(test.body)))
+			require.Equal(t, test.expectedCode, recorder.Code)
+
+			if test.expectedCode == http.StatusCreated {
+				mockSecretProvider.AssertExpectations(t)
+				assert.Equal(t, map[string]string{credentialsMapConfigKey + "/creds3": ""}, writer.values)
+				assert.Contains(t, handler.driver.config.AppCustom.CredentialsMap, "creds3")
+			} else {
+				assert.Empty(t, writer.values)
+			}
+		})
+	}
+}
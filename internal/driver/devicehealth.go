@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Connection methods which are reported in the health details of a device
+const (
+	methodCredentialLookup     = "CredentialLookup"
+	methodGetCapabilities      = "GetCapabilities"
+	methodGetSystemDateAndTime = "GetSystemDateAndTime"
+	methodGetDeviceInfo        = "GetDeviceInformation"
+	methodAuthModeDetection    = "AuthModeDetection"
+	methodTCPProbe             = "TCPProbe"
+	methodICMPProbe            = "ICMPEcho"
+	methodARPLookup            = "ARPLookup"
+	methodRTSPDescribe         = "RTSPDescribe"
+)
+
+// ConnectionMethodResult holds the outcome of a single connection method tested during a status check
+type ConnectionMethodResult struct {
+	Method        string `json:"method"`
+	Success       bool   `json:"success"`
+	LatencyMillis int64  `json:"latencyMillis"`
+	Error         string `json:"error,omitempty"`
+}
+
+// StatusTransition records a change of the DeviceStatus of a device
+type StatusTransition struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DeviceHealth holds the details of the latest status check of a device, along with its status history
+type DeviceHealth struct {
+	DeviceName        string                   `json:"deviceName"`
+	Status            string                   `json:"status"`
+	LastCheck         time.Time                `json:"lastCheck"`
+	SecretPath        string                   `json:"secretPath"`
+	LastError         string                   `json:"lastError,omitempty"`
+	ConnectionMethods []ConnectionMethodResult `json:"connectionMethods"`
+	StatusHistory     []StatusTransition       `json:"statusHistory"`
+}
+
+// addResult appends the result of a connection method which was started at the specified time
+func (health *DeviceHealth) addResult(method string, start time.Time, err error) {
+	result := ConnectionMethodResult{
+		Method:        method,
+		Success:       err == nil,
+		LatencyMillis: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		health.LastError = result.Error
+	}
+	health.ConnectionMethods = append(health.ConnectionMethods, result)
+}
+
+// DeviceHealthTracker keeps track of the health details of every device checked by the status check
+type DeviceHealthTracker struct {
+	lock    sync.RWMutex
+	devices map[string]*DeviceHealth
+	// historyLength is the maximum number of status transitions kept per device
+	historyLength int
+}
+
+// NewDeviceHealthTracker creates a new DeviceHealthTracker which keeps historyLength status transitions per device
+func NewDeviceHealthTracker(historyLength int) *DeviceHealthTracker {
+	return &DeviceHealthTracker{
+		devices:       make(map[string]*DeviceHealth),
+		historyLength: historyLength,
+	}
+}
+
+// RecordCheck stores the result of a status check, keeping the existing status history of the device
+func (tracker *DeviceHealthTracker) RecordCheck(health DeviceHealth) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	if existing, found := tracker.devices[health.DeviceName]; found {
+		health.StatusHistory = existing.StatusHistory
+	}
+	tracker.devices[health.DeviceName] = &health
+}
+
+// RecordTransition appends a status transition to the history of the device. Only the most recent
+// transitions are kept.
+func (tracker *DeviceHealthTracker) RecordTransition(deviceName string, from string, to string) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	health, found := tracker.devices[deviceName]
+	if !found {
+		health = &DeviceHealth{DeviceName: deviceName}
+		tracker.devices[deviceName] = health
+	}
+
+	health.StatusHistory = append(health.StatusHistory, StatusTransition{
+		From:      from,
+		To:        to,
+		Timestamp: time.Now(),
+	})
+	if len(health.StatusHistory) > tracker.historyLength {
+		health.StatusHistory = health.StatusHistory[len(health.StatusHistory)-tracker.historyLength:]
+	}
+}
+
+// Get returns a copy of the health details of the specified device
+func (tracker *DeviceHealthTracker) Get(deviceName string) (DeviceHealth, bool) {
+	tracker.lock.RLock()
+	defer tracker.lock.RUnlock()
+
+	health, found := tracker.devices[deviceName]
+	if !found {
+		return DeviceHealth{}, false
+	}
+	return health.copy(), true
+}
+
+// All returns a copy of the health details of every device, sorted by device name
+func (tracker *DeviceHealthTracker) All() []DeviceHealth {
+	tracker.lock.RLock()
+	defer tracker.lock.RUnlock()
+
+	all := make([]DeviceHealth, 0, len(tracker.devices))
+	for _, health := range tracker.devices {
+		all = append(all, health.copy())
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].DeviceName < all[j].DeviceName
+	})
+	return all
+}
+
+// Remove deletes the health details of the specified device
+func (tracker *DeviceHealthTracker) Remove(deviceName string) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	// note: delete on non-existing keys is a no-op
+	delete(tracker.devices, deviceName)
+}
+
+func (health *DeviceHealth) copy() DeviceHealth {
+	c := *health
+	c.ConnectionMethods = append([]ConnectionMethodResult(nil), health.ConnectionMethods...)
+	c.StatusHistory = append([]StatusTransition(nil), health.StatusHistory...)
+	return c
+}
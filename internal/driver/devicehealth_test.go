@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceHealthTracker_RecordTransition(t *testing.T) {
+	tracker := NewDeviceHealthTracker(2)
+
+	tracker.RecordTransition(testDeviceName, "", Unreachable)
+	tracker.RecordTransition(testDeviceName, Unreachable, UpWithoutAuth)
+	tracker.RecordTransition(testDeviceName, UpWithoutAuth, UpWithAuth)
+
+	health, found := tracker.Get(testDeviceName)
+	require.True(t, found)
+	require.Len(t, health.StatusHistory, 2)
+	assert.Equal(t, Unreachable, health.StatusHistory[0].From)
+	assert.Equal(t, UpWithoutAuth, health.StatusHistory[0].To)
+	assert.Equal(t, UpWithoutAuth, health.StatusHistory[1].From)
+	assert.Equal(t, UpWithAuth, health.StatusHistory[1].To)
+}
+
+func TestDeviceHealthTracker_RecordCheck(t *testing.T) {
+	tracker := NewDeviceHealthTracker(maxStatusHistory)
+	tracker.RecordTransition(testDeviceName, Unreachable, UpWithoutAuth)
+
+	health := DeviceHealth{
+		DeviceName: testDeviceName,
+		Status:     UpWithoutAuth,
+		SecretPath: "credentials001",
+	}
+	health.addResult(methodGetCapabilities, time.Now(), nil)
+	health.addResult(methodGetDeviceInfo, time.Now(), fmt.Errorf("unauthorized"))
+	tracker.RecordCheck(health)
+
+	actual, found := tracker.Get(testDeviceName)
+	require.True(t, found)
+	assert.Equal(t, UpWithoutAuth, actual.Status)
+	assert.Equal(t, "credentials001", actual.SecretPath)
+	assert.Equal(t, "unauthorized", actual.LastError)
+	require.Len(t, actual.ConnectionMethods, 2)
+	assert.True(t, actual.ConnectionMethods[0].Success)
+	assert.False(t, actual.ConnectionMethods[1].Success)
+	// the status history should be kept when recording a new check
+	assert.Len(t, actual.StatusHistory, 1)
+
+	tracker.Remove(testDeviceName)
+	_, found = tracker.Get(testDeviceName)
+	assert.False(t, found)
+}
+
+func TestDeviceHealthRestHandler_getDeviceHealth(t *testing.T) {
+	tracker := NewDeviceHealthTracker(maxStatusHistory)
+	tracker.RecordCheck(DeviceHealth{DeviceName: testDeviceName, Status: UpWithAuth})
+	handler := NewDeviceHealthRestHandler(nil, logger.NewMockClient(), tracker)
+
+	tests := []struct {
+		name           string
+		deviceName     string
+		expectedStatus int
+	}{
+		{
+			name:           "existing device",
+			deviceName:     testDeviceName,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown device",
+			deviceName:     "bogus",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, apiDeviceHealthRoute+"/"+test.deviceName, nil)
+			request = mux.SetURLVars(request, map[string]string{common.DeviceName: test.deviceName})
+			recorder := httptest.NewRecorder()
+
+			handler.getDeviceHealth(recorder, request)
+
+			require.Equal(t, test.expectedStatus, recorder.Code)
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+			var actual DeviceHealth
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &actual))
+			assert.Equal(t, test.deviceName, actual.DeviceName)
+			assert.Equal(t, UpWithAuth, actual.Status)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/edgexfoundry/device-sdk-go/v2/pkg/interfaces"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	DeviceHealthRestPath  = "devicehealth"
+	apiDeviceHealthRoute  = common.ApiBase + "/" + DeviceHealthRestPath
+	apiDeviceHealthByName = apiDeviceHealthRoute + "/{" + common.DeviceName + "}"
+)
+
+// DeviceHealthRestHandler exposes the health details collected by the status check
+type DeviceHealthRestHandler struct {
+	sdkService interfaces.DeviceServiceSDK
+	lc         logger.LoggingClient
+	tracker    *DeviceHealthTracker
+}
+
+// NewDeviceHealthRestHandler creates a new DeviceHealthRestHandler entity
+func NewDeviceHealthRestHandler(service interfaces.DeviceServiceSDK, logger logger.LoggingClient, tracker *DeviceHealthTracker) *DeviceHealthRestHandler {
+	return &DeviceHealthRestHandler{
+		sdkService: service,
+		lc:         logger,
+		tracker:    tracker,
+	}
+}
+
+// AddRoutes adds the routes for querying the health details of all devices or a single device
+func (handler DeviceHealthRestHandler) AddRoutes() errors.EdgeX {
+	if err := handler.sdkService.AddRoute(apiDeviceHealthRoute, handler.getAllDeviceHealth, http.MethodGet); err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("unable to add required route: %s: %s", apiDeviceHealthRoute, err.Error()), err)
+	}
+	handler.lc.Infof("Route %s added.", apiDeviceHealthRoute)
+
+	if err := handler.sdkService.AddRoute(apiDeviceHealthByName, handler.getDeviceHealth, http.MethodGet); err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("unable to add required route: %s: %s", apiDeviceHealthByName, err.Error()), err)
+	}
+	handler.lc.Infof("Route %s added.", apiDeviceHealthByName)
+
+	return nil
+}
+
+// getAllDeviceHealth returns the health details of every device which has been checked
+func (handler DeviceHealthRestHandler) getAllDeviceHealth(writer http.ResponseWriter, _ *http.Request) {
+	handler.writeJSON(writer, handler.tracker.All())
+}
+
+// getDeviceHealth returns the health details of a single device
+func (handler DeviceHealthRestHandler) getDeviceHealth(writer http.ResponseWriter, request *http.Request) {
+	deviceName := mux.Vars(request)[common.DeviceName]
+
+	health, found := handler.tracker.Get(deviceName)
+	if !found {
+		http.Error(writer, fmt.Sprintf("No health details found for device '%s'", deviceName), http.StatusNotFound)
+		return
+	}
+
+	handler.writeJSON(writer, health)
+}
+
+func (handler DeviceHealthRestHandler) writeJSON(writer http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		handler.lc.Errorf("Failed to marshal the device health details: %s", err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set(common.ContentType, common.ContentTypeJSON)
+	if _, err = writer.Write(data); err != nil {
+		handler.lc.Errorf("Failed to write the device health details: %s", err.Error())
+	}
+}
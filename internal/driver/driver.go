@@ -13,9 +13,9 @@ import (
 	"fmt"
 	"github.com/edgexfoundry/device-sdk-go/v2/pkg/interfaces"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -73,6 +73,33 @@ type Driver struct {
 	watchersMu    sync.Mutex
 
 	macAddressMapper *MACAddressMapper
+	// credentialsMapper maps devices to credentials by endpoint reference address, serial number or IP address
+	credentialsMapper *CredentialsMapper
+
+	// healthTracker keeps the health details collected by the status check
+	healthTracker *DeviceHealthTracker
+
+	// credentialProber rate limits the credential probing of UpWithoutAuth devices
+	credentialProber *CredentialProber
+	// secretCallbacks are the secret paths which have a secret updated callback registered
+	secretCallbacks   map[string]struct{}
+	secretCallbacksMu sync.Mutex
+	// rotationMu ensures only one password rotation is in progress at a time
+	rotationMu sync.Mutex
+	// circuitBreaker fails the requests to the devices which stopped responding immediately
+	circuitBreaker *CircuitBreaker
+	// requestLimiter limits the amount of concurrent requests sent to each device
+	requestLimiter *RequestLimiter
+	// snapshotCache keeps the media profiles and snapshot uris used to take the snapshots of the devices
+	snapshotCache *SnapshotCache
+	// ptzCache keeps the media profiles and PTZ node spaces used to move the devices
+	ptzCache *PTZCache
+	// snapshotScheduler keeps track of the scheduled snapshots of the devices
+	snapshotScheduler *SnapshotScheduler
+	// transportPool holds the http transports shared by the Onvif clients
+	transportPool *HTTPTransportPool
+	// configWriter persists configuration changes made by the service, nil if not using a Configuration Provider
+	configWriter ConfigWriter
 
 	// debounceTimer and debounceMu keep track of when to fire a debounced discovery call
 	debounceTimer *time.Timer
@@ -115,6 +142,9 @@ func (d *Driver) Initialize(lc logger.LoggingClient, asyncCh chan<- *sdkModel.As
 	d.onvifClients = make(map[string]*OnvifClient)
 	d.sdkService = service.RunningService()
 	d.macAddressMapper = NewMACAddressMapper(d.sdkService)
+	d.credentialsMapper = NewCredentialsMapper(d.sdkService)
+	d.healthTracker = NewDeviceHealthTracker(maxStatusHistory)
+	d.credentialProber = NewCredentialProber()
 	d.config = &ServiceConfig{}
 
 	err := d.sdkService.LoadCustomConfig(d.config, "AppCustom")
@@ -129,7 +159,22 @@ func (d *Driver) Initialize(lc logger.LoggingClient, asyncCh chan<- *sdkModel.As
 			d.config.AppCustom.DiscoveryMode)
 	}
 
+	d.transportPool = NewHTTPTransportPool(newHTTPTransportSettings(d.config.AppCustom))
+	d.circuitBreaker = NewCircuitBreaker(d.config.AppCustom.CircuitBreakerFailureThreshold)
+	d.requestLimiter = NewRequestLimiter()
+	d.snapshotCache = NewSnapshotCache()
+	d.snapshotScheduler = NewSnapshotScheduler()
+	d.ptzCache = NewPTZCache()
 	d.macAddressMapper.UpdateMappings(d.config.AppCustom.CredentialsMap)
+	d.credentialsMapper.UpdateMappings(d.config.AppCustom.EndpointRefCredentialsMap,
+		d.config.AppCustom.SerialNumberCredentialsMap, d.config.AppCustom.IPCredentialsMap)
+
+	d.configWriter, err = newConfigProviderWriter(d.sdkService, lc)
+	if err != nil {
+		d.lc.Warnf("Unable to create Configuration Provider client, configuration changes made by the service will not be persisted: %s", err.Error())
+	} else if d.configWriter == nil {
+		d.lc.Info("Not using a Configuration Provider, configuration changes made by the service will not be persisted.")
+	}
 
 	err = d.sdkService.ListenForCustomConfigChanges(&d.config.AppCustom, "AppCustom", d.updateWritableConfig)
 	if err != nil {
@@ -149,14 +194,36 @@ func (d *Driver) Initialize(lc logger.LoggingClient, asyncCh chan<- *sdkModel.As
 		d.clientsMu.Unlock()
 	}
 
-	handler := NewRestNotificationHandler(d.sdkService, lc, asyncCh)
+	handler := NewRestNotificationHandler(d.sdkService, lc, asyncCh, d)
 	edgexErr := handler.AddRoute()
 	if edgexErr != nil {
 		return errors.NewCommonEdgeXWrapper(edgexErr)
 	}
 
+	d.registerSecretUpdatedCallbacks()
+
+	credentialsHandler := NewCredentialsRestHandler(d)
+	edgexErr = credentialsHandler.AddRoutes()
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
+	healthHandler := NewDeviceHealthRestHandler(d.sdkService, lc, d.healthTracker)
+	edgexErr = healthHandler.AddRoutes()
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
+	encoderTemplateHandler := NewEncoderTemplateRestHandler(d)
+	edgexErr = encoderTemplateHandler.AddRoutes()
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
 	d.configMu.RLock()
 	enableStatusCheck := d.config.AppCustom.EnableStatusCheck
+	enableTimeSync := d.config.AppCustom.EnableTimeSync
+	enableSnapshotSchedule := d.config.AppCustom.EnableSnapshotSchedule
 	d.configMu.RUnlock()
 
 	if enableStatusCheck {
@@ -169,6 +236,26 @@ func (d *Driver) Initialize(lc logger.LoggingClient, asyncCh chan<- *sdkModel.As
 		}()
 	}
 
+	if enableTimeSync {
+		// starts loop to correct the clocks of the cameras
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done() // wait for timeSyncLoop to return
+			d.timeSyncLoop()
+			d.lc.Info("timeSyncLoop has stopped.")
+		}()
+	}
+
+	if enableSnapshotSchedule {
+		// starts loop to capture the scheduled snapshots
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done() // wait for snapshotScheduleLoop to return
+			d.snapshotScheduleLoop()
+			d.lc.Info("snapshotScheduleLoop has stopped.")
+		}()
+	}
+
 	d.lc.Info("Driver initialized.")
 	return nil
 }
@@ -181,8 +268,14 @@ func (d *Driver) updateWritableConfig(rawWritableConfig interface{}) {
 		return
 	}
 
+	// note: the secret paths are resolved before and after the update, so only the clients of the devices
+	// whose credentials changed are refreshed
+	previousSecretPaths := d.deviceSecretPaths()
+
 	d.configMu.Lock()
 	oldSubnets := d.config.AppCustom.DiscoverySubnets
+	tlsChanged := d.config.AppCustom.DefaultTLSPolicy != updated.DefaultTLSPolicy ||
+		!reflect.DeepEqual(d.config.AppCustom.TLSPolicies, updated.TLSPolicies)
 	d.config.AppCustom = *updated
 	d.configMu.Unlock()
 
@@ -191,7 +284,16 @@ func (d *Driver) updateWritableConfig(rawWritableConfig interface{}) {
 		d.debouncedDiscover()
 	}
 
-	d.macAddressMapper.UpdateMappings(d.config.AppCustom.CredentialsMap)
+	d.macAddressMapper.UpdateMappings(updated.CredentialsMap)
+	d.credentialsMapper.UpdateMappings(updated.EndpointRefCredentialsMap, updated.SerialNumberCredentialsMap, updated.IPCredentialsMap)
+	d.circuitBreaker.SetThreshold(updated.CircuitBreakerFailureThreshold)
+	if d.transportPool.Update(newHTTPTransportSettings(*updated), tlsChanged) {
+		d.lc.Info("HTTP transport or TLS configuration has changed, refreshing the onvif clients of all devices.")
+		d.refreshOnvifClients(d.deviceNames())
+	} else {
+		d.refreshChangedOnvifClients(previousSecretPaths)
+	}
+	d.registerSecretUpdatedCallbacks()
 	// check device statuses in case the credentials map was updated
 	d.checkStatuses()
 }
@@ -444,8 +546,10 @@ func (d *Driver) Stop(force bool) error {
 		client.baseNotificationManager.UnsubscribeAll()
 	}
 
-	close(d.taskCh) // send signal for taskLoop to finish
-	d.wg.Wait()     // wait for taskLoop goroutine to return
+	close(d.taskCh) // send signal for taskLoop, timeSyncLoop and snapshotScheduleLoop to finish
+	d.wg.Wait()     // wait for taskLoop, timeSyncLoop and snapshotScheduleLoop goroutines to return
+
+	d.transportPool.CloseIdleConnections()
 
 	return nil
 }
@@ -453,6 +557,7 @@ func (d *Driver) Stop(force bool) error {
 // AddDevice is a callback function that is invoked
 // when a new Device associated with this Device Service is added
 func (d *Driver) AddDevice(deviceName string, protocols map[string]models.ProtocolProperties, adminState models.AdminState) error {
+	d.registerSecretUpdatedCallback(protocols[OnvifProtocol][SecretPath])
 	err := d.createOnvifClient(deviceName)
 	if err != nil {
 		return errors.NewCommonEdgeXWrapper(err)
@@ -463,6 +568,24 @@ func (d *Driver) AddDevice(deviceName string, protocols map[string]models.Protoc
 // UpdateDevice is a callback function that is invoked
 // when a Device associated with this Device Service is updated
 func (d *Driver) UpdateDevice(deviceName string, protocols map[string]models.ProtocolProperties, adminState models.AdminState) error {
+	d.registerSecretUpdatedCallback(protocols[OnvifProtocol][SecretPath])
+
+	d.clientsMu.RLock()
+	onvifClient, found := d.onvifClients[deviceName]
+	d.clientsMu.RUnlock()
+
+	// The onvif device of an existing client is replaced in place when only the credentials or connection properties
+	// changed, so its active subscriptions are kept
+	if found {
+		resource, edgexErr := d.getCameraEventResourceByDeviceName(deviceName)
+		if edgexErr == nil && reflect.DeepEqual(resource, onvifClient.CameraEventResource) {
+			if edgexErr = d.refreshOnvifClient(onvifClient); edgexErr != nil {
+				return errors.NewCommonEdgeXWrapper(edgexErr)
+			}
+			return nil
+		}
+	}
+
 	// Invoke the createOnvifClient func to create new onvif client and replace the old one
 	err := d.createOnvifClient(deviceName)
 	if err != nil {
@@ -475,9 +598,84 @@ func (d *Driver) UpdateDevice(deviceName string, protocols map[string]models.Pro
 // when a Device associated with this Device Service is removed
 func (d *Driver) RemoveDevice(deviceName string, protocols map[string]models.ProtocolProperties) error {
 	d.removeOnvifClient(deviceName)
+	d.healthTracker.Remove(deviceName)
+	d.credentialProber.Remove(deviceName)
+	d.circuitBreaker.Remove(deviceName)
+	d.snapshotCache.Remove(deviceName)
+	d.snapshotScheduler.Remove(deviceName)
+	d.ptzCache.Remove(deviceName)
+	return nil
+}
+
+// refreshOnvifClients updates the Onvif clients of the devices, so they use the latest credentials.
+// Existing clients are kept and only their onvif device is replaced, so any active subscriptions are kept.
+func (d *Driver) refreshOnvifClients(deviceNames []string) {
+	for _, deviceName := range deviceNames {
+		d.clientsMu.RLock()
+		onvifClient, found := d.onvifClients[deviceName]
+		d.clientsMu.RUnlock()
+
+		if !found {
+			if err := d.createOnvifClient(deviceName); err != nil {
+				d.lc.Warnf("Unable to refresh the onvif client of device %s: %s", deviceName, err.Error())
+			}
+			continue
+		}
+
+		if edgexErr := d.refreshOnvifClient(onvifClient); edgexErr != nil {
+			d.lc.Warnf("Unable to refresh the onvif client of device %s: %s", deviceName, edgexErr.Error())
+		}
+	}
+}
+
+// refreshOnvifClient replaces the onvif device of the existing client with one using the latest credentials and
+// connection properties of the device
+func (d *Driver) refreshOnvifClient(onvifClient *OnvifClient) errors.EdgeX {
+	device, err := d.sdkService.GetDeviceByName(onvifClient.DeviceName)
+	if err != nil {
+		return errors.NewCommonEdgeXWrapper(err)
+	}
+	onvifDevice, edgexErr := d.newOnvifDevice(device)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	onvifClient.setOnvifDevice(onvifDevice, d.requestPolicyForDevice(device))
+	d.lc.Debugf("Refreshed the credentials of the onvif client of device %s", onvifClient.DeviceName)
 	return nil
 }
 
+// deviceSecretPaths returns the secret path currently used by each device
+func (d *Driver) deviceSecretPaths() map[string]string {
+	secretPaths := make(map[string]string)
+	for _, device := range d.sdkService.Devices() {
+		secretPaths[device.Name] = d.secretPathForDevice(device)
+	}
+	return secretPaths
+}
+
+// deviceNames returns the names of all devices
+func (d *Driver) deviceNames() []string {
+	devices := d.sdkService.Devices()
+	deviceNames := make([]string, 0, len(devices))
+	for _, device := range devices {
+		deviceNames = append(deviceNames, device.Name)
+	}
+	return deviceNames
+}
+
+// refreshChangedOnvifClients refreshes the Onvif clients of the devices whose secret path is different from
+// the previous secret paths
+func (d *Driver) refreshChangedOnvifClients(previous map[string]string) {
+	var changed []string
+	for deviceName, secretPath := range d.deviceSecretPaths() {
+		if previous[deviceName] != secretPath {
+			d.lc.Infof("Credentials of device %s changed from secret path %s to %s", deviceName, previous[deviceName], secretPath)
+			changed = append(changed, deviceName)
+		}
+	}
+	d.refreshOnvifClients(changed)
+}
+
 // createOnvifClient creates the Onvif client used to communicate with the specified the device
 func (d *Driver) createOnvifClient(deviceName string) error {
 	device, err := d.sdkService.GetDeviceByName(deviceName)
@@ -665,11 +863,6 @@ func (d *Driver) getEndpointReference(device models.Device) (devInfo *onvifdevic
 
 // newOnvifClient creates a temporary client for auto-discovery
 func (d *Driver) newTemporaryOnvifClient(device models.Device) (*OnvifClient, errors.EdgeX) {
-	xAddr, edgexErr := GetCameraXAddr(device.Protocols)
-	if edgexErr != nil {
-		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create cameraInfo for camera %s", device.Name), edgexErr)
-	}
-
 	credential, edgexErr := d.tryGetCredentialsForDevice(device)
 	if edgexErr != nil {
 		// if credentials are not found, instead of returning an error, set the AuthMode to NoAuth
@@ -678,27 +871,40 @@ func (d *Driver) newTemporaryOnvifClient(device models.Device) (*OnvifClient, er
 		credential = noAuthCredentials
 	}
 
-	d.configMu.Lock()
-	requestTimeout := d.config.AppCustom.RequestTimeout
-	d.configMu.Unlock()
+	return d.newTemporaryOnvifClientWithCredentials(device, credential)
+}
+
+// newTemporaryOnvifClientWithCredentials creates a temporary client which uses the specified credentials
+func (d *Driver) newTemporaryOnvifClientWithCredentials(device models.Device, credential Credentials) (*OnvifClient, errors.EdgeX) {
+	xAddr, edgexErr := GetCameraXAddr(device.Protocols)
+	if edgexErr != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create cameraInfo for camera %s", device.Name), edgexErr)
+	}
+	credential = d.resolveAuthMode(device, credential)
+
+	policy := d.requestPolicyForDevice(device)
+	httpClient, edgexErr := d.newHTTPClient(device, policy.Timeout)
+	if edgexErr != nil {
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
 
 	onvifDevice, err := onvif.NewDevice(onvif.DeviceParams{
-		Xaddr:    xAddr,
-		Username: credential.Username,
-		Password: credential.Password,
-		AuthMode: credential.AuthMode,
-		HttpClient: &http.Client{
-			Timeout: time.Duration(requestTimeout) * time.Second,
-		},
+		Xaddr:      xAddr,
+		Username:   credential.Username,
+		Password:   credential.Password,
+		AuthMode:   credential.AuthMode,
+		HttpClient: httpClient,
 	})
 	if err != nil {
 		return nil, errors.NewCommonEdgeX(errors.KindServiceUnavailable, "failed to initialize Onvif device client", err)
 	}
 
 	client := &OnvifClient{
-		lc:          d.lc,
-		DeviceName:  device.Name,
-		onvifDevice: onvifDevice,
+		lc:            d.lc,
+		DeviceName:    device.Name,
+		onvifDevice:   onvifDevice,
+		requestPolicy: policy,
+		limiter:       d.requestLimiter,
 	}
 	return client, nil
 }
@@ -49,7 +49,11 @@ func boolPointer(val bool) *xsd.Boolean {
 
 func createDriverWithMockService() (*Driver, *sdkMocks.DeviceServiceSDK) {
 	mockService := &sdkMocks.DeviceServiceSDK{}
-	driver := &Driver{sdkService: mockService, lc: logger.MockLogger{}}
+	driver := &Driver{sdkService: mockService, lc: logger.MockLogger{}, healthTracker: NewDeviceHealthTracker(maxStatusHistory),
+		credentialProber: NewCredentialProber(), credentialsMapper: NewCredentialsMapper(mockService),
+		transportPool: NewHTTPTransportPool(newHTTPTransportSettings(CustomConfig{})), circuitBreaker: NewCircuitBreaker(0),
+		requestLimiter: NewRequestLimiter(), snapshotCache: NewSnapshotCache(),
+		snapshotScheduler: NewSnapshotScheduler(), ptzCache: NewPTZCache()}
 	return driver, mockService
 }
 
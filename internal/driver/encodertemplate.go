@@ -0,0 +1,680 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/IOTechSystems/onvif/media"
+	"github.com/IOTechSystems/onvif/xsd"
+	xsdOnvif "github.com/IOTechSystems/onvif/xsd/onvif"
+)
+
+const (
+	// EncoderTemplateApplied means the template was applied to the camera, possibly with adjustments
+	EncoderTemplateApplied = "Applied"
+	// EncoderTemplateValidated means the template can be applied to the camera, but was not since it is a dry run
+	EncoderTemplateValidated = "Validated"
+	// EncoderTemplateRejected means the camera does not support the template
+	EncoderTemplateRejected = "Rejected"
+	// EncoderTemplateFailed means the camera could not be configured
+	EncoderTemplateFailed = "Failed"
+)
+
+// EncoderTemplate is a declarative video encoder configuration which can be applied to many cameras. The fields
+// which are not set keep the current value of the camera.
+type EncoderTemplate struct {
+	// Encoding is the video codec, such as H264 or H265
+	Encoding string  `json:"encoding"`
+	Width    int     `json:"width,omitempty"`
+	Height   int     `json:"height,omitempty"`
+	Quality  float64 `json:"quality,omitempty"`
+	// FrameRate is the frame rate limit in frames per second
+	FrameRate float64 `json:"frameRate,omitempty"`
+	// Bitrate is the bitrate limit in kbps
+	Bitrate int `json:"bitrate,omitempty"`
+	// GovLength is the number of frames between the I-frames
+	GovLength int `json:"govLength,omitempty"`
+}
+
+// EncoderTemplateRequest is the body of the request used to apply an encoder template to many cameras
+type EncoderTemplateRequest struct {
+	Template EncoderTemplate `json:"template"`
+	// Devices are the names of the devices to configure
+	Devices []string `json:"devices,omitempty"`
+	// AllDevices configures every UpWithAuth device instead of the Devices. Either one is required, so a malformed
+	// request does not reconfigure every camera.
+	AllDevices bool `json:"allDevices,omitempty"`
+	// ProfileToken is the token of the media profile whose video encoder is configured, the first media profile
+	// with a video encoder is configured by default
+	ProfileToken string `json:"profileToken,omitempty"`
+	// Strict rejects the template when the camera does not support one of its values, instead of using the closest
+	// supported value
+	Strict bool `json:"strict,omitempty"`
+	// DryRun validates the template against the options of the cameras without applying it
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// EncoderTemplateResult is the result of applying the encoder template to a device
+type EncoderTemplateResult struct {
+	Device             string `json:"device"`
+	Status             string `json:"status"`
+	MediaService       string `json:"mediaService,omitempty"`
+	ProfileToken       string `json:"profileToken,omitempty"`
+	ConfigurationToken string `json:"configurationToken,omitempty"`
+	// Configuration is the video encoder configuration which was applied, or would be applied for a dry run
+	Configuration *EncoderTemplate `json:"configuration,omitempty"`
+	// Adjustments describe the values of the template which were replaced by the closest supported value
+	Adjustments []string `json:"adjustments,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// encoderResolution is a video resolution supported by a video encoder
+type encoderResolution struct {
+	Width  int
+	Height int
+}
+
+// encoderRange is the range of values supported by a video encoder
+type encoderRange struct {
+	Min float64
+	Max float64
+}
+
+// encoderOptions are the options of an encoding supported by a video encoder, normalized from the options of the
+// media services. The ranges are nil when unknown.
+type encoderOptions struct {
+	Encoding    string
+	Resolutions []encoderResolution
+	// FrameRates are the supported frame rates reported by Media2, instead of the FrameRateRange of Media
+	FrameRates     []float64
+	FrameRateRange *encoderRange
+	BitrateRange   *encoderRange
+	GovLengthRange *encoderRange
+	QualityRange   *encoderRange
+}
+
+// encoderConfiguration is the video encoder configuration of a camera, along with the options of its encodings
+type encoderConfiguration struct {
+	token   string
+	current EncoderTemplate
+	options []encoderOptions
+	// set applies the settings to the video encoder configuration of the camera
+	set func(settings EncoderTemplate) errors.EdgeX
+}
+
+// validateEncoderTemplateRequest returns an error if the encoder template request is invalid
+func validateEncoderTemplateRequest(request EncoderTemplateRequest) errors.EdgeX {
+	template := request.Template
+	if strings.TrimSpace(template.Encoding) == "" {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "the encoding of the template is required", nil)
+	}
+	if (template.Width == 0) != (template.Height == 0) {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "the width and height of the template must be set together", nil)
+	}
+	if template.Width < 0 || template.Height < 0 || template.Quality < 0 || template.FrameRate < 0 || template.Bitrate < 0 || template.GovLength < 0 {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "the values of the template must not be negative", nil)
+	}
+	if len(request.Devices) == 0 && !request.AllDevices {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "the devices to configure are required, or allDevices to configure every UpWithAuth device", nil)
+	}
+	if len(request.Devices) > 0 && request.AllDevices {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "devices and allDevices can not be set together", nil)
+	}
+	return nil
+}
+
+// applyEncoderTemplate applies the encoder template to the requested devices, or to every UpWithAuth device if all
+// the devices are requested. The devices are configured concurrently, and the results are returned in the order of the
+// devices.
+func (d *Driver) applyEncoderTemplate(request EncoderTemplateRequest) ([]EncoderTemplateResult, errors.EdgeX) {
+	if edgexErr := validateEncoderTemplateRequest(request); edgexErr != nil {
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	names := request.Devices
+	if request.AllDevices {
+		names = d.filterDevices(func(device models.Device) bool {
+			return device.Protocols[OnvifProtocol][DeviceStatus] == UpWithAuth
+		})
+	}
+	if len(names) == 0 {
+		return nil, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, "no devices to apply the encoder template to", nil)
+	}
+
+	results := make([]EncoderTemplateResult, len(names))
+	wg := sync.WaitGroup{}
+	for i, name := range names {
+		// the clients are retrieved sequentially, since getOnvifClient may create them
+		onvifClient, edgexErr := d.getOnvifClient(name)
+		if edgexErr != nil {
+			results[i] = EncoderTemplateResult{Device: name, Status: EncoderTemplateFailed, Error: edgexErr.Error()}
+			continue
+		}
+		i := i // save the index within the closure
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = onvifClient.applyEncoderTemplate(request)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Status == EncoderTemplateRejected || result.Status == EncoderTemplateFailed {
+			d.lc.Warnf("Unable to apply the encoder template to device %s: %s", result.Device, result.Error)
+		}
+	}
+	return results, nil
+}
+
+// applyEncoderTemplate checks the encoder template against the options of the video encoder of the media profile,
+// adjusts or rejects the values which are not supported, and applies it to the camera
+func (onvifClient *OnvifClient) applyEncoderTemplate(request EncoderTemplateRequest) EncoderTemplateResult {
+	result := EncoderTemplateResult{Device: onvifClient.DeviceName}
+	fail := func(status string, edgexErr errors.EdgeX) EncoderTemplateResult {
+		result.Status = status
+		result.Error = edgexErr.Error()
+		return result
+	}
+
+	profiles, mediaService, edgexErr := onvifClient.getMediaProfiles("")
+	if edgexErr != nil {
+		return fail(EncoderTemplateFailed, edgexErr)
+	}
+	result.MediaService = mediaService
+	profile, edgexErr := selectEncoderProfile(profiles, request.ProfileToken)
+	if edgexErr != nil {
+		return fail(EncoderTemplateFailed, edgexErr)
+	}
+	result.ProfileToken = string(profile.Token)
+
+	var encoder encoderConfiguration
+	if mediaService == onvif.Media2WebService {
+		encoder, edgexErr = onvifClient.getMedia2EncoderConfiguration(profile)
+	} else {
+		encoder, edgexErr = onvifClient.getMediaEncoderConfiguration(profile)
+	}
+	if edgexErr != nil {
+		return fail(EncoderTemplateFailed, edgexErr)
+	}
+	result.ConfigurationToken = encoder.token
+
+	settings, adjustments, edgexErr := fitEncoderTemplate(request.Template, encoder.current, encoder.options, request.Strict)
+	result.Adjustments = adjustments
+	if edgexErr != nil {
+		return fail(EncoderTemplateRejected, edgexErr)
+	}
+	result.Configuration = &settings
+	if request.DryRun {
+		result.Status = EncoderTemplateValidated
+		return result
+	}
+
+	if edgexErr = encoder.set(settings); edgexErr != nil {
+		return fail(EncoderTemplateFailed, edgexErr)
+	}
+	// the cached snapshot uris may depend on the previous video encoder configuration
+	onvifClient.driver.snapshotCache.Remove(onvifClient.DeviceName)
+	onvifClient.lc.Infof("Applied the encoder template to the video encoder configuration '%s' of device %s.", encoder.token, onvifClient.DeviceName)
+	result.Status = EncoderTemplateApplied
+	return result
+}
+
+// selectEncoderProfile returns the media profile with the token, or the first media profile with a video encoder
+// configuration if the token is empty
+func selectEncoderProfile(profiles []xsdOnvif.Profile, token string) (xsdOnvif.Profile, errors.EdgeX) {
+	for _, profile := range profiles {
+		if token != "" && string(profile.Token) != token {
+			continue
+		}
+		if profile.VideoEncoderConfiguration != nil {
+			return profile, nil
+		}
+		if token != "" {
+			return profile, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("media profile with token '%s' does not have a video encoder configuration", token), nil)
+		}
+	}
+	if token != "" {
+		return xsdOnvif.Profile{}, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("media profile with token '%s' not found", token), nil)
+	}
+	return xsdOnvif.Profile{}, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, "no media profile with a video encoder configuration found", nil)
+}
+
+// fitEncoderTemplate returns the settings of the video encoder resulting from the template, starting from the current
+// settings. The values which are not supported by the encoding options are replaced by the closest supported value,
+// and described by the returned adjustments, unless strict is true, in which case the template is rejected. The
+// current values are adjusted as well when they are not supported by the encoding of the template.
+func fitEncoderTemplate(template EncoderTemplate, current EncoderTemplate, options []encoderOptions, strict bool) (EncoderTemplate, []string, errors.EdgeX) {
+	var option *encoderOptions
+	supported := make([]string, 0, len(options))
+	for i := range options {
+		supported = append(supported, options[i].Encoding)
+		if normalizeEncoding(options[i].Encoding) == normalizeEncoding(template.Encoding) {
+			option = &options[i]
+		}
+	}
+	if option == nil {
+		return EncoderTemplate{}, nil, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("the encoding %s is not supported by the camera, which supports %v", template.Encoding, supported), nil)
+	}
+
+	settings := current
+	settings.Encoding = option.Encoding
+	var adjustments []string
+	var rejected errors.EdgeX
+	adjust := func(name string, requested bool, from string, to string) {
+		if requested && strict {
+			if rejected == nil {
+				rejected = errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("the %s %s is not supported by the camera, the closest supported value is %s", name, from, to), nil)
+			}
+			return
+		}
+		adjustments = append(adjustments, fmt.Sprintf("%s %s is not supported, using %s", name, from, to))
+	}
+
+	if template.Width != 0 {
+		settings.Width, settings.Height = template.Width, template.Height
+	}
+	if resolution, found := closestResolution(option.Resolutions, settings.Width, settings.Height); found &&
+		(resolution.Width != settings.Width || resolution.Height != settings.Height) {
+		adjust("resolution", template.Width != 0, fmt.Sprintf("%dx%d", settings.Width, settings.Height), fmt.Sprintf("%dx%d", resolution.Width, resolution.Height))
+		settings.Width, settings.Height = resolution.Width, resolution.Height
+	}
+
+	fitValue := func(name string, requested float64, value *float64, fit func(float64) float64) {
+		if requested != 0 {
+			*value = requested
+		}
+		if *value == 0 {
+			return
+		}
+		if fitted := fit(*value); fitted != *value {
+			adjust(name, requested != 0, strconv.FormatFloat(*value, 'f', -1, 64), strconv.FormatFloat(fitted, 'f', -1, 64))
+			*value = fitted
+		}
+	}
+	fitValue("frame rate", template.FrameRate, &settings.FrameRate, func(v float64) float64 {
+		if len(option.FrameRates) > 0 {
+			return closestFrameRate(option.FrameRates, v)
+		}
+		return option.FrameRateRange.clamp(v)
+	})
+	fitValue("quality", template.Quality, &settings.Quality, option.QualityRange.clamp)
+	bitrate, govLength := float64(settings.Bitrate), float64(settings.GovLength)
+	fitValue("bitrate", float64(template.Bitrate), &bitrate, option.BitrateRange.clamp)
+	fitValue("GOV length", float64(template.GovLength), &govLength, option.GovLengthRange.clamp)
+	settings.Bitrate, settings.GovLength = int(bitrate), int(govLength)
+
+	if rejected != nil {
+		return EncoderTemplate{}, adjustments, rejected
+	}
+	return settings, adjustments, nil
+}
+
+// normalizeEncoding returns the encoding in upper case, with the Media2 name of MPEG4 replaced by the Media name
+func normalizeEncoding(encoding string) string {
+	encoding = strings.ToUpper(strings.TrimSpace(encoding))
+	switch encoding {
+	case "MPV4-ES", "MP4V-ES":
+		return "MPEG4"
+	}
+	return encoding
+}
+
+// clamp returns the value within the range, or the value unchanged if the range is unknown
+func (r *encoderRange) clamp(value float64) float64 {
+	if r == nil {
+		return value
+	}
+	return math.Max(r.Min, math.Min(r.Max, value))
+}
+
+// newEncoderRange returns the range, or nil if the range is unknown
+func newEncoderRange(min float64, max float64) *encoderRange {
+	if max <= 0 || min > max {
+		return nil
+	}
+	return &encoderRange{Min: min, Max: max}
+}
+
+// closestResolution returns the resolution if supported, otherwise the largest supported resolution which is not
+// larger than the resolution, or the smallest supported resolution if all are larger. Returns false if there are no
+// supported resolutions or the resolution is unknown.
+func closestResolution(resolutions []encoderResolution, width int, height int) (encoderResolution, bool) {
+	if len(resolutions) == 0 || width == 0 || height == 0 {
+		return encoderResolution{}, false
+	}
+	var largest, smallest *encoderResolution
+	for i, resolution := range resolutions {
+		if resolution.Width == width && resolution.Height == height {
+			return resolution, true
+		}
+		area := resolution.Width * resolution.Height
+		if resolution.Width <= width && resolution.Height <= height && (largest == nil || area > largest.Width*largest.Height) {
+			largest = &resolutions[i]
+		}
+		if smallest == nil || area < smallest.Width*smallest.Height {
+			smallest = &resolutions[i]
+		}
+	}
+	if largest != nil {
+		return *largest, true
+	}
+	return *smallest, true
+}
+
+// closestFrameRate returns the largest supported frame rate which is not larger than the frame rate, or the smallest
+// supported frame rate if all are larger
+func closestFrameRate(frameRates []float64, frameRate float64) float64 {
+	closest, smallest := 0.0, frameRates[0]
+	for _, rate := range frameRates {
+		if rate <= frameRate && rate > closest {
+			closest = rate
+		}
+		smallest = math.Min(smallest, rate)
+	}
+	if closest == 0 {
+		return smallest
+	}
+	return closest
+}
+
+// getMediaEncoderConfiguration returns the Media video encoder configuration of the media profile
+func (onvifClient *OnvifClient) getMediaEncoderConfiguration(profile xsdOnvif.Profile) (encoderConfiguration, errors.EdgeX) {
+	token := profile.VideoEncoderConfiguration.Token
+	requestData, err := json.Marshal(media.GetVideoEncoderConfiguration{ConfigurationToken: token})
+	if err != nil {
+		return encoderConfiguration{}, errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the GetVideoEncoderConfiguration request", err)
+	}
+	respContent, edgexErr := onvifClient.callOnvifFunction(onvif.MediaWebService, onvif.GetVideoEncoderConfiguration, requestData)
+	if edgexErr != nil {
+		return encoderConfiguration{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	configResp, ok := respContent.(*media.GetVideoEncoderConfigurationResponse)
+	if !ok {
+		return encoderConfiguration{}, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetVideoEncoderConfigurationResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+	}
+
+	requestData, err = json.Marshal(media.GetVideoEncoderConfigurationOptions{ProfileToken: profile.Token, ConfigurationToken: token})
+	if err != nil {
+		return encoderConfiguration{}, errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the GetVideoEncoderConfigurationOptions request", err)
+	}
+	respContent, edgexErr = onvifClient.callOnvifFunction(onvif.MediaWebService, onvif.GetVideoEncoderConfigurationOptions, requestData)
+	if edgexErr != nil {
+		return encoderConfiguration{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	optionsResp, ok := respContent.(*media.GetVideoEncoderConfigurationOptionsResponse)
+	if !ok {
+		return encoderConfiguration{}, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetVideoEncoderConfigurationOptionsResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+	}
+
+	config := configResp.Configuration
+	return encoderConfiguration{
+		token:   string(token),
+		current: mediaEncoderSettings(config),
+		options: mediaEncoderOptions(optionsResp.Options),
+		set: func(settings EncoderTemplate) errors.EdgeX {
+			return onvifClient.setMediaEncoderConfiguration(config, settings)
+		},
+	}, nil
+}
+
+// setMediaEncoderConfiguration applies the settings to the Media video encoder configuration, keeping its other
+// values such as the multicast configuration
+func (onvifClient *OnvifClient) setMediaEncoderConfiguration(config xsdOnvif.VideoEncoderConfiguration, settings EncoderTemplate) errors.EdgeX {
+	encoding := xsdOnvif.VideoEncoding(settings.Encoding)
+	config.Encoding = &encoding
+	if settings.Width != 0 {
+		width, height := xsd.Int(settings.Width), xsd.Int(settings.Height)
+		config.Resolution = &xsdOnvif.VideoResolution{Width: &width, Height: &height}
+	}
+	config.Quality = settings.Quality
+	if settings.FrameRate != 0 || settings.Bitrate != 0 {
+		if config.RateControl == nil {
+			config.RateControl = &xsdOnvif.VideoRateControl{}
+		}
+		if settings.FrameRate != 0 {
+			// the frame rate limit of the Media service is an integer
+			frameRate := xsd.Int(math.Round(settings.FrameRate))
+			config.RateControl.FrameRateLimit = &frameRate
+		}
+		if settings.Bitrate != 0 {
+			bitrate := xsd.Int(settings.Bitrate)
+			config.RateControl.BitrateLimit = &bitrate
+		}
+	}
+	if settings.GovLength != 0 {
+		govLength := xsd.Int(settings.GovLength)
+		switch normalizeEncoding(settings.Encoding) {
+		case "H264":
+			if config.H264 == nil {
+				config.H264 = &xsdOnvif.H264Configuration{}
+			}
+			config.H264.GovLength = &govLength
+		case "MPEG4":
+			if config.MPEG4 == nil {
+				config.MPEG4 = &xsdOnvif.Mpeg4Configuration{}
+			}
+			config.MPEG4.GovLength = &govLength
+		}
+	}
+
+	// the json field names of the configuration are the same as the ones of the configuration of the request
+	requestData, err := json.Marshal(struct {
+		Configuration    xsdOnvif.VideoEncoderConfiguration
+		ForcePersistence bool
+	}{config, true})
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the SetVideoEncoderConfiguration request", err)
+	}
+	_, edgexErr := onvifClient.callOnvifFunction(onvif.MediaWebService, onvif.SetVideoEncoderConfiguration, requestData)
+	return edgexErr
+}
+
+// mediaEncoderSettings returns the settings of the Media video encoder configuration
+func mediaEncoderSettings(config xsdOnvif.VideoEncoderConfiguration) EncoderTemplate {
+	settings := EncoderTemplate{Quality: config.Quality}
+	if config.Encoding != nil {
+		settings.Encoding = string(*config.Encoding)
+	}
+	if config.Resolution != nil && config.Resolution.Width != nil && config.Resolution.Height != nil {
+		settings.Width, settings.Height = int(*config.Resolution.Width), int(*config.Resolution.Height)
+	}
+	if config.RateControl != nil {
+		if config.RateControl.FrameRateLimit != nil {
+			settings.FrameRate = float64(*config.RateControl.FrameRateLimit)
+		}
+		if config.RateControl.BitrateLimit != nil {
+			settings.Bitrate = int(*config.RateControl.BitrateLimit)
+		}
+	}
+	if config.H264 != nil && config.H264.GovLength != nil {
+		settings.GovLength = int(*config.H264.GovLength)
+	} else if config.MPEG4 != nil && config.MPEG4.GovLength != nil {
+		settings.GovLength = int(*config.MPEG4.GovLength)
+	}
+	return settings
+}
+
+// mediaEncoderOptions normalizes the options of the encodings of the Media video encoder configuration
+func mediaEncoderOptions(options xsdOnvif.VideoEncoderConfigurationOptions) []encoderOptions {
+	var quality *encoderRange
+	if options.QualityRange != nil {
+		quality = newEncoderRange(float64(options.QualityRange.Min), float64(options.QualityRange.Max))
+	}
+	intRange := func(r xsdOnvif.IntRange) *encoderRange {
+		return newEncoderRange(float64(r.Min), float64(r.Max))
+	}
+	resolutions := func(available ...xsdOnvif.VideoResolution) []encoderResolution {
+		var result []encoderResolution
+		for _, resolution := range available {
+			if resolution.Width != nil && resolution.Height != nil {
+				result = append(result, encoderResolution{Width: int(*resolution.Width), Height: int(*resolution.Height)})
+			}
+		}
+		return result
+	}
+
+	var result []encoderOptions
+	if options.JPEG != nil {
+		option := encoderOptions{Encoding: "JPEG", Resolutions: resolutions(options.JPEG.ResolutionsAvailable...),
+			FrameRateRange: intRange(options.JPEG.FrameRateRange), QualityRange: quality}
+		if options.Extension != nil && options.Extension.JPEG != nil {
+			option.BitrateRange = intRange(options.Extension.JPEG.BitrateRange)
+		}
+		result = append(result, option)
+	}
+	if options.MPEG4 != nil {
+		option := encoderOptions{Encoding: "MPEG4", Resolutions: resolutions(options.MPEG4.ResolutionsAvailable),
+			FrameRateRange: intRange(options.MPEG4.FrameRateRange), GovLengthRange: intRange(options.MPEG4.GovLengthRange), QualityRange: quality}
+		if options.Extension != nil && options.Extension.MPEG4 != nil {
+			option.BitrateRange = intRange(options.Extension.MPEG4.BitrateRange)
+		}
+		result = append(result, option)
+	}
+	if options.H264 != nil {
+		option := encoderOptions{Encoding: "H264", Resolutions: resolutions(options.H264.ResolutionsAvailable...),
+			FrameRateRange: intRange(options.H264.FrameRateRange), GovLengthRange: intRange(options.H264.GovLengthRange), QualityRange: quality}
+		if options.Extension != nil && options.Extension.H264 != nil {
+			option.BitrateRange = intRange(options.Extension.H264.BitrateRange)
+		}
+		result = append(result, option)
+	}
+	return result
+}
+
+// getMedia2EncoderConfiguration returns the Media2 video encoder configuration of the media profile
+func (onvifClient *OnvifClient) getMedia2EncoderConfiguration(profile xsdOnvif.Profile) (encoderConfiguration, errors.EdgeX) {
+	token := string(profile.VideoEncoderConfiguration.Token)
+	requestData, err := json.Marshal(media2GetVideoEncoderConfigurations{ConfigurationToken: token})
+	if err != nil {
+		return encoderConfiguration{}, errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the Media2 GetVideoEncoderConfigurations request", err)
+	}
+	respContent, edgexErr := onvifClient.callFunction(onvif.Media2WebService, onvif.GetVideoEncoderConfigurations, &media2GetVideoEncoderConfigurationsFunction{}, requestData)
+	if edgexErr != nil {
+		return encoderConfiguration{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	configResp, ok := respContent.(*media2GetVideoEncoderConfigurationsResponse)
+	if !ok {
+		return encoderConfiguration{}, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid Media2 GetVideoEncoderConfigurationsResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+	}
+	if len(configResp.Configurations) == 0 {
+		return encoderConfiguration{}, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("video encoder configuration with token '%s' not found", token), nil)
+	}
+
+	requestData, err = json.Marshal(media2GetVideoEncoderConfigurationOptions{ConfigurationToken: token, ProfileToken: string(profile.Token)})
+	if err != nil {
+		return encoderConfiguration{}, errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the Media2 GetVideoEncoderConfigurationOptions request", err)
+	}
+	respContent, edgexErr = onvifClient.callFunction(onvif.Media2WebService, onvif.GetVideoEncoderConfigurationOptions, &media2GetVideoEncoderConfigurationOptionsFunction{}, requestData)
+	if edgexErr != nil {
+		return encoderConfiguration{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	optionsResp, ok := respContent.(*media2GetVideoEncoderConfigurationOptionsResponse)
+	if !ok {
+		return encoderConfiguration{}, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid Media2 GetVideoEncoderConfigurationOptionsResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+	}
+
+	config := configResp.Configurations[0]
+	return encoderConfiguration{
+		token:   token,
+		current: media2EncoderSettings(config),
+		options: media2EncoderOptions(optionsResp.Options),
+		set: func(settings EncoderTemplate) errors.EdgeX {
+			return onvifClient.setMedia2EncoderConfiguration(config, settings)
+		},
+	}, nil
+}
+
+// setMedia2EncoderConfiguration applies the settings to the Media2 video encoder configuration, keeping its other
+// values such as the multicast configuration
+func (onvifClient *OnvifClient) setMedia2EncoderConfiguration(config media2VideoEncoderConfiguration, settings EncoderTemplate) errors.EdgeX {
+	request := config.request()
+	request.Encoding = settings.Encoding
+	if settings.Width != 0 {
+		request.Resolution = media2VideoResolutionRequest{Width: settings.Width, Height: settings.Height}
+	}
+	request.Quality = settings.Quality
+	if settings.FrameRate != 0 || settings.Bitrate != 0 {
+		if request.RateControl == nil {
+			request.RateControl = &media2VideoRateControlRequest{}
+		}
+		if settings.FrameRate != 0 {
+			request.RateControl.FrameRateLimit = settings.FrameRate
+		}
+		if settings.Bitrate != 0 {
+			request.RateControl.BitrateLimit = settings.Bitrate
+		}
+	}
+	request.GovLength = settings.GovLength
+	if normalizeEncoding(settings.Encoding) != normalizeEncoding(config.Encoding) {
+		// the encoder profile of the previous encoding is not valid for the new one
+		request.Profile = ""
+	}
+
+	requestData, err := json.Marshal(media2SetVideoEncoderConfiguration{Configuration: request})
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the Media2 SetVideoEncoderConfiguration request", err)
+	}
+	_, edgexErr := onvifClient.callFunction(onvif.Media2WebService, onvif.SetVideoEncoderConfiguration, &media2SetVideoEncoderConfigurationFunction{}, requestData)
+	return edgexErr
+}
+
+// media2EncoderSettings returns the settings of the Media2 video encoder configuration
+func media2EncoderSettings(config media2VideoEncoderConfiguration) EncoderTemplate {
+	settings := EncoderTemplate{
+		Encoding:  config.Encoding,
+		Width:     config.Resolution.Width,
+		Height:    config.Resolution.Height,
+		Quality:   config.Quality,
+		GovLength: config.GovLength,
+	}
+	if config.RateControl != nil {
+		settings.FrameRate = config.RateControl.FrameRateLimit
+		settings.Bitrate = config.RateControl.BitrateLimit
+	}
+	return settings
+}
+
+// media2EncoderOptions normalizes the options of the encodings of the Media2 video encoder configuration
+func media2EncoderOptions(options []media2VideoEncoderOptions) []encoderOptions {
+	result := make([]encoderOptions, 0, len(options))
+	for _, option := range options {
+		normalized := encoderOptions{
+			Encoding:     option.Encoding,
+			QualityRange: newEncoderRange(option.QualityRange.Min, option.QualityRange.Max),
+		}
+		for _, resolution := range option.ResolutionsAvailable {
+			normalized.Resolutions = append(normalized.Resolutions, encoderResolution{Width: resolution.Width, Height: resolution.Height})
+		}
+		for _, rate := range strings.Fields(option.FrameRatesSupported) {
+			if frameRate, err := strconv.ParseFloat(rate, 64); err == nil && frameRate > 0 {
+				normalized.FrameRates = append(normalized.FrameRates, frameRate)
+			}
+		}
+		if option.BitrateRange != nil {
+			normalized.BitrateRange = newEncoderRange(float64(option.BitrateRange.Min), float64(option.BitrateRange.Max))
+		}
+		if govLength := strings.Fields(option.GovLengthRange); len(govLength) == 2 {
+			min, minErr := strconv.Atoi(govLength[0])
+			max, maxErr := strconv.Atoi(govLength[1])
+			if minErr == nil && maxErr == nil {
+				normalized.GovLengthRange = newEncoderRange(float64(min), float64(max))
+			}
+		}
+		result = append(result, normalized)
+	}
+	return result
+}
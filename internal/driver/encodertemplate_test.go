@@ -0,0 +1,317 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edgexfoundry/device-onvif-camera/internal/driver/mocks"
+)
+
+const (
+	testEncoderProfilesResponse = `<GetProfilesResponse>` +
+		`<Profiles token="main"><Name>MainStream</Name><VideoEncoderConfiguration token="encoder1"><Name>encoder1</Name>` +
+		`<Resolution><Width>1280</Width><Height>720</Height></Resolution></VideoEncoderConfiguration></Profiles>` +
+		`</GetProfilesResponse>`
+	testEncoderConfigurationResponse = `<GetVideoEncoderConfigurationResponse><Configuration token="encoder1">` +
+		`<Name>encoder1</Name><UseCount>1</UseCount><Encoding>H264</Encoding>` +
+		`<Resolution><Width>1280</Width><Height>720</Height></Resolution><Quality>5</Quality>` +
+		`<RateControl><FrameRateLimit>25</FrameRateLimit><EncodingInterval>1</EncodingInterval><BitrateLimit>1024</BitrateLimit></RateControl>` +
+		`<H264><GovLength>50</GovLength><H264Profile>Main</H264Profile></H264>` +
+		`<Multicast><Address><Type>IPv4</Type><IPv4Address>239.0.0.1</IPv4Address></Address><Port>5000</Port><TTL>1</TTL><AutoStart>false</AutoStart></Multicast>` +
+		`<SessionTimeout>PT60S</SessionTimeout>` +
+		`</Configuration></GetVideoEncoderConfigurationResponse>`
+	testEncoderOptionsResponse = `<GetVideoEncoderConfigurationOptionsResponse><Options>` +
+		`<QualityRange><Min>1</Min><Max>10</Max></QualityRange>` +
+		`<H264><ResolutionsAvailable><Width>1920</Width><Height>1080</Height></ResolutionsAvailable>` +
+		`<ResolutionsAvailable><Width>1280</Width><Height>720</Height></ResolutionsAvailable>` +
+		`<GovLengthRange><Min>1</Min><Max>100</Max></GovLengthRange><FrameRateRange><Min>1</Min><Max>25</Max></FrameRateRange>` +
+		`<EncodingIntervalRange><Min>1</Min><Max>1</Max></EncodingIntervalRange><H264ProfilesSupported>Main</H264ProfilesSupported></H264>` +
+		`<Extension><H264><BitrateRange><Min>64</Min><Max>4096</Max></BitrateRange></H264></Extension>` +
+		`</Options></GetVideoEncoderConfigurationOptionsResponse>`
+	testMedia2EncoderConfigurationsResponse = `<GetVideoEncoderConfigurationsResponse>` +
+		`<Configurations token="encoder1" GovLength="30" Profile="Main"><Name>encoder1</Name><UseCount>1</UseCount>` +
+		`<Encoding>H265</Encoding><Resolution><Width>3840</Width><Height>2160</Height></Resolution>` +
+		`<RateControl ConstantBitRate="true"><FrameRateLimit>15</FrameRateLimit><BitrateLimit>8192</BitrateLimit></RateControl>` +
+		`<Quality>5</Quality></Configurations></GetVideoEncoderConfigurationsResponse>`
+	testMedia2EncoderOptionsResponse = `<GetVideoEncoderConfigurationOptionsResponse>` +
+		`<Options GovLengthRange="1 60" FrameRatesSupported="30 25 15 12.5 5" ProfilesSupported="Main"><Encoding>H265</Encoding>` +
+		`<QualityRange><Min>1</Min><Max>10</Max></QualityRange><ResolutionsAvailable><Width>3840</Width><Height>2160</Height></ResolutionsAvailable>` +
+		`<BitrateRange><Min>256</Min><Max>16384</Max></BitrateRange></Options>` +
+		`<Options GovLengthRange="1 60" FrameRatesSupported="30 25 15 12.5 5" ProfilesSupported="Main High"><Encoding>H264</Encoding>` +
+		`<QualityRange><Min>1</Min><Max>10</Max></QualityRange><ResolutionsAvailable><Width>1920</Width><Height>1080</Height></ResolutionsAvailable>` +
+		`<BitrateRange><Min>64</Min><Max>8192</Max></BitrateRange></Options>` +
+		`</GetVideoEncoderConfigurationOptionsResponse>`
+)
+
+// mockEncoderCamera sets up the mock device to respond to the video encoder requests of the Media service, or of the
+// Media2 service if media2 is true. The bodies of the SetVideoEncoderConfiguration requests are sent to the channel.
+func mockEncoderCamera(mockDevice *mocks.OnvifDevice, media2 bool, setRequests chan<- string) {
+	deviceEndpoint := ""
+	if media2 {
+		deviceEndpoint = "http://camera/onvif/device_service"
+	}
+	mockDevice.On("GetEndpointByRequestStruct", mock.Anything).Return("http://camera/onvif/media_service", nil)
+	mockDevice.On("GetEndpoint", "device").Return(deviceEndpoint)
+	mockDevice.On("GetEndpoint", "media2").Return("http://camera/onvif/media_service")
+	mockDevice.On("SendSoap", mock.Anything, mock.Anything).Return(func(_ string, body string) *http.Response {
+		var content string
+		switch {
+		case strings.Contains(body, "tds:GetServices"):
+			content = testServicesResponse
+		case strings.Contains(body, "tr2:GetProfiles"):
+			content = testMedia2ProfilesResponse
+		case strings.Contains(body, "GetProfiles"):
+			content = testEncoderProfilesResponse
+		case strings.Contains(body, "tr2:GetVideoEncoderConfigurationOptions"):
+			content = testMedia2EncoderOptionsResponse
+		case strings.Contains(body, "tr2:GetVideoEncoderConfigurations"):
+			content = testMedia2EncoderConfigurationsResponse
+		case strings.Contains(body, "GetVideoEncoderConfigurationOptions"):
+			content = testEncoderOptionsResponse
+		case strings.Contains(body, "GetVideoEncoderConfiguration"):
+			content = testEncoderConfigurationResponse
+		case strings.Contains(body, "SetVideoEncoderConfiguration"):
+			setRequests <- body
+			content = `<SetVideoEncoderConfigurationResponse></SetVideoEncoderConfigurationResponse>`
+		}
+		response := fmt.Sprintf(testRotationEnvelope, content)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(response))}
+	}, nil)
+	mockDevice.On("GetDeviceParams").Return(onvif.DeviceParams{Username: testRotationUser, Password: testRotationPassword})
+}
+
+func TestValidateEncoderTemplateRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		template      EncoderTemplate
+		devices       []string
+		allDevices    bool
+		errorExpected bool
+	}{
+		{name: "encoding only", template: EncoderTemplate{Encoding: "H264"}},
+		{name: "full template", template: EncoderTemplate{Encoding: "H264", Width: 1920, Height: 1080, FrameRate: 15, Bitrate: 2048, GovLength: 30, Quality: 5}},
+		{name: "missing encoding", template: EncoderTemplate{Width: 1920, Height: 1080}, errorExpected: true},
+		{name: "missing height", template: EncoderTemplate{Encoding: "H264", Width: 1920}, errorExpected: true},
+		{name: "negative bitrate", template: EncoderTemplate{Encoding: "H264", Bitrate: -1}, errorExpected: true},
+		{name: "all devices", template: EncoderTemplate{Encoding: "H264"}, allDevices: true},
+		{name: "no devices", template: EncoderTemplate{Encoding: "H264"}, devices: []string{}, errorExpected: true},
+		{name: "devices and all devices", template: EncoderTemplate{Encoding: "H264"}, devices: []string{testDeviceName}, allDevices: true, errorExpected: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			request := EncoderTemplateRequest{Template: test.template, Devices: test.devices, AllDevices: test.allDevices}
+			if test.devices == nil && !test.allDevices {
+				request.Devices = []string{testDeviceName}
+			}
+			err := validateEncoderTemplateRequest(request)
+			if test.errorExpected {
+				require.Error(t, err)
+				assert.Equal(t, errors.KindContractInvalid, errors.Kind(err))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestFitEncoderTemplate(t *testing.T) {
+	options := []encoderOptions{
+		{
+			Encoding:       "H264",
+			Resolutions:    []encoderResolution{{1920, 1080}, {1280, 720}, {640, 360}},
+			FrameRateRange: &encoderRange{Min: 1, Max: 25},
+			BitrateRange:   &encoderRange{Min: 64, Max: 4096},
+			GovLengthRange: &encoderRange{Min: 1, Max: 100},
+			QualityRange:   &encoderRange{Min: 1, Max: 10},
+		},
+		{
+			Encoding:    "MPV4-ES",
+			Resolutions: []encoderResolution{{640, 360}},
+			FrameRates:  []float64{30, 15, 7.5},
+		},
+	}
+	current := EncoderTemplate{Encoding: "H264", Width: 1280, Height: 720, FrameRate: 25, Bitrate: 1024, GovLength: 50, Quality: 5}
+
+	tests := []struct {
+		name                string
+		template            EncoderTemplate
+		strict              bool
+		expected            EncoderTemplate
+		expectedAdjustments int
+		errorExpected       bool
+	}{
+		{
+			name:     "supported template",
+			template: EncoderTemplate{Encoding: "h264", Width: 1920, Height: 1080, FrameRate: 15, Bitrate: 2048},
+			expected: EncoderTemplate{Encoding: "H264", Width: 1920, Height: 1080, FrameRate: 15, Bitrate: 2048, GovLength: 50, Quality: 5},
+		},
+		{
+			name:                "clamped template",
+			template:            EncoderTemplate{Encoding: "H264", Width: 3840, Height: 2160, FrameRate: 30, Bitrate: 8192, GovLength: 200},
+			expected:            EncoderTemplate{Encoding: "H264", Width: 1920, Height: 1080, FrameRate: 25, Bitrate: 4096, GovLength: 100, Quality: 5},
+			expectedAdjustments: 4,
+		},
+		{
+			name:     "smallest resolution",
+			template: EncoderTemplate{Encoding: "H264", Width: 320, Height: 240},
+			expected: EncoderTemplate{Encoding: "H264", Width: 640, Height: 360, FrameRate: 25, Bitrate: 1024, GovLength: 50, Quality: 5},
+			// the resolution is adjusted
+			expectedAdjustments: 1,
+		},
+		{
+			name:          "strict template",
+			template:      EncoderTemplate{Encoding: "H264", Bitrate: 8192},
+			strict:        true,
+			errorExpected: true,
+		},
+		{
+			name:     "strict supported template",
+			template: EncoderTemplate{Encoding: "H264", Bitrate: 2048},
+			strict:   true,
+			expected: EncoderTemplate{Encoding: "H264", Width: 1280, Height: 720, FrameRate: 25, Bitrate: 2048, GovLength: 50, Quality: 5},
+		},
+		{
+			name:     "strict current values adjusted to the new encoding",
+			template: EncoderTemplate{Encoding: "MPEG4"},
+			strict:   true,
+			// the current resolution and frame rate are not part of the template, so they are adjusted
+			expected:            EncoderTemplate{Encoding: "MPV4-ES", Width: 640, Height: 360, FrameRate: 15, Bitrate: 1024, GovLength: 50, Quality: 5},
+			expectedAdjustments: 2,
+		},
+		{
+			name:                "frame rates",
+			template:            EncoderTemplate{Encoding: "MPEG4", FrameRate: 10},
+			expected:            EncoderTemplate{Encoding: "MPV4-ES", Width: 640, Height: 360, FrameRate: 7.5, Bitrate: 1024, GovLength: 50, Quality: 5},
+			expectedAdjustments: 2,
+		},
+		{
+			name:          "strict frame rates",
+			template:      EncoderTemplate{Encoding: "MPEG4", FrameRate: 10},
+			strict:        true,
+			errorExpected: true,
+		},
+		{
+			name:          "unsupported encoding",
+			template:      EncoderTemplate{Encoding: "H265"},
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			settings, adjustments, err := fitEncoderTemplate(test.template, current, options, test.strict)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, settings)
+			assert.Len(t, adjustments, test.expectedAdjustments)
+		})
+	}
+}
+
+func TestOnvifClient_applyEncoderTemplate(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	setRequests := make(chan string, 1)
+	mockEncoderCamera(mockDevice, false, setRequests)
+
+	template := EncoderTemplate{Encoding: "H264", Width: 3840, Height: 2160, FrameRate: 15, Bitrate: 2048}
+	result := onvifClient.applyEncoderTemplate(EncoderTemplateRequest{Template: template, DryRun: true})
+	require.Equal(t, EncoderTemplateValidated, result.Status, result.Error)
+	assert.Equal(t, onvif.MediaWebService, result.MediaService)
+	assert.Equal(t, "main", result.ProfileToken)
+	assert.Equal(t, "encoder1", result.ConfigurationToken)
+	assert.Equal(t, &EncoderTemplate{Encoding: "H264", Width: 1920, Height: 1080, FrameRate: 15, Bitrate: 2048, GovLength: 50, Quality: 5}, result.Configuration)
+	assert.Len(t, result.Adjustments, 1)
+	assert.Empty(t, setRequests)
+
+	result = onvifClient.applyEncoderTemplate(EncoderTemplateRequest{Template: template})
+	require.Equal(t, EncoderTemplateApplied, result.Status, result.Error)
+	body := <-setRequests
+	assert.Contains(t, body, `<trt:Configuration token="encoder1">`)
+	assert.Contains(t, body, `<onvif:Resolution><onvif:Width>1920</onvif:Width><onvif:Height>1080</onvif:Height></onvif:Resolution>`)
+	assert.Contains(t, body, `<onvif:FrameRateLimit>15</onvif:FrameRateLimit>`)
+	assert.Contains(t, body, `<onvif:BitrateLimit>2048</onvif:BitrateLimit>`)
+	assert.Contains(t, body, `<onvif:GovLength>50</onvif:GovLength>`)
+	// the settings which are not part of the template are kept
+	assert.Contains(t, body, `<onvif:IPv4Address>239.0.0.1</onvif:IPv4Address>`)
+	assert.Contains(t, body, `<trt:ForcePersistence>true</trt:ForcePersistence>`)
+
+	result = onvifClient.applyEncoderTemplate(EncoderTemplateRequest{Template: template, Strict: true})
+	assert.Equal(t, EncoderTemplateRejected, result.Status)
+	assert.NotEmpty(t, result.Error)
+
+	result = onvifClient.applyEncoderTemplate(EncoderTemplateRequest{Template: template, ProfileToken: "sub"})
+	assert.Equal(t, EncoderTemplateFailed, result.Status)
+	assert.NotEmpty(t, result.Error)
+}
+
+func TestOnvifClient_applyEncoderTemplate_media2(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	setRequests := make(chan string, 1)
+	mockEncoderCamera(mockDevice, true, setRequests)
+
+	result := onvifClient.applyEncoderTemplate(EncoderTemplateRequest{Template: EncoderTemplate{Encoding: "H264", Width: 1920, Height: 1080, FrameRate: 15, Bitrate: 2048}})
+	require.Equal(t, EncoderTemplateApplied, result.Status, result.Error)
+	assert.Equal(t, onvif.Media2WebService, result.MediaService)
+	assert.Equal(t, &EncoderTemplate{Encoding: "H264", Width: 1920, Height: 1080, FrameRate: 15, Bitrate: 2048, GovLength: 30, Quality: 5}, result.Configuration)
+	assert.Empty(t, result.Adjustments)
+
+	body := <-setRequests
+	// the encoder profile of H265 is not kept for H264
+	assert.Contains(t, body, `<tr2:Configuration token="encoder1" GovLength="30">`)
+	assert.Contains(t, body, `<onvif:Encoding>H264</onvif:Encoding>`)
+	assert.Contains(t, body, `<onvif:Resolution><onvif:Width>1920</onvif:Width><onvif:Height>1080</onvif:Height></onvif:Resolution>`)
+	assert.Contains(t, body, `<onvif:RateControl ConstantBitRate="true"><onvif:FrameRateLimit>15</onvif:FrameRateLimit><onvif:BitrateLimit>2048</onvif:BitrateLimit></onvif:RateControl>`)
+}
+
+func TestDriver_applyEncoderTemplate(t *testing.T) {
+	driver, mockService := createDriverWithMockService()
+	driver.clientsMu = new(sync.RWMutex)
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	setRequests := make(chan string, 1)
+	mockEncoderCamera(mockDevice, false, setRequests)
+	driver.onvifClients = map[string]*OnvifClient{testDeviceName: onvifClient}
+	mockService.On("GetDeviceByName", "unknown").Return(models.Device{}, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, "not found", nil))
+
+	results, err := driver.applyEncoderTemplate(EncoderTemplateRequest{
+		Template: EncoderTemplate{Encoding: "H264", Bitrate: 2048},
+		Devices:  []string{"unknown", testDeviceName},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "unknown", results[0].Device)
+	assert.Equal(t, EncoderTemplateFailed, results[0].Status)
+	assert.Equal(t, testDeviceName, results[1].Device)
+	assert.Equal(t, EncoderTemplateApplied, results[1].Status)
+	<-setRequests
+
+	// a request without devices does not configure every device
+	_, err = driver.applyEncoderTemplate(EncoderTemplateRequest{Template: EncoderTemplate{Encoding: "H264"}})
+	require.Error(t, err)
+	assert.Equal(t, errors.KindContractInvalid, errors.Kind(err))
+	assert.Empty(t, setRequests)
+}
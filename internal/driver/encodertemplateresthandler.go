@@ -0,0 +1,78 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+)
+
+const (
+	EncoderTemplateRestPath = "encodertemplate"
+	apiEncoderTemplateRoute = common.ApiBase + "/" + EncoderTemplateRestPath
+)
+
+// EncoderTemplateRestHandler exposes the bulk configuration of the video encoders of the cameras
+type EncoderTemplateRestHandler struct {
+	driver *Driver
+}
+
+// NewEncoderTemplateRestHandler creates a new EncoderTemplateRestHandler entity
+func NewEncoderTemplateRestHandler(driver *Driver) *EncoderTemplateRestHandler {
+	return &EncoderTemplateRestHandler{
+		driver: driver,
+	}
+}
+
+// AddRoutes adds the route for applying an encoder template to many cameras
+func (handler EncoderTemplateRestHandler) AddRoutes() errors.EdgeX {
+	if err := handler.driver.sdkService.AddRoute(apiEncoderTemplateRoute, handler.applyEncoderTemplate, http.MethodPost); err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("unable to add required route: %s: %s", apiEncoderTemplateRoute, err.Error()), err)
+	}
+	handler.driver.lc.Infof("Route %s %s added.", http.MethodPost, apiEncoderTemplateRoute)
+
+	return nil
+}
+
+// applyEncoderTemplate applies the encoder template of the request to the cameras, and returns the result of every
+// camera. The request only fails when the template is invalid or there are no cameras to configure.
+func (handler EncoderTemplateRestHandler) applyEncoderTemplate(writer http.ResponseWriter, request *http.Request) {
+	defer request.Body.Close()
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req EncoderTemplateRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		http.Error(writer, fmt.Sprintf("Unable to parse the encoder template request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	results, edgexErr := handler.driver.applyEncoderTemplate(req)
+	if edgexErr != nil {
+		http.Error(writer, edgexErr.Error(), edgexErr.Code())
+		return
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		handler.driver.lc.Errorf("Failed to marshal the encoder template results: %s", err.Error())
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set(common.ContentType, common.ContentTypeJSON)
+	if _, err = writer.Write(data); err != nil {
+		handler.driver.lc.Errorf("Failed to write the encoder template results: %s", err.Error())
+	}
+}
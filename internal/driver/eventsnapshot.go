@@ -0,0 +1,136 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"sync"
+
+	sdkModel "github.com/edgexfoundry/device-sdk-go/v2/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+)
+
+// newSnapshotCommandValue creates the Binary commandValue of the snapshot, tagged with its media types
+func newSnapshotCommandValue(resourceName string, snapshot snapshotImage) (*sdkModel.CommandValue, errors.EdgeX) {
+	cv, err := sdkModel.NewCommandValue(resourceName, common.ValueTypeBinary, snapshot.data)
+	if err != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create the snapshot commandValue for resource '%s'", resourceName), err)
+	}
+	cv.Tags[SnapshotMediaType] = snapshot.mediaType
+	cv.Tags[SnapshotOriginalMediaType] = snapshot.originalMediaType
+	return cv, nil
+}
+
+// eventPublisher publishes the camera events of a device in the order they are received, while capturing at most one
+// event snapshot of the camera at a time
+type eventPublisher struct {
+	mutex sync.Mutex
+	// capturing indicates a snapshot is being captured, the events received meanwhile wait in the pending events
+	capturing bool
+	pending   []pendingCameraEvent
+}
+
+// pendingCameraEvent is a camera event waiting for the snapshot being captured
+type pendingCameraEvent struct {
+	cv              *sdkModel.CommandValue
+	snapshotOnEvent bool
+	parameters      string
+}
+
+// publishCameraEvent sends the camera event to the async channel. When the subscription of the event enables
+// SnapshotOnEvent, the event is sent along with a snapshot of the camera captured with the SnapshotParameters.
+// The snapshot is captured in the background, since the event pull still holds its request slot of the camera when the
+// event is published. A single snapshot is captured at a time, it is attached to the events received meanwhile, which
+// are published after it so the events keep their order.
+func (onvifClient *OnvifClient) publishCameraEvent(cv *sdkModel.CommandValue, request *SubscriptionRequest) {
+	event := pendingCameraEvent{cv: cv}
+	if request != nil && request.SnapshotOnEvent != nil && *request.SnapshotOnEvent {
+		event.snapshotOnEvent = true
+		if request.SnapshotParameters != nil {
+			event.parameters = *request.SnapshotParameters
+		}
+	}
+
+	publisher := &onvifClient.eventPublisher
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+	if publisher.capturing {
+		publisher.pending = append(publisher.pending, event)
+		return
+	}
+	if !event.snapshotOnEvent {
+		onvifClient.sendCameraEvent(event.cv)
+		return
+	}
+	publisher.capturing = true
+	publisher.pending = []pendingCameraEvent{event}
+	go onvifClient.publishPendingCameraEvents(event.parameters)
+}
+
+// publishPendingCameraEvents captures the snapshot with the parameters, and publishes the pending events with it
+func (onvifClient *OnvifClient) publishPendingCameraEvents(parameters string) {
+	snapshotCv, edgexErr := onvifClient.captureEventSnapshot(parameters)
+	if edgexErr != nil {
+		// the events are still published, without the snapshot
+		onvifClient.lc.Warnf("Unable to capture the snapshot of the event of device %s: %s", onvifClient.DeviceName, edgexErr.Error())
+	}
+
+	publisher := &onvifClient.eventPublisher
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+	for _, event := range publisher.pending {
+		switch {
+		case !event.snapshotOnEvent || snapshotCv == nil:
+			onvifClient.sendCameraEvent(event.cv)
+		case event.parameters != parameters:
+			onvifClient.lc.Debugf("Publishing the event of device %s without a snapshot, since the snapshot was captured with other parameters", onvifClient.DeviceName)
+			onvifClient.sendCameraEvent(event.cv)
+		default:
+			onvifClient.sendCameraEvent(event.cv, snapshotCv)
+		}
+	}
+	publisher.pending = nil
+	publisher.capturing = false
+}
+
+// sendCameraEvent sends the commandValues of the camera event to the async channel
+func (onvifClient *OnvifClient) sendCameraEvent(commandValues ...*sdkModel.CommandValue) {
+	onvifClient.driver.asynchCh <- &sdkModel.AsyncValues{
+		DeviceName:    onvifClient.DeviceName,
+		CommandValues: commandValues,
+	}
+}
+
+// captureEventSnapshot captures the snapshot of the camera, and returns it as the commandValue of the device resource
+// with the GetSnapshot get function
+func (onvifClient *OnvifClient) captureEventSnapshot(parameters string) (*sdkModel.CommandValue, errors.EdgeX) {
+	resource, edgexErr := onvifClient.driver.getDeviceResourceByGetFunction(onvifClient.DeviceName, GetSnapshot)
+	if edgexErr != nil {
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	snapshot, edgexErr := onvifClient.callGetSnapshotFunction([]byte(parameters))
+	if edgexErr != nil {
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	return newSnapshotCommandValue(resource.Name, snapshot)
+}
+
+// publishBaseNotification publishes the camera event received for the BaseNotification subscription of the device,
+// and returns false if the subscription is unknown
+func (d *Driver) publishBaseNotification(deviceName string, subscriptionName string, cv *sdkModel.CommandValue) bool {
+	onvifClient, edgexErr := d.getOnvifClient(deviceName)
+	if edgexErr != nil || onvifClient.baseNotificationManager == nil {
+		return false
+	}
+	consumer, ok := onvifClient.baseNotificationManager.consumer(subscriptionName)
+	if !ok {
+		return false
+	}
+	onvifClient.publishCameraEvent(cv, consumer.subscriptionRequest)
+	return true
+}
@@ -0,0 +1,165 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sdkModel "github.com/edgexfoundry/device-sdk-go/v2/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnvifClient_publishCameraEvent(t *testing.T) {
+	enabled, disabled := true, false
+	parameters := `{"ProfileName": "SubStream"}`
+
+	tests := []struct {
+		name             string
+		request          *SubscriptionRequest
+		status           int
+		expectedSnapshot string
+	}{
+		{
+			name:    "snapshot not configured",
+			request: &SubscriptionRequest{},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "snapshot disabled",
+			request: &SubscriptionRequest{SnapshotOnEvent: &disabled},
+			status:  http.StatusOK,
+		},
+		{
+			name:             "snapshot enabled",
+			request:          &SubscriptionRequest{SnapshotOnEvent: &enabled, SnapshotParameters: &parameters},
+			status:           http.StatusOK,
+			expectedSnapshot: "sub image",
+		},
+		{
+			name:    "event is published without the failed snapshot",
+			request: &SubscriptionRequest{SnapshotOnEvent: &enabled},
+			status:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			driver, mockService := createDriverWithMockService()
+			asyncCh := make(chan *sdkModel.AsyncValues, 1)
+			driver.asynchCh = asyncCh
+			onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+			mockSnapshotCamera(mockDevice, newSnapshotServer(t, test.status))
+			mockService.On("GetDeviceByName", testDeviceName).Return(models.Device{Name: testDeviceName, ProfileName: "onvif-camera"}, nil)
+			mockService.On("GetProfileByName", "onvif-camera").Return(models.DeviceProfile{DeviceResources: []models.DeviceResource{
+				{Name: "Snapshot", Attributes: map[string]interface{}{Service: EdgeXWebService, GetFunction: GetSnapshot}},
+			}}, nil)
+
+			cv, err := sdkModel.NewCommandValue("CameraEvent", common.ValueTypeObject, "event")
+			require.NoError(t, err)
+			onvifClient.publishCameraEvent(cv, test.request)
+
+			var asyncValues *sdkModel.AsyncValues
+			select {
+			case asyncValues = <-asyncCh:
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "the camera event was not published")
+			}
+			assert.Equal(t, testDeviceName, asyncValues.DeviceName)
+			assert.Equal(t, cv, asyncValues.CommandValues[0])
+			if test.expectedSnapshot == "" {
+				require.Len(t, asyncValues.CommandValues, 1)
+				return
+			}
+			require.Len(t, asyncValues.CommandValues, 2)
+			snapshotCv := asyncValues.CommandValues[1]
+			assert.Equal(t, "Snapshot", snapshotCv.DeviceResourceName)
+			assert.Equal(t, common.ValueTypeBinary, snapshotCv.Type)
+			assert.Equal(t, []byte(test.expectedSnapshot), snapshotCv.Value)
+			assert.Equal(t, "text/plain", snapshotCv.Tags[SnapshotMediaType])
+		})
+	}
+}
+
+func TestOnvifClient_publishCameraEvent_coalescedSnapshots(t *testing.T) {
+	enabled := true
+	parameters := `{"ProfileName": "SubStream"}`
+	otherParameters := `{"ProfileName": "MainStream"}`
+
+	var captures int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&captures, 1)
+		<-release
+		_, _ = w.Write([]byte(path.Base(r.URL.Path) + " image"))
+	}))
+	t.Cleanup(server.Close)
+
+	driver, mockService := createDriverWithMockService()
+	asyncCh := make(chan *sdkModel.AsyncValues, 4)
+	driver.asynchCh = asyncCh
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	mockSnapshotCamera(mockDevice, server)
+	mockService.On("GetDeviceByName", testDeviceName).Return(models.Device{Name: testDeviceName, ProfileName: "onvif-camera"}, nil)
+	mockService.On("GetProfileByName", "onvif-camera").Return(models.DeviceProfile{DeviceResources: []models.DeviceResource{
+		{Name: "Snapshot", Attributes: map[string]interface{}{Service: EdgeXWebService, GetFunction: GetSnapshot}},
+	}}, nil)
+
+	requests := []*SubscriptionRequest{
+		{SnapshotOnEvent: &enabled, SnapshotParameters: &parameters},
+		{},
+		{SnapshotOnEvent: &enabled, SnapshotParameters: &parameters},
+		{SnapshotOnEvent: &enabled, SnapshotParameters: &otherParameters},
+	}
+	var cvs []*sdkModel.CommandValue
+	for i, request := range requests {
+		cv, err := sdkModel.NewCommandValue("CameraEvent", common.ValueTypeObject, i)
+		require.NoError(t, err)
+		cvs = append(cvs, cv)
+		onvifClient.publishCameraEvent(cv, request)
+	}
+
+	// the events wait for the snapshot being captured, and no other snapshot is captured meanwhile
+	assert.Empty(t, asyncCh)
+	close(release)
+
+	expectedSnapshots := []bool{true, false, true, false}
+	for i, expectedSnapshot := range expectedSnapshots {
+		var asyncValues *sdkModel.AsyncValues
+		select {
+		case asyncValues = <-asyncCh:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "the camera event was not published")
+		}
+		assert.Equal(t, cvs[i], asyncValues.CommandValues[0])
+		if !expectedSnapshot {
+			assert.Len(t, asyncValues.CommandValues, 1)
+			continue
+		}
+		require.Len(t, asyncValues.CommandValues, 2)
+		assert.Equal(t, []byte("sub image"), asyncValues.CommandValues[1].Value)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&captures))
+
+	// the next event captures a new snapshot
+	onvifClient.publishCameraEvent(cvs[0], requests[0])
+	select {
+	case asyncValues := <-asyncCh:
+		assert.Len(t, asyncValues.CommandValues, 2)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the camera event was not published")
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&captures))
+}
@@ -0,0 +1,185 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+const (
+	httpAuthSchemeBasic  = "basic"
+	httpAuthSchemeDigest = "digest"
+)
+
+// httpAuthChallenge is a challenge of the WWW-Authenticate header of a 401 response
+type httpAuthChallenge struct {
+	// scheme is the lower case authentication scheme
+	scheme string
+	params map[string]string
+}
+
+// parseAuthChallenges parses the challenges of the WWW-Authenticate headers. Each header may contain multiple
+// challenges, and the parameter values may be quoted strings containing commas.
+func parseAuthChallenges(headers []string) []httpAuthChallenge {
+	var challenges []httpAuthChallenge
+	for _, header := range headers {
+		var current *httpAuthChallenge
+		for _, part := range splitAuthHeader(header) {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			// a new challenge starts with the scheme, optionally followed by its first parameter
+			if scheme, rest, found := strings.Cut(part, " "); !strings.Contains(scheme, "=") && (found || !strings.Contains(part, "=")) {
+				challenges = append(challenges, httpAuthChallenge{scheme: strings.ToLower(scheme), params: make(map[string]string)})
+				current = &challenges[len(challenges)-1]
+				part = strings.TrimSpace(rest)
+				if part == "" {
+					continue
+				}
+			}
+			if current == nil {
+				continue
+			}
+			key, value, found := strings.Cut(part, "=")
+			if !found {
+				continue
+			}
+			current.params[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return challenges
+}
+
+// splitAuthHeader splits the header on the commas which are not part of a quoted string
+func splitAuthHeader(header string) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, c := range header {
+		switch {
+		case c == '"' && (i == 0 || header[i-1] != '\\'):
+			quoted = !quoted
+		case c == ',' && !quoted:
+			parts = append(parts, header[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, header[start:])
+}
+
+// selectAuthChallenge returns the strongest supported challenge, preferring digest over basic
+func selectAuthChallenge(challenges []httpAuthChallenge) (httpAuthChallenge, bool) {
+	var basic *httpAuthChallenge
+	for i, challenge := range challenges {
+		switch challenge.scheme {
+		case httpAuthSchemeDigest:
+			if _, supported := digestHashFunc(challenge.params["algorithm"]); supported {
+				return challenge, true
+			}
+		case httpAuthSchemeBasic:
+			if basic == nil {
+				basic = &challenges[i]
+			}
+		}
+	}
+	if basic != nil {
+		return *basic, true
+	}
+	return httpAuthChallenge{}, false
+}
+
+// authorize sets the Authorization header of the request answering the challenge
+func (challenge httpAuthChallenge) authorize(request *http.Request, username, password string) error {
+	switch challenge.scheme {
+	case httpAuthSchemeBasic:
+		request.SetBasicAuth(username, password)
+		return nil
+	case httpAuthSchemeDigest:
+		authorization, err := challenge.digestAuthorization(request.Method, request.URL.RequestURI(), username, password)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Authorization", authorization)
+		return nil
+	}
+	return fmt.Errorf("unsupported authentication scheme '%s'", challenge.scheme)
+}
+
+// digestHashFunc returns the hash function of the digest algorithm, as defined by RFC 7616
+func digestHashFunc(algorithm string) (func() hash.Hash, bool) {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(algorithm), "-sess")) {
+	case "", "MD5":
+		return md5.New, true
+	case "SHA-256":
+		return sha256.New, true
+	}
+	return nil, false
+}
+
+// digestAuthorization returns the Authorization header value of the digest challenge, as defined by RFC 7616
+func (challenge httpAuthChallenge) digestAuthorization(method, uri, username, password string) (string, error) {
+	params := challenge.params
+	algorithm := params["algorithm"]
+	hashFunc, supported := digestHashFunc(algorithm)
+	if !supported {
+		return "", fmt.Errorf("unsupported digest algorithm '%s'", algorithm)
+	}
+	digest := func(values ...string) string {
+		h := hashFunc()
+		h.Write([]byte(strings.Join(values, ":")))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := base64.RawURLEncoding.EncodeToString(cnonceBytes)
+	nonceCount := "00000001"
+
+	ha1 := digest(username, params["realm"], password)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = digest(ha1, params["nonce"], cnonce)
+	}
+	ha2 := digest(method, uri)
+
+	qop := ""
+	for _, option := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(option) == "auth" {
+			qop = "auth"
+		}
+	}
+	var response string
+	if qop != "" {
+		response = digest(ha1, params["nonce"], nonceCount, cnonce, qop, ha2)
+	} else {
+		// RFC 2069 compatibility, for cameras which do not send the qop
+		response = digest(ha1, params["nonce"], ha2)
+	}
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		username, params["realm"], params["nonce"], uri, response)
+	if algorithm != "" {
+		authorization += fmt.Sprintf(`, algorithm=%s`, algorithm)
+	}
+	if qop != "" {
+		authorization += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nonceCount, cnonce)
+	}
+	if opaque, found := params["opaque"]; found {
+		authorization += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+	return authorization, nil
+}
@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testDigestRealm = "Camera"
+	testDigestNonce = "5f2b9c1e"
+)
+
+func md5Hex(values ...string) string {
+	sum := md5.Sum([]byte(strings.Join(values, ":")))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyDigest verifies the digest Authorization header of the request, as a camera would
+func verifyDigest(r *http.Request, username, password string) bool {
+	challenges := parseAuthChallenges([]string{r.Header.Get("Authorization")})
+	if len(challenges) != 1 || challenges[0].scheme != httpAuthSchemeDigest {
+		return false
+	}
+	params := challenges[0].params
+	if params["username"] != username || params["uri"] != r.URL.RequestURI() || params["nonce"] != testDigestNonce {
+		return false
+	}
+	ha1 := md5Hex(username, testDigestRealm, password)
+	if params["algorithm"] == "MD5-sess" {
+		ha1 = md5Hex(ha1, params["nonce"], params["cnonce"])
+	}
+	ha2 := md5Hex(r.Method, params["uri"])
+	expected := md5Hex(ha1, params["nonce"], params["nc"], params["cnonce"], params["qop"], ha2)
+	return params["response"] == expected
+}
+
+func TestParseAuthChallenges(t *testing.T) {
+	challenges := parseAuthChallenges([]string{
+		`Digest realm="Camera, Inc", qop="auth,auth-int", nonce="abc", opaque="xyz", Basic realm="Camera"`,
+		`Negotiate`,
+	})
+	require.Len(t, challenges, 3)
+	assert.Equal(t, httpAuthSchemeDigest, challenges[0].scheme)
+	assert.Equal(t, map[string]string{"realm": "Camera, Inc", "qop": "auth,auth-int", "nonce": "abc", "opaque": "xyz"}, challenges[0].params)
+	assert.Equal(t, httpAuthSchemeBasic, challenges[1].scheme)
+	assert.Equal(t, "Camera", challenges[1].params["realm"])
+	assert.Equal(t, "negotiate", challenges[2].scheme)
+
+	challenge, found := selectAuthChallenge(challenges)
+	require.True(t, found)
+	assert.Equal(t, httpAuthSchemeDigest, challenge.scheme)
+
+	challenge, found = selectAuthChallenge(parseAuthChallenges([]string{`Digest realm="a", algorithm=SHA-512-256, nonce="b"`, `Basic realm="a"`}))
+	require.True(t, found)
+	assert.Equal(t, httpAuthSchemeBasic, challenge.scheme)
+
+	_, found = selectAuthChallenge(parseAuthChallenges([]string{`Negotiate`}))
+	assert.False(t, found)
+}
+
+func TestOnvifClient_downloadSnapshot_authentication(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		password  string
+		authorize func(r *http.Request) bool
+		success   bool
+	}{
+		{
+			name:      "digest",
+			challenge: fmt.Sprintf(`Digest realm="%s", qop="auth,auth-int", nonce="%s", opaque="abc"`, testDigestRealm, testDigestNonce),
+			password:  testRotationPassword,
+			authorize: func(r *http.Request) bool {
+				return verifyDigest(r, testRotationUser, testRotationPassword) && strings.Contains(r.Header.Get("Authorization"), `opaque="abc"`)
+			},
+			success: true,
+		},
+		{
+			name:      "digest MD5-sess",
+			challenge: fmt.Sprintf(`Digest realm="%s", qop="auth", nonce="%s", algorithm=MD5-sess`, testDigestRealm, testDigestNonce),
+			password:  testRotationPassword,
+			authorize: func(r *http.Request) bool { return verifyDigest(r, testRotationUser, testRotationPassword) },
+			success:   true,
+		},
+		{
+			name:      "basic",
+			challenge: fmt.Sprintf(`Basic realm="%s"`, testDigestRealm),
+			password:  testRotationPassword,
+			authorize: func(r *http.Request) bool {
+				username, password, ok := r.BasicAuth()
+				return ok && username == testRotationUser && password == testRotationPassword
+			},
+			success: true,
+		},
+		{
+			name:      "wrong password",
+			challenge: fmt.Sprintf(`Digest realm="%s", qop="auth", nonce="%s"`, testDigestRealm, testDigestNonce),
+			password:  "wrong",
+			authorize: func(r *http.Request) bool { return verifyDigest(r, testRotationUser, testRotationPassword) },
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				if !test.authorize(r) {
+					w.Header().Set("WWW-Authenticate", test.challenge)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte("image"))
+			}))
+			defer server.Close()
+
+			driver, _ := createDriverWithMockService()
+			onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+			mockDevice.On("GetDeviceParams").Return(onvif.DeviceParams{
+				Username:   testRotationUser,
+				Password:   test.password,
+				HttpClient: server.Client(),
+				AuthMode:   AuthModeUsernameToken,
+			})
+
+			image, _, err := onvifClient.downloadSnapshot(server.URL + "/snapshot?channel=1")
+			// the request is retried only once with the scheme of the challenge
+			assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+			if !test.success {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "image", string(image))
+		})
+	}
+}
@@ -72,10 +72,7 @@ func (m *MACAddressMapper) TryGetSecretPathForMACAddress(mac string, defaultSecr
 		return noAuthSecretPath
 	}
 
-	m.credsMu.RLock()
-	defer m.credsMu.RUnlock()
-
-	secretPath, found := m.credsMap[sanitized]
+	secretPath, found := m.lookupSecretPath(sanitized)
 	if !found {
 		m.sdkService.GetLoggingClient().Debugf("No credential mapping exists for mac address '%s', will use default secret path.", mac)
 		return defaultSecretPath
@@ -84,6 +81,24 @@ func (m *MACAddressMapper) TryGetSecretPathForMACAddress(mac string, defaultSecr
 	return secretPath
 }
 
+// LookupSecretPathForMACAddress returns the secret path associated with the mac address passed, and whether
+// a mapping exists. Invalid mac addresses are never mapped.
+func (m *MACAddressMapper) LookupSecretPathForMACAddress(mac string) (string, bool) {
+	sanitized, err := SanitizeMACAddress(mac)
+	if err != nil {
+		return "", false
+	}
+	return m.lookupSecretPath(sanitized)
+}
+
+func (m *MACAddressMapper) lookupSecretPath(sanitizedMAC string) (string, bool) {
+	m.credsMu.RLock()
+	defer m.credsMu.RUnlock()
+
+	secretPath, found := m.credsMap[sanitizedMAC]
+	return secretPath, found
+}
+
 // SanitizeMACAddress takes in a MAC address in one of the IEEE 802 MAC-48, EUI-48, EUI-64 formats
 // and will return it in the standard go format, using colons and lower case letters:
 // Example:	aa:bb:cc:dd:ee:ff
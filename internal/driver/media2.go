@@ -0,0 +1,390 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"math"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/IOTechSystems/onvif/xsd"
+	xsdOnvif "github.com/IOTechSystems/onvif/xsd/onvif"
+)
+
+// The onvif library only implements the Media2 GetProfiles function without the configurations of the profiles,
+// so the Media2 functions needed by the driver are defined here, as specified by
+// https://www.onvif.org/ver20/media/wsdl/media.wsdl
+
+const (
+	// media2Namespace is the namespace of the Media2 service reported by GetServices
+	media2Namespace = "http://www.onvif.org/ver20/media/wsdl"
+	// media2ConfigurationAll requests all the configurations of the Media2 profiles
+	media2ConfigurationAll = "All"
+	// The Media2 stream protocols of the GetStreamUri function
+	media2ProtocolRtspUnicast  = "RtspUnicast"
+	media2ProtocolRTSP         = "RTSP"
+	media2ProtocolRtspOverHttp = "RtspOverHttp"
+)
+
+// driverRequest is implemented by the requests of the functions defined by the driver. The web service of these
+// requests can't be derived from their package, like it is for the requests of the onvif library.
+type driverRequest interface {
+	webService() string
+}
+
+// media2GetProfiles is the Media2 GetProfiles request including the configurations of the profiles
+type media2GetProfiles struct {
+	XMLName string   `xml:"tr2:GetProfiles"`
+	Token   *string  `xml:"tr2:Token,omitempty"`
+	Type    []string `xml:"tr2:Type"`
+}
+
+func (*media2GetProfiles) webService() string {
+	return onvif.Media2WebService
+}
+
+// media2GetProfilesResponse is the response of the Media2 GetProfiles request
+type media2GetProfilesResponse struct {
+	Profiles []media2Profile
+}
+
+// media2Profile is a Media2 profile with the configurations used by the driver
+type media2Profile struct {
+	Token          string `xml:"token,attr"`
+	Fixed          bool   `xml:"fixed,attr"`
+	Name           string
+	Configurations media2Configurations
+}
+
+// media2Configurations holds the configurations of a Media2 profile
+type media2Configurations struct {
+	VideoEncoder *media2VideoEncoderConfiguration
+	PTZ          *struct {
+		Token string `xml:"token,attr"`
+	}
+}
+
+// media2VideoEncoderConfiguration is the Media2 video encoder configuration, whose encoding is a string
+// such as H264 or H265 instead of the Media enumeration
+type media2VideoEncoderConfiguration struct {
+	Token      string `xml:"token,attr"`
+	GovLength  int    `xml:"GovLength,attr"`
+	Profile    string `xml:"Profile,attr"`
+	Name       string
+	UseCount   int
+	Encoding   string
+	Resolution struct {
+		Width  int
+		Height int
+	}
+	RateControl *struct {
+		ConstantBitRate bool `xml:"ConstantBitRate,attr"`
+		FrameRateLimit  float64
+		BitrateLimit    int
+	}
+	Multicast *struct {
+		Address struct {
+			Type        string
+			IPv4Address string
+			IPv6Address string
+		}
+		Port      int
+		TTL       int
+		AutoStart bool
+	}
+	Quality float64
+}
+
+// media2GetProfilesFunction is the onvif.Function of the Media2 GetProfiles request
+type media2GetProfilesFunction struct{}
+
+func (*media2GetProfilesFunction) Request() interface{} {
+	return &media2GetProfiles{Type: []string{media2ConfigurationAll}}
+}
+
+func (*media2GetProfilesFunction) Response() interface{} {
+	return &media2GetProfilesResponse{}
+}
+
+// media2GetStreamUri is the Media2 GetStreamUri request
+type media2GetStreamUri struct {
+	XMLName      string `xml:"tr2:GetStreamUri"`
+	Protocol     string `xml:"tr2:Protocol"`
+	ProfileToken string `xml:"tr2:ProfileToken"`
+}
+
+func (*media2GetStreamUri) webService() string {
+	return onvif.Media2WebService
+}
+
+// media2GetStreamUriResponse is the response of the Media2 GetStreamUri request
+type media2GetStreamUriResponse struct {
+	Uri string
+}
+
+// media2GetStreamUriFunction is the onvif.Function of the Media2 GetStreamUri request
+type media2GetStreamUriFunction struct{}
+
+func (*media2GetStreamUriFunction) Request() interface{} {
+	return &media2GetStreamUri{}
+}
+
+func (*media2GetStreamUriFunction) Response() interface{} {
+	return &media2GetStreamUriResponse{}
+}
+
+// media2GetSnapshotUri is the Media2 GetSnapshotUri request
+type media2GetSnapshotUri struct {
+	XMLName      string `xml:"tr2:GetSnapshotUri"`
+	ProfileToken string `xml:"tr2:ProfileToken"`
+}
+
+func (*media2GetSnapshotUri) webService() string {
+	return onvif.Media2WebService
+}
+
+// media2GetSnapshotUriResponse is the response of the Media2 GetSnapshotUri request
+type media2GetSnapshotUriResponse struct {
+	Uri string
+}
+
+// media2GetSnapshotUriFunction is the onvif.Function of the Media2 GetSnapshotUri request
+type media2GetSnapshotUriFunction struct{}
+
+func (*media2GetSnapshotUriFunction) Request() interface{} {
+	return &media2GetSnapshotUri{}
+}
+
+func (*media2GetSnapshotUriFunction) Response() interface{} {
+	return &media2GetSnapshotUriResponse{}
+}
+
+// media2GetVideoEncoderConfigurations is the Media2 GetVideoEncoderConfigurations request
+type media2GetVideoEncoderConfigurations struct {
+	XMLName            string `xml:"tr2:GetVideoEncoderConfigurations"`
+	ConfigurationToken string `xml:"tr2:ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"tr2:ProfileToken,omitempty"`
+}
+
+func (*media2GetVideoEncoderConfigurations) webService() string {
+	return onvif.Media2WebService
+}
+
+// media2GetVideoEncoderConfigurationsResponse is the response of the Media2 GetVideoEncoderConfigurations request
+type media2GetVideoEncoderConfigurationsResponse struct {
+	Configurations []media2VideoEncoderConfiguration
+}
+
+// media2GetVideoEncoderConfigurationsFunction is the onvif.Function of the Media2 GetVideoEncoderConfigurations request
+type media2GetVideoEncoderConfigurationsFunction struct{}
+
+func (*media2GetVideoEncoderConfigurationsFunction) Request() interface{} {
+	return &media2GetVideoEncoderConfigurations{}
+}
+
+func (*media2GetVideoEncoderConfigurationsFunction) Response() interface{} {
+	return &media2GetVideoEncoderConfigurationsResponse{}
+}
+
+// media2GetVideoEncoderConfigurationOptions is the Media2 GetVideoEncoderConfigurationOptions request
+type media2GetVideoEncoderConfigurationOptions struct {
+	XMLName            string `xml:"tr2:GetVideoEncoderConfigurationOptions"`
+	ConfigurationToken string `xml:"tr2:ConfigurationToken,omitempty"`
+	ProfileToken       string `xml:"tr2:ProfileToken,omitempty"`
+}
+
+func (*media2GetVideoEncoderConfigurationOptions) webService() string {
+	return onvif.Media2WebService
+}
+
+// media2GetVideoEncoderConfigurationOptionsResponse is the response of the Media2 GetVideoEncoderConfigurationOptions
+// request, which holds the options of every encoding supported by the configuration
+type media2GetVideoEncoderConfigurationOptionsResponse struct {
+	Options []media2VideoEncoderOptions
+}
+
+// media2VideoEncoderOptions are the Media2 options of an encoding. The GovLengthRange attribute holds the minimum and
+// maximum GOV length, and the FrameRatesSupported attribute the list of the supported frame rates.
+type media2VideoEncoderOptions struct {
+	GovLengthRange      string `xml:"GovLengthRange,attr"`
+	FrameRatesSupported string `xml:"FrameRatesSupported,attr"`
+	ProfilesSupported   string `xml:"ProfilesSupported,attr"`
+	Encoding            string
+	QualityRange        struct {
+		Min float64
+		Max float64
+	}
+	ResolutionsAvailable []struct {
+		Width  int
+		Height int
+	}
+	BitrateRange *struct {
+		Min int
+		Max int
+	}
+}
+
+// media2GetVideoEncoderConfigurationOptionsFunction is the onvif.Function of the Media2
+// GetVideoEncoderConfigurationOptions request
+type media2GetVideoEncoderConfigurationOptionsFunction struct{}
+
+func (*media2GetVideoEncoderConfigurationOptionsFunction) Request() interface{} {
+	return &media2GetVideoEncoderConfigurationOptions{}
+}
+
+func (*media2GetVideoEncoderConfigurationOptionsFunction) Response() interface{} {
+	return &media2GetVideoEncoderConfigurationOptionsResponse{}
+}
+
+// media2SetVideoEncoderConfiguration is the Media2 SetVideoEncoderConfiguration request
+type media2SetVideoEncoderConfiguration struct {
+	XMLName       string                                 `xml:"tr2:SetVideoEncoderConfiguration"`
+	Configuration media2VideoEncoderConfigurationRequest `xml:"tr2:Configuration"`
+}
+
+func (*media2SetVideoEncoderConfiguration) webService() string {
+	return onvif.Media2WebService
+}
+
+// media2VideoEncoderConfigurationRequest is the video encoder configuration of the Media2
+// SetVideoEncoderConfiguration request, whose elements belong to the onvif schema
+type media2VideoEncoderConfigurationRequest struct {
+	Token       string                         `xml:"token,attr"`
+	GovLength   int                            `xml:"GovLength,attr,omitempty"`
+	Profile     string                         `xml:"Profile,attr,omitempty"`
+	Name        string                         `xml:"onvif:Name"`
+	UseCount    int                            `xml:"onvif:UseCount"`
+	Encoding    string                         `xml:"onvif:Encoding"`
+	Resolution  media2VideoResolutionRequest   `xml:"onvif:Resolution"`
+	RateControl *media2VideoRateControlRequest `xml:"onvif:RateControl,omitempty"`
+	Multicast   *media2MulticastRequest        `xml:"onvif:Multicast,omitempty"`
+	Quality     float64                        `xml:"onvif:Quality"`
+}
+
+type media2VideoResolutionRequest struct {
+	Width  int `xml:"onvif:Width"`
+	Height int `xml:"onvif:Height"`
+}
+
+type media2VideoRateControlRequest struct {
+	ConstantBitRate bool    `xml:"ConstantBitRate,attr,omitempty"`
+	FrameRateLimit  float64 `xml:"onvif:FrameRateLimit"`
+	BitrateLimit    int     `xml:"onvif:BitrateLimit"`
+}
+
+type media2MulticastRequest struct {
+	Address struct {
+		Type        string `xml:"onvif:Type"`
+		IPv4Address string `xml:"onvif:IPv4Address,omitempty"`
+		IPv6Address string `xml:"onvif:IPv6Address,omitempty"`
+	} `xml:"onvif:Address"`
+	Port      int  `xml:"onvif:Port"`
+	TTL       int  `xml:"onvif:TTL"`
+	AutoStart bool `xml:"onvif:AutoStart"`
+}
+
+// media2SetVideoEncoderConfigurationResponse is the empty response of the Media2 SetVideoEncoderConfiguration request
+type media2SetVideoEncoderConfigurationResponse struct{}
+
+// media2SetVideoEncoderConfigurationFunction is the onvif.Function of the Media2 SetVideoEncoderConfiguration request
+type media2SetVideoEncoderConfigurationFunction struct{}
+
+func (*media2SetVideoEncoderConfigurationFunction) Request() interface{} {
+	return &media2SetVideoEncoderConfiguration{}
+}
+
+func (*media2SetVideoEncoderConfigurationFunction) Response() interface{} {
+	return &media2SetVideoEncoderConfigurationResponse{}
+}
+
+// request returns the configuration of the Media2 SetVideoEncoderConfiguration request, keeping the settings which
+// are not changed by the driver, such as the multicast configuration
+func (encoder media2VideoEncoderConfiguration) request() media2VideoEncoderConfigurationRequest {
+	request := media2VideoEncoderConfigurationRequest{
+		Token:      encoder.Token,
+		GovLength:  encoder.GovLength,
+		Profile:    encoder.Profile,
+		Name:       encoder.Name,
+		UseCount:   encoder.UseCount,
+		Encoding:   encoder.Encoding,
+		Resolution: media2VideoResolutionRequest{Width: encoder.Resolution.Width, Height: encoder.Resolution.Height},
+		Quality:    encoder.Quality,
+	}
+	if encoder.RateControl != nil {
+		request.RateControl = &media2VideoRateControlRequest{
+			ConstantBitRate: encoder.RateControl.ConstantBitRate,
+			FrameRateLimit:  encoder.RateControl.FrameRateLimit,
+			BitrateLimit:    encoder.RateControl.BitrateLimit,
+		}
+	}
+	if encoder.Multicast != nil {
+		request.Multicast = &media2MulticastRequest{Port: encoder.Multicast.Port, TTL: encoder.Multicast.TTL, AutoStart: encoder.Multicast.AutoStart}
+		request.Multicast.Address.Type = encoder.Multicast.Address.Type
+		request.Multicast.Address.IPv4Address = encoder.Multicast.Address.IPv4Address
+		request.Multicast.Address.IPv6Address = encoder.Multicast.Address.IPv6Address
+	}
+	return request
+}
+
+// deviceGetServices is the GetServices request of the device service. The response of the onvif library only holds
+// a single service, so the request is defined by the driver to get all the services.
+type deviceGetServices struct {
+	XMLName           string `xml:"tds:GetServices"`
+	IncludeCapability bool   `xml:"tds:IncludeCapability"`
+}
+
+func (*deviceGetServices) webService() string {
+	return onvif.DeviceWebService
+}
+
+// deviceGetServicesResponse is the response of the GetServices request
+type deviceGetServicesResponse struct {
+	Service []struct {
+		Namespace string
+		XAddr     string
+	}
+}
+
+// deviceGetServicesFunction is the onvif.Function of the GetServices request
+type deviceGetServicesFunction struct{}
+
+func (*deviceGetServicesFunction) Request() interface{} {
+	return &deviceGetServices{}
+}
+
+func (*deviceGetServicesFunction) Response() interface{} {
+	return &deviceGetServicesResponse{}
+}
+
+// mediaProfile normalizes the Media2 profile into a Media profile, so the profiles of both media services are
+// handled the same way. The frame rate limit is rounded, since it is an integer in the Media profiles.
+func (profile media2Profile) mediaProfile() xsdOnvif.Profile {
+	mediaProfile := xsdOnvif.Profile{
+		Token: xsdOnvif.ReferenceToken(profile.Token),
+		Fixed: profile.Fixed,
+		Name:  xsdOnvif.Name(profile.Name),
+	}
+	if ptz := profile.Configurations.PTZ; ptz != nil {
+		mediaProfile.PTZConfiguration = &xsdOnvif.PTZConfiguration{Token: xsdOnvif.ReferenceToken(ptz.Token)}
+	}
+	encoder := profile.Configurations.VideoEncoder
+	if encoder == nil {
+		return mediaProfile
+	}
+	encoding := xsdOnvif.VideoEncoding(encoder.Encoding)
+	width, height := xsd.Int(encoder.Resolution.Width), xsd.Int(encoder.Resolution.Height)
+	mediaProfile.VideoEncoderConfiguration = &xsdOnvif.VideoEncoderConfiguration{
+		ConfigurationEntity: xsdOnvif.ConfigurationEntity{Token: xsdOnvif.ReferenceToken(encoder.Token), Name: xsdOnvif.Name(encoder.Name)},
+		Encoding:            &encoding,
+		Resolution:          &xsdOnvif.VideoResolution{Width: &width, Height: &height},
+		Quality:             encoder.Quality,
+	}
+	if encoder.RateControl != nil {
+		frameRate, bitrate := xsd.Int(math.Round(encoder.RateControl.FrameRateLimit)), xsd.Int(encoder.RateControl.BitrateLimit)
+		mediaProfile.VideoEncoderConfiguration.RateControl = &xsdOnvif.VideoRateControl{FrameRateLimit: &frameRate, BitrateLimit: &bitrate}
+	}
+	return mediaProfile
+}
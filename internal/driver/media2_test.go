@@ -0,0 +1,41 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMedia2Profile_mediaProfile(t *testing.T) {
+	var response media2GetProfilesResponse
+	err := xml.Unmarshal([]byte(testMedia2ProfilesResponse), &response)
+	require.NoError(t, err)
+	require.Len(t, response.Profiles, 1)
+
+	profile := response.Profiles[0].mediaProfile()
+	assert.Equal(t, "main", string(profile.Token))
+	assert.Equal(t, "MainStream", string(profile.Name))
+	assert.True(t, profile.Fixed)
+	encoder := profile.VideoEncoderConfiguration
+	require.NotNil(t, encoder)
+	assert.Equal(t, "encoder1", string(encoder.Token))
+	assert.Equal(t, "H265", string(*encoder.Encoding))
+	assert.EqualValues(t, 3840, *encoder.Resolution.Width)
+	assert.EqualValues(t, 2160, *encoder.Resolution.Height)
+	assert.EqualValues(t, 15, *encoder.RateControl.FrameRateLimit)
+	assert.EqualValues(t, 8192, *encoder.RateControl.BitrateLimit)
+	require.NotNil(t, profile.PTZConfiguration)
+	assert.Equal(t, "ptz1", string(profile.PTZConfiguration.Token))
+
+	profile = media2Profile{Token: "audio"}.mediaProfile()
+	assert.Nil(t, profile.VideoEncoderConfiguration)
+	assert.Nil(t, profile.PTZConfiguration)
+}
@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package driver
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ndmStateOffset is the offset of the ndm_state field within the ndmsg header of the neighbor messages
+const ndmStateOffset = 8
+
+// netlinkByteOrder is the byte order of the netlink messages, which is the one of the host. It is found by parsing
+// a netlink header encoded in little endian.
+var netlinkByteOrder = func() binary.ByteOrder {
+	header := make([]byte, syscall.NLMSG_HDRLEN)
+	binary.LittleEndian.PutUint32(header, syscall.NLMSG_HDRLEN)
+	if _, err := syscall.ParseNetlinkMessage(header); err == nil {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// readNeighborTable dumps the IPv4 neighbor table of the kernel with a netlink request, which holds the state of the
+// entries unlike /proc/net/arp
+func readNeighborTable() ([]neighborEntry, error) {
+	table, err := syscall.NetlinkRIB(unix.RTM_GETNEIGH, syscall.AF_INET)
+	if err != nil {
+		return nil, err
+	}
+	return parseNeighborTable(table)
+}
+
+// parseNeighborTable parses the entries of the netlink dump of the neighbor table
+func parseNeighborTable(table []byte) ([]neighborEntry, error) {
+	msgs, err := syscall.ParseNetlinkMessage(table)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []neighborEntry
+	for _, msg := range msgs {
+		if msg.Header.Type != unix.RTM_NEWNEIGH || len(msg.Data) < unix.SizeofNdMsg {
+			continue
+		}
+		entry := neighborEntry{state: netlinkByteOrder.Uint16(msg.Data[ndmStateOffset:])}
+
+		// the syscall package only parses the attributes of the link, address and route messages, but the ndmsg
+		// header of the neighbor messages has the size of the rtmsg header of the route messages
+		routeMsg := msg
+		routeMsg.Header.Type = syscall.RTM_NEWROUTE
+		attrs, err := syscall.ParseNetlinkRouteAttr(&routeMsg)
+		if err != nil {
+			return nil, err
+		}
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case unix.NDA_DST:
+				entry.ip = net.IP(attr.Value)
+			case unix.NDA_LLADDR:
+				entry.mac = net.HardwareAddr(attr.Value)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package driver
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// neighborMessage builds the netlink message of a neighbor entry, as dumped by the kernel
+func neighborMessage(msgType uint16, ip string, mac string, state uint16) []byte {
+	msg := make([]byte, syscall.NLMSG_HDRLEN+unix.SizeofNdMsg)
+	msg[syscall.NLMSG_HDRLEN] = syscall.AF_INET
+	netlinkByteOrder.PutUint16(msg[syscall.NLMSG_HDRLEN+ndmStateOffset:], state)
+	msg = appendNeighborAttr(msg, unix.NDA_DST, net.ParseIP(ip).To4())
+	hardwareAddr, _ := net.ParseMAC(mac)
+	msg = appendNeighborAttr(msg, unix.NDA_LLADDR, hardwareAddr)
+	netlinkByteOrder.PutUint32(msg[0:4], uint32(len(msg)))
+	netlinkByteOrder.PutUint16(msg[4:6], msgType)
+	return msg
+}
+
+// appendNeighborAttr appends the attribute to the netlink message, padded to the netlink alignment
+func appendNeighborAttr(msg []byte, attrType uint16, value []byte) []byte {
+	attrLen := syscall.SizeofRtAttr + len(value)
+	attr := make([]byte, (attrLen+syscall.RTA_ALIGNTO-1)&^(syscall.RTA_ALIGNTO-1))
+	netlinkByteOrder.PutUint16(attr[0:2], uint16(attrLen))
+	netlinkByteOrder.PutUint16(attr[2:4], attrType)
+	copy(attr[syscall.SizeofRtAttr:], value)
+	return append(msg, attr...)
+}
+
+func TestParseNeighborTable(t *testing.T) {
+	var table []byte
+	table = append(table, neighborMessage(unix.RTM_NEWNEIGH, "192.168.1.1", "aa:bb:cc:dd:ee:ff", neighborStateReachable)...)
+	table = append(table, neighborMessage(unix.RTM_NEWNEIGH, "192.168.1.20", "11:22:33:44:55:66", 0x04)...) // NUD_STALE
+	table = append(table, neighborMessage(syscall.RTM_NEWROUTE, "192.168.1.30", "11:22:33:44:55:77", neighborStateReachable)...)
+
+	entries, err := parseNeighborTable(table)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "192.168.1.1", entries[0].ip.String())
+	assert.Equal(t, "aa:bb:cc:dd:ee:ff", entries[0].mac.String())
+	assert.Equal(t, uint16(neighborStateReachable), entries[0].state)
+	assert.Equal(t, "192.168.1.20", entries[1].ip.String())
+	assert.Equal(t, uint16(0x04), entries[1].state)
+
+	_, err = parseNeighborTable(table[:len(table)-3])
+	require.Error(t, err)
+}
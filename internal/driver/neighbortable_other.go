@@ -0,0 +1,16 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+
+package driver
+
+import "fmt"
+
+// readNeighborTable is only supported on Linux
+func readNeighborTable() ([]neighborEntry, error) {
+	return nil, fmt.Errorf("the neighbor table can only be read on linux")
+}
@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// icmpProtocolNumber is the IANA protocol number of ICMP for IPv4
+	icmpProtocolNumber = 1
+
+	// neighborStateReachable and neighborStateDelay are the states (NUD_REACHABLE and NUD_DELAY) of the neighbor
+	// entries whose hardware address was recently confirmed. The stale entries may be left by a camera which has
+	// since left the network, so they are not accepted.
+	neighborStateReachable = 0x02
+	neighborStateDelay     = 0x08
+)
+
+// neighborEntry is an entry of the IPv4 neighbor table of the kernel
+type neighborEntry struct {
+	ip  net.IP
+	mac net.HardwareAddr
+	// state is the NUD state of the entry
+	state uint16
+}
+
+// deviceIP resolves the IPv4 address of the device from its Onvif protocol properties
+func deviceIP(device models.Device) (net.IP, error) {
+	addr := device.Protocols[OnvifProtocol][Address]
+	if addr == "" {
+		return nil, fmt.Errorf("device has no network address")
+	}
+	ipAddr, err := net.ResolveIPAddr("ip4", addr)
+	if err != nil {
+		return nil, err
+	}
+	return ipAddr.IP, nil
+}
+
+// icmpProbe sends an unprivileged ICMP echo request to the device and waits for the reply.
+// Returns nil if the device replied before the probe timeout.
+// Note: unprivileged ICMP requires the group of the service to be included in the
+// net.ipv4.ping_group_range sysctl.
+func (d *Driver) icmpProbe(device models.Device) error {
+	ip, err := deviceIP(device)
+	if err != nil {
+		return err
+	}
+
+	d.configMu.RLock()
+	timeout := time.Duration(d.config.AppCustom.ProbeTimeoutMillis) * time.Millisecond
+	d.configMu.RUnlock()
+
+	conn, err := icmp.ListenPacket("udp4", "0.0.0.0")
+	if err != nil {
+		return fmt.Errorf("unable to open unprivileged icmp socket: %w", err)
+	}
+	defer conn.Close()
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{
+			ID:   os.Getpid() & 0xffff,
+			Seq:  1,
+			Data: []byte(device.Name),
+		},
+	}
+	request, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err = conn.WriteTo(request, &net.UDPAddr{IP: ip}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		reply, err := icmp.ParseMessage(icmpProtocolNumber, buf[:n])
+		if err != nil {
+			continue
+		}
+		// note: the kernel rewrites the echo ID of unprivileged sockets, so only the peer is compared
+		if udpAddr, ok := peer.(*net.UDPAddr); ok && udpAddr.IP.Equal(ip) && reply.Type == ipv4.ICMPTypeEchoReply {
+			return nil
+		}
+	}
+}
+
+// arpLookup checks whether the kernel's neighbor table has an entry for the device which was recently confirmed.
+// Returns nil if a reachable entry was found.
+func (d *Driver) arpLookup(device models.Device) error {
+	ip, err := deviceIP(device)
+	if err != nil {
+		return err
+	}
+
+	entries, err := readNeighborTable()
+	if err != nil {
+		return fmt.Errorf("unable to read the neighbor table: %w", err)
+	}
+
+	mac, found := findNeighborEntry(entries, ip)
+	if !found {
+		return fmt.Errorf("no reachable arp entry found for %s", ip)
+	}
+	d.lc.Debugf("Found arp entry %s for device %s", mac, device.Name)
+	return nil
+}
+
+// findNeighborEntry returns the hardware address of the REACHABLE or DELAY entry matching the ip
+func findNeighborEntry(entries []neighborEntry, ip net.IP) (string, bool) {
+	for _, entry := range entries {
+		if entry.state&(neighborStateReachable|neighborStateDelay) != 0 && len(entry.mac) > 0 && ip.Equal(entry.ip) {
+			return entry.mac.String(), true
+		}
+	}
+	return "", false
+}
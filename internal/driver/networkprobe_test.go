@@ -0,0 +1,77 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindNeighborEntry(t *testing.T) {
+	mac := func(addr string) net.HardwareAddr {
+		hardwareAddr, _ := net.ParseMAC(addr)
+		return hardwareAddr
+	}
+	entries := []neighborEntry{
+		{ip: net.ParseIP("192.168.1.1").To4(), mac: mac("aa:bb:cc:dd:ee:ff"), state: neighborStateReachable},
+		{ip: net.ParseIP("192.168.1.10").To4(), mac: mac("aa:bb:cc:dd:ee:00"), state: neighborStateDelay},
+		{ip: net.ParseIP("192.168.1.20").To4(), mac: mac("11:22:33:44:55:66"), state: 0x04}, // NUD_STALE
+		{ip: net.ParseIP("192.168.1.30").To4(), state: 0x20},                                // NUD_FAILED
+		{ip: net.ParseIP("192.168.1.40").To4(), mac: mac("11:22:33:44:55:77"), state: 0x80}, // NUD_PERMANENT
+	}
+
+	tests := []struct {
+		name        string
+		ip          string
+		expectedMAC string
+		found       bool
+	}{
+		{
+			name:        "reachable entry",
+			ip:          "192.168.1.1",
+			expectedMAC: "aa:bb:cc:dd:ee:ff",
+			found:       true,
+		},
+		{
+			name:        "delay entry",
+			ip:          "192.168.1.10",
+			expectedMAC: "aa:bb:cc:dd:ee:00",
+			found:       true,
+		},
+		{
+			name:  "stale entry",
+			ip:    "192.168.1.20",
+			found: false,
+		},
+		{
+			name:  "failed entry",
+			ip:    "192.168.1.30",
+			found: false,
+		},
+		{
+			name:  "permanent entry",
+			ip:    "192.168.1.40",
+			found: false,
+		},
+		{
+			name:  "missing entry",
+			ip:    "10.0.0.1",
+			found: false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mac, found := findNeighborEntry(entries, net.ParseIP(test.ip))
+			assert.Equal(t, test.found, found)
+			assert.Equal(t, test.expectedMAC, mac)
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/edgexfoundry/go-mod-core-contracts/v2/clients/logger"
@@ -36,6 +37,12 @@ const (
 	SubscribeCameraEvent   = "SubscribeCameraEvent"
 	UnsubscribeCameraEvent = "UnsubscribeCameraEvent"
 	GetSnapshot            = "GetSnapshot"
+	SnapshotCapture        = "SnapshotCapture"
+	GetStreamURIs          = "GetStreamURIs"
+	PTZMoveTo              = "PTZMoveTo"
+	PTZNudge               = "PTZNudge"
+	PTZStop                = "PTZStop"
+	PTZGotoPreset          = "PTZGotoPreset"
 )
 
 // OnvifClient manages the state required to issue ONVIF requests to the specified camera
@@ -44,47 +51,33 @@ type OnvifClient struct {
 	lc          logger.LoggingClient
 	DeviceName  string
 	onvifDevice OnvifDevice
+	// requestPolicy holds the timeout, retry and concurrency settings of the requests sent to the camera
+	requestPolicy requestPolicy
+	// limiter limits the amount of concurrent requests sent to the camera
+	limiter *RequestLimiter
+	// deviceMu is for locking access to the onvifDevice and requestPolicy, which are replaced when the credentials
+	// or the settings of the device change
+	deviceMu sync.RWMutex
 	// RebootNeeded indicates the camera should reboot to apply the configuration change
 	RebootNeeded bool
 	// CameraEventResource is used to send the async event to north bound
 	CameraEventResource     models.DeviceResource
 	pullPointManager        *PullPointManager
 	baseNotificationManager *BaseNotificationManager
+	// eventPublisher publishes the camera events in order, along with their snapshots
+	eventPublisher eventPublisher
 }
 
 // newOnvifClient returns an OnvifClient for a single camera
 func (d *Driver) newOnvifClient(device models.Device) (*OnvifClient, errors.EdgeX) {
-	xAddr, edgexErr := GetCameraXAddr(device.Protocols)
+	onvifDevice, edgexErr := d.newOnvifDevice(device)
 	if edgexErr != nil {
-		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create cameraInfo for camera %s", device.Name), edgexErr)
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
 	}
 
-	credential, edgexErr := d.tryGetCredentialsForDevice(device)
+	resource, edgexErr := d.getCameraEventResourceByDeviceName(device.Name)
 	if edgexErr != nil {
-		d.lc.Warnf("Unable to find credentials for Device %s, reverting to no auth", device.Name)
-		credential = noAuthCredentials
-	}
-
-	d.configMu.Lock()
-	requestTimeout := d.config.AppCustom.RequestTimeout
-	d.configMu.Unlock()
-
-	onvifDevice, err := onvif.NewDevice(onvif.DeviceParams{
-		Xaddr:    xAddr,
-		Username: credential.Username,
-		Password: credential.Password,
-		AuthMode: credential.AuthMode,
-		HttpClient: &http.Client{
-			Timeout: time.Duration(requestTimeout) * time.Second,
-		},
-	})
-	if err != nil {
-		return nil, errors.NewCommonEdgeX(errors.KindServiceUnavailable, "failed to initialize Onvif device client", err)
-	}
-
-	resource, err := d.getCameraEventResourceByDeviceName(device.Name)
-	if err != nil {
-		return nil, errors.NewCommonEdgeXWrapper(err)
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
 	}
 
 	client := &OnvifClient{
@@ -92,6 +85,8 @@ func (d *Driver) newOnvifClient(device models.Device) (*OnvifClient, errors.Edge
 		lc:                  d.lc,
 		DeviceName:          device.Name,
 		onvifDevice:         onvifDevice,
+		requestPolicy:       d.requestPolicyForDevice(device),
+		limiter:             d.requestLimiter,
 		CameraEventResource: resource,
 	}
 	// Create PullPointManager to control multiple pull points
@@ -104,7 +99,71 @@ func (d *Driver) newOnvifClient(device models.Device) (*OnvifClient, errors.Edge
 	return client, nil
 }
 
+// newOnvifDevice creates the onvif device used to communicate with the camera, using the latest credentials of the device
+func (d *Driver) newOnvifDevice(device models.Device) (OnvifDevice, errors.EdgeX) {
+	xAddr, edgexErr := GetCameraXAddr(device.Protocols)
+	if edgexErr != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create cameraInfo for camera %s", device.Name), edgexErr)
+	}
+
+	credential, edgexErr := d.tryGetCredentialsForDevice(device)
+	if edgexErr != nil {
+		d.lc.Warnf("Unable to find credentials for Device %s, reverting to no auth", device.Name)
+		credential = noAuthCredentials
+	}
+	credential = d.resolveAuthMode(device, credential)
+
+	httpClient, edgexErr := d.newHTTPClient(device, d.requestPolicyForDevice(device).Timeout)
+	if edgexErr != nil {
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
+	onvifDevice, err := onvif.NewDevice(onvif.DeviceParams{
+		Xaddr:      xAddr,
+		Username:   credential.Username,
+		Password:   credential.Password,
+		AuthMode:   credential.AuthMode,
+		HttpClient: httpClient,
+	})
+	if err != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindServiceUnavailable, "failed to initialize Onvif device client", err)
+	}
+	return onvifDevice, nil
+}
+
+// getOnvifDevice returns the onvif device used to communicate with the camera
+func (onvifClient *OnvifClient) getOnvifDevice() OnvifDevice {
+	onvifClient.deviceMu.RLock()
+	defer onvifClient.deviceMu.RUnlock()
+	return onvifClient.onvifDevice
+}
+
+// getRequestPolicy returns the timeout and retry settings of the requests sent to the camera
+func (onvifClient *OnvifClient) getRequestPolicy() requestPolicy {
+	onvifClient.deviceMu.RLock()
+	defer onvifClient.deviceMu.RUnlock()
+	return onvifClient.requestPolicy
+}
+
+// setOnvifDevice replaces the onvif device and request policy used to communicate with the camera, for example
+// after the credentials changed. The pull point subscribers are updated as well, so any active subscriptions are kept.
+func (onvifClient *OnvifClient) setOnvifDevice(onvifDevice OnvifDevice, policy requestPolicy) {
+	onvifClient.deviceMu.Lock()
+	onvifClient.onvifDevice = onvifDevice
+	onvifClient.requestPolicy = policy
+	onvifClient.deviceMu.Unlock()
+
+	if onvifClient.pullPointManager != nil {
+		onvifClient.pullPointManager.updateOnvifDevice(onvifDevice, policy.Timeout)
+	}
+}
+
 func (d *Driver) getCameraEventResourceByDeviceName(deviceName string) (r models.DeviceResource, edgexErr errors.EdgeX) {
+	return d.getDeviceResourceByGetFunction(deviceName, CameraEvent)
+}
+
+// getDeviceResourceByGetFunction returns the device resource of the device's profile with the get function
+func (d *Driver) getDeviceResourceByGetFunction(deviceName string, function string) (r models.DeviceResource, edgexErr errors.EdgeX) {
 	device, err := d.sdkService.GetDeviceByName(deviceName)
 	if err != nil {
 		return r, errors.NewCommonEdgeXWrapper(err)
@@ -115,11 +174,11 @@ func (d *Driver) getCameraEventResourceByDeviceName(deviceName string) (r models
 	}
 	for _, r := range profile.DeviceResources {
 		val, ok := r.Attributes[GetFunction]
-		if ok && fmt.Sprint(val) == CameraEvent {
+		if ok && fmt.Sprint(val) == function {
 			return r, nil
 		}
 	}
-	return r, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("device resource with Getfunciton '%s' not found", CameraEvent), nil)
+	return r, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("device resource with Getfunciton '%s' not found", function), nil)
 }
 
 // CallOnvifFunction send the request to the camera via onvif client
@@ -226,14 +285,39 @@ func (onvifClient *OnvifClient) callCustomFunction(resourceName, functionName st
 			onvifClient.baseNotificationManager.UnsubscribeAll()
 		}()
 	case GetSnapshot:
-		res, edgexErr := onvifClient.callGetSnapshotFunction()
+		snapshot, edgexErr := onvifClient.callGetSnapshotFunction(data)
 		if edgexErr != nil {
 			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
 		}
-		cv, err = sdkModel.NewCommandValue(resourceName, common.ValueTypeBinary, res)
+		cv, edgexErr = newSnapshotCommandValue(resourceName, snapshot)
+		if edgexErr != nil {
+			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+	case GetStreamURIs:
+		uris, edgexErr := onvifClient.callGetStreamURIsFunction(data)
+		if edgexErr != nil {
+			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+		cv, err = sdkModel.NewCommandValue(resourceName, common.ValueTypeObject, uris)
 		if err != nil {
 			return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create commandValue for the web service '%s' function '%s'", EdgeXWebService, functionName), err)
 		}
+	case PTZMoveTo:
+		if edgexErr = onvifClient.callPTZMoveToFunction(data); edgexErr != nil {
+			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+	case PTZNudge:
+		if edgexErr = onvifClient.callPTZNudgeFunction(data); edgexErr != nil {
+			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+	case PTZStop:
+		if edgexErr = onvifClient.callPTZStopFunction(data); edgexErr != nil {
+			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+	case PTZGotoPreset:
+		if edgexErr = onvifClient.callPTZGotoPresetFunction(data); edgexErr != nil {
+			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+		}
 	case SetFriendlyName:
 		deviceName := onvifClient.DeviceName
 		device, err := onvifClient.driver.sdkService.GetDeviceByName(deviceName)
@@ -294,6 +378,43 @@ func (onvifClient *OnvifClient) callCustomFunction(resourceName, functionName st
 		if err != nil {
 			return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create commandValue for the web service '%s' function '%s'", EdgeXWebService, functionName), err)
 		}
+	case SetSecretPath:
+		deviceName := onvifClient.DeviceName
+		device, err := onvifClient.driver.sdkService.GetDeviceByName(deviceName)
+		if err != nil {
+			return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to get device '%s'", deviceName), err)
+		}
+
+		// an empty secret path removes the override, so the credential mappings are used again
+		secretPath := strings.TrimSpace(string(data))
+		if secretPath == "" {
+			delete(device.Protocols[OnvifProtocol], SecretPath)
+		} else {
+			if _, edgexErr = onvifClient.driver.tryGetCredentials(secretPath); edgexErr != nil {
+				return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("secret path '%s' does not exist in the Secret Store", secretPath), edgexErr)
+			}
+			device.Protocols[OnvifProtocol][SecretPath] = secretPath // create or update secret path field
+		}
+		err = onvifClient.driver.sdkService.UpdateDevice(device)
+		if err != nil {
+			return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to update device '%s'", deviceName), err)
+		}
+	case GetSecretPath:
+		deviceName := onvifClient.DeviceName
+		device, err := onvifClient.driver.sdkService.GetDeviceByName(deviceName)
+		if err != nil {
+			return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to get device '%s'", deviceName), err)
+		}
+
+		// return the secret path which is in use, whether it comes from the override or the credential mappings
+		cv, err = sdkModel.NewCommandValue(resourceName, common.ValueTypeString, onvifClient.driver.secretPathForDevice(device))
+		if err != nil {
+			return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create commandValue for the web service '%s' function '%s'", EdgeXWebService, functionName), err)
+		}
+	case RotatePassword:
+		if edgexErr = onvifClient.driver.rotateDevicePassword(onvifClient.DeviceName); edgexErr != nil {
+			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+		}
 	default:
 		return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("not support the custom function '%s'", functionName), nil)
 	}
@@ -322,52 +443,6 @@ func (onvifClient *OnvifClient) callSubscribeCameraEventFunction(resourceName, s
 	return nil
 }
 
-// callGetSnapshotFunction returns a snapshot from the camera as a slice of bytes
-// The implementation can refer to https://github.com/edgexfoundry/device-camera-go/blob/5c4f34d1d59b8e25e1a6316661d463e2495d45fe/internal/driver/onvifclient.go#L119
-func (onvifClient *OnvifClient) callGetSnapshotFunction() ([]byte, errors.EdgeX) {
-	// Get the token from the profile
-	respContent, edgexErr := onvifClient.callOnvifFunction(onvif.MediaWebService, onvif.GetProfiles, nil)
-	if edgexErr != nil {
-		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
-	}
-	profilesResp, ok := respContent.(*media.GetProfilesResponse)
-	if !ok {
-		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetProfilesResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
-	}
-	if len(profilesResp.Profiles) == 0 {
-		return nil, errors.NewCommonEdgeX(errors.KindServerError, "no onvif profiles found", nil)
-	}
-	requestData, edgexErr := snapshotUriRequestData(profilesResp.Profiles[0].Token)
-	if edgexErr != nil {
-		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
-	}
-	// Get the snapshot uri
-	respContent, edgexErr = onvifClient.callOnvifFunction(onvif.MediaWebService, onvif.GetSnapshotUri, requestData)
-	if edgexErr != nil {
-		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
-	}
-	uriResponse, ok := respContent.(*media.GetSnapshotUriResponse)
-	if !ok {
-		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetSnapshotUriResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
-	}
-	url := uriResponse.MediaUri.Uri
-
-	// Get the snapshot binary data
-	resp, err := onvifClient.onvifDevice.SendGetSnapshotRequest(string(url))
-	if err != nil {
-		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to retrieve the snapshot from the url %s", url), err)
-	}
-	defer resp.Body.Close()
-	buf, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.NewCommonEdgeX(errors.KindServerError, "error reading http request", err)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("http request for image failed with status %v, %s", resp.StatusCode, string(buf)), nil)
-	}
-	return buf, nil
-}
-
 func snapshotUriRequestData(profileToken xsdOnvif.ReferenceToken) ([]byte, errors.EdgeX) {
 	req := media.GetSnapshotUri{
 		ProfileToken: profileToken,
@@ -384,14 +459,26 @@ func (onvifClient *OnvifClient) callOnvifFunction(serviceName, functionName stri
 	if edgexErr != nil {
 		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
 	}
+	return onvifClient.callFunction(serviceName, functionName, function, data)
+}
+
+// callFunction sends the request of the function to the camera, and returns the content of the response
+func (onvifClient *OnvifClient) callFunction(serviceName, functionName string, function onvif.Function, data []byte) (interface{}, errors.EdgeX) {
 	request, edgexErr := createRequest(function, data)
 	if edgexErr != nil {
 		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create '%s' request for the web service '%s'", functionName, serviceName), edgexErr)
 	}
 
-	endpoint, err := onvifClient.onvifDevice.GetEndpointByRequestStruct(request)
-	if err != nil {
-		return nil, errors.NewCommonEdgeXWrapper(err)
+	if breaker := onvifClient.circuitBreaker(); breaker != nil {
+		if edgexErr = breaker.allow(onvifClient.DeviceName); edgexErr != nil {
+			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+	}
+
+	onvifDevice := onvifClient.getOnvifDevice()
+	endpoint, edgexErr := requestEndpoint(onvifDevice, request)
+	if edgexErr != nil {
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
 	}
 
 	requestBody, err := xml.Marshal(request)
@@ -401,16 +488,11 @@ func (onvifClient *OnvifClient) callOnvifFunction(serviceName, functionName stri
 	xmlRequestBody := string(requestBody)
 	onvifClient.lc.Debugf("SOAP Request: %v", xmlRequestBody)
 
-	servResp, err := onvifClient.onvifDevice.SendSoap(endpoint, xmlRequestBody)
+	statusCode, rsp, err := onvifClient.sendSoap(onvifDevice, functionName, endpoint, xmlRequestBody)
+	onvifClient.recordTransportResult(err)
 	if err != nil {
 		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to send the '%s' request for the web service '%s'", functionName, serviceName), err)
 	}
-	defer servResp.Body.Close()
-
-	rsp, err := ioutil.ReadAll(servResp.Body)
-	if err != nil {
-		return nil, errors.NewCommonEdgeXWrapper(err)
-	}
 
 	responseEnvelope, edgexErr := createResponse(function, rsp)
 	if edgexErr != nil {
@@ -419,15 +501,15 @@ func (onvifClient *OnvifClient) callOnvifFunction(serviceName, functionName stri
 	res, _ := xml.Marshal(responseEnvelope)
 	onvifClient.lc.Debugf("SOAP Response: %v", string(res))
 
-	if servResp.StatusCode == http.StatusUnauthorized {
+	if statusCode == http.StatusUnauthorized {
 		return nil, errors.NewCommonEdgeX(errors.KindInvalidId,
 			fmt.Sprintf("failed to verify the authentication for the function '%s' of web service '%s'. Onvif error: %s",
 				functionName, serviceName, responseEnvelope.Body.Fault.String()), nil)
-	} else if servResp.StatusCode == http.StatusBadRequest {
+	} else if statusCode == http.StatusBadRequest {
 		return nil, errors.NewCommonEdgeX(errors.KindContractInvalid,
 			fmt.Sprintf("invalid request for the function '%s' of web service '%s'. Onvif error: %s",
 				functionName, serviceName, responseEnvelope.Body.Fault.String()), nil)
-	} else if servResp.StatusCode > http.StatusNoContent {
+	} else if statusCode > http.StatusNoContent {
 		return nil, errors.NewCommonEdgeX(errors.KindServerError,
 			fmt.Sprintf("failed to execute the request for the function '%s' of web service '%s'. Onvif error: %s",
 				functionName, serviceName, responseEnvelope.Body.Fault.String()), nil)
@@ -435,6 +517,61 @@ func (onvifClient *OnvifClient) callOnvifFunction(serviceName, functionName stri
 	return responseEnvelope.Body.Content, nil
 }
 
+// sendSoap sends the SOAP request to the camera and returns the status code and body of the response.
+// The requests of idempotent functions are retried with an exponential backoff, when the camera could not be
+// reached or was temporarily unavailable.
+func (onvifClient *OnvifClient) sendSoap(onvifDevice OnvifDevice, functionName, endpoint, xmlRequestBody string) (int, []byte, error) {
+	policy := onvifClient.getRequestPolicy()
+	retries := 0
+	if isIdempotentFunction(functionName) {
+		retries = policy.Retries
+	}
+
+	for retry := 1; ; retry++ {
+		release := onvifClient.acquireRequestSlot(false)
+		statusCode, rsp, err := sendSoapOnce(onvifDevice, endpoint, xmlRequestBody)
+		release()
+		if retry > retries || !isRetryableResponse(statusCode, err) {
+			return statusCode, rsp, err
+		}
+		backoff := policy.retryBackoff(retry)
+		onvifClient.lc.Debugf("The '%s' request to device %s failed (status code: %d, error: %v), retrying in %v (%d/%d)",
+			functionName, onvifClient.DeviceName, statusCode, err, backoff, retry, retries)
+		time.Sleep(backoff)
+	}
+}
+
+// sendSoapOnce sends the SOAP request to the camera and reads the response
+func sendSoapOnce(onvifDevice OnvifDevice, endpoint, xmlRequestBody string) (int, []byte, error) {
+	servResp, err := onvifDevice.SendSoap(endpoint, xmlRequestBody)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer servResp.Body.Close()
+
+	rsp, err := ioutil.ReadAll(servResp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return servResp.StatusCode, rsp, nil
+}
+
+// requestEndpoint returns the endpoint of the web service of the request
+func requestEndpoint(onvifDevice OnvifDevice, request interface{}) (string, errors.EdgeX) {
+	if r, ok := request.(driverRequest); ok {
+		endpoint := onvifDevice.GetEndpoint(strings.ToLower(r.webService()))
+		if endpoint == "" {
+			return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("the camera does not support the web service '%s'", r.webService()), nil)
+		}
+		return endpoint, nil
+	}
+	endpoint, err := onvifDevice.GetEndpointByRequestStruct(request)
+	if err != nil {
+		return "", errors.NewCommonEdgeXWrapper(err)
+	}
+	return endpoint, nil
+}
+
 func createRequest(function onvif.Function, data []byte) (interface{}, errors.EdgeX) {
 	request := function.Request()
 	if len(data) > 0 {
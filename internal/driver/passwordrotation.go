@@ -0,0 +1,224 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/IOTechSystems/onvif"
+	onvifdevice "github.com/IOTechSystems/onvif/device"
+	xsdOnvif "github.com/IOTechSystems/onvif/xsd/onvif"
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/secret"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+)
+
+const (
+	// rotatedPasswordLength is the length of the passwords generated by the password rotation
+	rotatedPasswordLength = 24
+	// rotatedPasswordCharset is the set of characters of the generated passwords. Symbols are not used,
+	// because many cameras only accept a limited set of them.
+	rotatedPasswordCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// rotateDevicePassword generates a new password for the user of the device's credentials. The credentials must
+// not be shared with any other device, otherwise the credential group must be rotated instead.
+func (d *Driver) rotateDevicePassword(deviceName string) errors.EdgeX {
+	device, err := d.sdkService.GetDeviceByName(deviceName)
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("device '%s' not found", deviceName), err)
+	}
+
+	secretPath := d.secretPathForDevice(device)
+	var others []string
+	for _, name := range d.devicesUsingSecretPath(secretPath) {
+		if name != deviceName {
+			others = append(others, name)
+		}
+	}
+	if len(others) > 0 {
+		return errors.NewCommonEdgeX(errors.KindStatusConflict,
+			fmt.Sprintf("the credentials of secret path '%s' are shared with the devices %v, rotate the password of the credential group instead", secretPath, others), nil)
+	}
+
+	return d.rotatePassword(secretPath, []models.Device{device})
+}
+
+// rotateCredentialGroupPassword generates a new password for the user of the credential group, and applies it
+// to every device using the group. Returns the names of the devices whose password was rotated.
+func (d *Driver) rotateCredentialGroupPassword(secretPath string) ([]string, errors.EdgeX) {
+	names := d.devicesUsingSecretPath(secretPath)
+	if len(names) == 0 {
+		return nil, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("no devices are using the credential group '%s'", secretPath), nil)
+	}
+
+	devices := make([]models.Device, 0, len(names))
+	for _, name := range names {
+		device, err := d.sdkService.GetDeviceByName(name)
+		if err != nil {
+			return nil, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("device '%s' not found", name), err)
+		}
+		devices = append(devices, device)
+	}
+
+	if edgexErr := d.rotatePassword(secretPath, devices); edgexErr != nil {
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	return names, nil
+}
+
+// rotatePassword changes the password of the user of the secret path on every device, verifies the devices accept
+// the new password, and stores it in the Secret Store. If any step fails, the devices which were already changed
+// are rolled back to the previous password.
+func (d *Driver) rotatePassword(secretPath string, devices []models.Device) errors.EdgeX {
+	if strings.ToLower(secretPath) == noAuthSecretPath {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("the %s credential group does not have a password", secretPath), nil)
+	}
+	// the new password can not be stored when using the InsecureSecrets, so fail before changing any device
+	if !secret.IsSecurityEnabled() {
+		return errors.NewCommonEdgeX(errors.KindNotAllowed, "password rotation requires the secure Secret Store", nil)
+	}
+
+	d.rotationMu.Lock()
+	defer d.rotationMu.Unlock()
+
+	credential, edgexErr := d.tryGetCredentials(secretPath)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("credential group '%s' does not exist in the Secret Store", secretPath), edgexErr)
+	}
+	if credential.AuthMode == AuthModeNone || credential.Username == "" {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("the credentials of secret path '%s' do not have a user", secretPath), nil)
+	}
+
+	newPassword, err := generatePassword(rotatedPasswordLength)
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, "failed to generate a new password", err)
+	}
+	newCredential := credential
+	newCredential.Password = newPassword
+
+	var changed []models.Device
+	rollback := func() {
+		for i := len(changed) - 1; i >= 0; i-- {
+			if rollbackErr := d.rollbackDevicePassword(changed[i], credential, newCredential); rollbackErr != nil {
+				d.lc.Errorf("Failed to roll back the password of user %s on device %s, the device must be recovered manually: %s",
+					credential.Username, changed[i].Name, rollbackErr.Error())
+				continue
+			}
+			d.lc.Infof("Rolled back the password of user %s on device %s.", credential.Username, changed[i].Name)
+		}
+	}
+
+	for _, device := range devices {
+		if edgexErr = d.setDevicePassword(device, credential, newPassword); edgexErr != nil {
+			rollback()
+			return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to change the password of device '%s'", device.Name), edgexErr)
+		}
+		changed = append(changed, device)
+
+		if !d.tryCredentials(device, newCredential) {
+			rollback()
+			return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("device '%s' did not accept the new password", device.Name), nil)
+		}
+	}
+
+	err = d.sdkService.GetSecretProvider().StoreSecret(secretPath, map[string]string{
+		UsernameKey: newCredential.Username,
+		PasswordKey: newCredential.Password,
+		AuthModeKey: newCredential.AuthMode,
+	})
+	if err != nil {
+		rollback()
+		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to store the new password for the secret path '%s'", secretPath), err)
+	}
+
+	names := make([]string, 0, len(devices))
+	for _, device := range devices {
+		names = append(names, device.Name)
+	}
+	d.lc.Infof("Rotated the password of user %s for the secret path %s on the devices %v.", credential.Username, secretPath, names)
+
+	// refresh explicitly, in case no secret updated callback is registered for the secret path
+	d.refreshOnvifClients(names)
+	return nil
+}
+
+// rollbackDevicePassword changes the password of the device back to the previous password of the credential. The
+// device is authenticated with the new password, or with the previous password if the device did not accept the new
+// password, for example when the device acknowledged the change without applying it.
+func (d *Driver) rollbackDevicePassword(device models.Device, credential Credentials, newCredential Credentials) errors.EdgeX {
+	edgexErr := d.setDevicePassword(device, newCredential, credential.Password)
+	if edgexErr == nil {
+		return nil
+	}
+	d.lc.Debugf("Unable to roll back the password of device %s with the new password, trying the previous password: %s", device.Name, edgexErr.Error())
+	if previousErr := d.setDevicePassword(device, credential, credential.Password); previousErr != nil {
+		return errors.NewCommonEdgeX(errors.Kind(previousErr), fmt.Sprintf("device '%s' accepted neither the new nor the previous password", device.Name), previousErr)
+	}
+	return nil
+}
+
+// setDevicePassword authenticates to the device with the credentials, and changes the password of the
+// credentials' user. The user level of the user is kept.
+func (d *Driver) setDevicePassword(device models.Device, credential Credentials, password string) errors.EdgeX {
+	devClient, edgexErr := d.newTemporaryOnvifClientWithCredentials(device, credential)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
+	usersResponse, edgexErr := devClient.callOnvifFunction(onvif.DeviceWebService, onvif.GetUsers, []byte{})
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	users, ok := usersResponse.(*onvifdevice.GetUsersResponse)
+	if !ok {
+		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetUsersResponse of type %T for the camera %s", usersResponse, device.Name), nil)
+	}
+
+	var userLevel *xsdOnvif.UserLevel
+	for _, user := range users.User {
+		if user.Username == credential.Username {
+			userLevel = user.UserLevel
+			break
+		}
+	}
+	if userLevel == nil {
+		return errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("user '%s' not found on the camera %s", credential.Username, device.Name), nil)
+	}
+
+	data, err := json.Marshal(onvifdevice.SetUser{
+		User: []xsdOnvif.UserRequest{{
+			Username:  credential.Username,
+			Password:  password,
+			UserLevel: userLevel,
+		}},
+	})
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal SetUser request", err)
+	}
+
+	_, edgexErr = devClient.callOnvifFunction(onvif.DeviceWebService, onvif.SetUser, data)
+	return edgexErr
+}
+
+// generatePassword returns a random password of the specified length
+func generatePassword(length int) (string, error) {
+	max := big.NewInt(int64(len(rotatedPasswordCharset)))
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = rotatedPasswordCharset[n.Int64()]
+	}
+	return string(password), nil
+}
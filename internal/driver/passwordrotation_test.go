@@ -0,0 +1,289 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-bootstrap/v2/bootstrap/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/clients/logger"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRotationUser     = "admin"
+	testRotationPassword = "old-password"
+	testRotationEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://www.w3.org/2003/05/soap-envelope"><Header /><Body>%s</Body></Envelope>`
+	testRotationFault = `<Fault><Code><Value>Sender</Value></Code><Reason><Text>request rejected</Text></Reason></Fault>`
+)
+
+var (
+	wsUsernameRegex = regexp.MustCompile(`<Username>([^<]*)</Username>`)
+	wsPasswordRegex = regexp.MustCompile(`<Password Type="[^"]*">([^<]*)</Password>`)
+	wsNonceRegex    = regexp.MustCompile(`<Nonce EncodingType="[^"]*">([^<]*)</Nonce>`)
+	wsCreatedRegex  = regexp.MustCompile(`<Created[^>]*>([^<]*)</Created>`)
+	setUserRegex    = regexp.MustCompile(`<onvif:Password>([^<]*)</onvif:Password>`)
+)
+
+// testCamera is a minimal Onvif camera which verifies the UsernameToken of every request, and supports changing
+// the password of its single user
+type testCamera struct {
+	mu          sync.Mutex
+	password    string
+	failSetUser bool
+	// ignoreSetUser accepts the password changes without applying them
+	ignoreSetUser bool
+	setUserCalled int
+	// streamURI is the stream uri of the single media profile of the camera, which has no media profile if empty
+	streamURI string
+	// systemDateTime is the UTC clock of the camera, which does not answer GetSystemDateAndTime if zero
+	systemDateTime time.Time
+	server         *httptest.Server
+}
+
+func newTestCamera(t *testing.T) *testCamera {
+	camera := &testCamera{password: testRotationPassword}
+	camera.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+		status, content := camera.handle(string(body))
+		if strings.Contains(string(body), "GetCapabilities") && camera.streamURI != "" {
+			// the media service is at the same address as the device service
+			content = "<GetCapabilitiesResponse><Capabilities><Media><XAddr>" + camera.server.URL + "</XAddr></Media></Capabilities></GetCapabilitiesResponse>"
+			_, err = writer.Write([]byte(fmt.Sprintf(testRotationEnvelope, content)))
+			assert.NoError(t, err)
+			return
+		}
+		if status == http.StatusOK {
+			content = "<Content>" + content + "</Content>"
+		} else {
+			content = testRotationFault
+		}
+		writer.WriteHeader(status)
+		_, err = writer.Write([]byte(fmt.Sprintf(testRotationEnvelope, content)))
+		assert.NoError(t, err)
+	}))
+	return camera
+}
+
+func (c *testCamera) handle(body string) (int, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the capabilities are requested when creating the client, and do not require authentication
+	if strings.Contains(body, "GetCapabilities") {
+		return http.StatusOK, ""
+	}
+	if strings.Contains(body, "GetSystemDateAndTime") && !c.systemDateTime.IsZero() {
+		return http.StatusOK, fmt.Sprintf("<SystemDateAndTime><UTCDateTime>"+
+			"<Time><Hour>%d</Hour><Minute>%d</Minute><Second>%d</Second></Time>"+
+			"<Date><Year>%d</Year><Month>%d</Month><Day>%d</Day></Date></UTCDateTime></SystemDateAndTime>",
+			c.systemDateTime.Hour(), c.systemDateTime.Minute(), c.systemDateTime.Second(),
+			c.systemDateTime.Year(), c.systemDateTime.Month(), c.systemDateTime.Day())
+	}
+	if !c.authenticated(body) {
+		return http.StatusUnauthorized, ""
+	}
+
+	switch {
+	case strings.Contains(body, "GetUsers"):
+		return http.StatusOK, "<User><Username>" + testRotationUser + "</Username><UserLevel>Administrator</UserLevel></User>"
+	case strings.Contains(body, "SetUser"):
+		c.setUserCalled++
+		if c.failSetUser {
+			return http.StatusBadRequest, ""
+		}
+		if c.ignoreSetUser {
+			return http.StatusOK, ""
+		}
+		c.password = setUserRegex.FindStringSubmatch(body)[1]
+	case strings.Contains(body, "GetProfiles") && c.streamURI != "":
+		return http.StatusOK, `<Profiles token="main"><Name>MainStream</Name><VideoEncoderConfiguration><Encoding>H264</Encoding>` +
+			`<Resolution><Width>1920</Width><Height>1080</Height></Resolution></VideoEncoderConfiguration></Profiles>`
+	case strings.Contains(body, "GetStreamUri") && c.streamURI != "":
+		return http.StatusOK, "<MediaUri><Uri>" + c.streamURI + "</Uri></MediaUri>"
+	}
+	return http.StatusOK, ""
+}
+
+func (c *testCamera) authenticated(body string) bool {
+	username := wsUsernameRegex.FindStringSubmatch(body)
+	digest := wsPasswordRegex.FindStringSubmatch(body)
+	nonce := wsNonceRegex.FindStringSubmatch(body)
+	created := wsCreatedRegex.FindStringSubmatch(body)
+	if username == nil || digest == nil || nonce == nil || created == nil || username[1] != testRotationUser {
+		return false
+	}
+	decodedNonce, _ := base64.StdEncoding.DecodeString(nonce[1])
+	hash := sha1.Sum([]byte(string(decodedNonce) + created[1] + c.password))
+	return base64.StdEncoding.EncodeToString(hash[:]) == digest[1]
+}
+
+func (c *testCamera) currentPassword() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.password
+}
+
+func (c *testCamera) device(t *testing.T, name string) models.Device {
+	serverURL, err := url.Parse(c.server.URL)
+	require.NoError(t, err)
+	return models.Device{Name: name, Protocols: map[string]models.ProtocolProperties{
+		OnvifProtocol: {Address: serverURL.Hostname(), Port: serverURL.Port()},
+	}}
+}
+
+// createTestCameraDriver creates a driver whose default credentials are the credentials of the testCamera
+func createTestCameraDriver(t *testing.T, authMode string, devices []models.Device) (*Driver, *mocks.SecretProvider) {
+	driver, mockService := createDriverWithMockService()
+	driver.macAddressMapper = NewMACAddressMapper(mockService)
+	driver.clientsMu = new(sync.RWMutex)
+	driver.configMu = new(sync.RWMutex)
+	driver.config = &ServiceConfig{AppCustom: CustomConfig{
+		DefaultSecretPath: "creds1",
+		RequestTimeout:    1,
+	}}
+	driver.onvifClients = make(map[string]*OnvifClient)
+	for _, device := range devices {
+		driver.onvifClients[device.Name], _ = createOnvifClientWithMockDevice(driver, device.Name)
+		mockService.On("GetDeviceByName", device.Name).Return(device, nil)
+	}
+
+	mockSecretProvider := &mocks.SecretProvider{}
+	mockSecretProvider.On("GetSecret", "creds1", UsernameKey, PasswordKey, AuthModeKey).
+		Return(map[string]string{UsernameKey: testRotationUser, PasswordKey: testRotationPassword, AuthModeKey: authMode}, nil)
+	mockService.On("GetSecretProvider").Return(mockSecretProvider)
+	mockService.On("GetLoggingClient").Return(logger.NewMockClient())
+	mockService.On("Devices").Return(devices)
+
+	return driver, mockSecretProvider
+}
+
+func TestRotateCredentialGroupPassword(t *testing.T) {
+	camera1 := newTestCamera(t)
+	defer camera1.server.Close()
+	camera2 := newTestCamera(t)
+	defer camera2.server.Close()
+
+	driver, mockSecretProvider := createTestCameraDriver(t, AuthModeUsernameToken, []models.Device{
+		camera1.device(t, "cam1"),
+		camera2.device(t, "cam2"),
+	})
+	mockSecretProvider.On("StoreSecret", "creds1", mock.Anything).Return(nil)
+
+	devices, err := driver.rotateCredentialGroupPassword("creds1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cam1", "cam2"}, devices)
+
+	newPassword := camera1.currentPassword()
+	assert.NotEqual(t, testRotationPassword, newPassword)
+	assert.Len(t, newPassword, rotatedPasswordLength)
+	assert.Equal(t, newPassword, camera2.currentPassword())
+	mockSecretProvider.AssertCalled(t, "StoreSecret", "creds1", map[string]string{
+		UsernameKey: testRotationUser,
+		PasswordKey: newPassword,
+		AuthModeKey: AuthModeUsernameToken,
+	})
+}
+
+func TestRotateCredentialGroupPassword_rollback(t *testing.T) {
+	tests := []struct {
+		name           string
+		failSetUser    bool
+		ignoreSetUser  bool
+		storeSecretErr error
+	}{
+		{
+			name:        "device rejects the password change",
+			failSetUser: true,
+		},
+		{
+			name:          "device rejects the new password after the change",
+			ignoreSetUser: true,
+		},
+		{
+			name:           "secret store fails",
+			storeSecretErr: errors.New("secret store unavailable"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			camera1 := newTestCamera(t)
+			defer camera1.server.Close()
+			camera2 := newTestCamera(t)
+			defer camera2.server.Close()
+			camera2.failSetUser = test.failSetUser
+			camera2.ignoreSetUser = test.ignoreSetUser
+
+			driver, mockSecretProvider := createTestCameraDriver(t, AuthModeUsernameToken, []models.Device{
+				camera1.device(t, "cam1"),
+				camera2.device(t, "cam2"),
+			})
+			mockSecretProvider.On("StoreSecret", "creds1", mock.Anything).Return(test.storeSecretErr)
+
+			_, err := driver.rotateCredentialGroupPassword("creds1")
+			require.Error(t, err)
+
+			assert.Equal(t, testRotationPassword, camera1.currentPassword())
+			assert.Equal(t, testRotationPassword, camera2.currentPassword())
+			// the first camera was changed and then rolled back
+			assert.Equal(t, 2, camera1.setUserCalled)
+			if test.ignoreSetUser {
+				// the second camera still uses the previous password, so it is rolled back with it
+				assert.Equal(t, 2, camera2.setUserCalled)
+			}
+			if test.failSetUser || test.ignoreSetUser {
+				mockSecretProvider.AssertNotCalled(t, "StoreSecret", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestRotateDevicePassword_sharedCredentials(t *testing.T) {
+	camera := newTestCamera(t)
+	defer camera.server.Close()
+
+	driver, _ := createTestCameraDriver(t, AuthModeUsernameToken, []models.Device{
+		camera.device(t, "cam1"),
+		camera.device(t, "cam2"),
+	})
+
+	err := driver.rotateDevicePassword("cam1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cam2")
+	assert.Equal(t, 0, camera.setUserCalled)
+}
+
+func TestGeneratePassword(t *testing.T) {
+	password, err := generatePassword(rotatedPasswordLength)
+	require.NoError(t, err)
+	assert.Len(t, password, rotatedPasswordLength)
+	for _, c := range password {
+		assert.Contains(t, rotatedPasswordCharset, string(c))
+	}
+
+	other, err := generatePassword(rotatedPasswordLength)
+	require.NoError(t, err)
+	assert.NotEqual(t, password, other)
+}
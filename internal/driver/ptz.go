@@ -0,0 +1,488 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/IOTechSystems/onvif/ptz"
+	"github.com/IOTechSystems/onvif/xsd"
+	xsdOnvif "github.com/IOTechSystems/onvif/xsd/onvif"
+)
+
+// PTZMoveRequest holds the parameters of the PTZMoveTo and PTZNudge commands. The pan and tilt are normalized from -1
+// to 1. The zoom is normalized from 0 to 1 for the PTZMoveTo command, and from -1 to 1 for the PTZNudge command.
+type PTZMoveRequest struct {
+	// ProfileToken is the token of the media profile of the PTZ node, the first media profile with a PTZ
+	// configuration is used by default
+	ProfileToken string
+	Pan          *float64
+	Tilt         *float64
+	Zoom         *float64
+	// Speed is the speed of the move normalized from 0 to 1, the default speed of the camera is used by default
+	Speed *float64
+}
+
+// PTZStopRequest holds the parameters of the PTZStop command
+type PTZStopRequest struct {
+	ProfileToken string
+	// PanTilt stops the pan and tilt movements, true by default
+	PanTilt *bool
+	// Zoom stops the zoom movement, true by default
+	Zoom *bool
+}
+
+// PTZPresetRequest holds the parameters of the PTZGotoPreset command
+type PTZPresetRequest struct {
+	ProfileToken string
+	// Name is the name of the preset
+	Name string
+}
+
+// ptzTarget is the media profile used to move the camera, along with the spaces of its PTZ node
+type ptzTarget struct {
+	profileToken string
+	spaces       xsdOnvif.PTZSpaces
+}
+
+// PTZCache keeps the PTZ targets of every device, so the media profile and PTZ node are not resolved for every move.
+// The cache of a device is cleared when one of its PTZ commands fails.
+type PTZCache struct {
+	mu      sync.Mutex
+	targets map[string]map[string]ptzTarget
+}
+
+// NewPTZCache creates a new PTZCache
+func NewPTZCache() *PTZCache {
+	return &PTZCache{
+		targets: make(map[string]map[string]ptzTarget),
+	}
+}
+
+// target returns the cached PTZ target of the requested profile token of the device
+func (c *PTZCache) target(deviceName string, profileToken string) (ptzTarget, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	target, found := c.targets[deviceName][profileToken]
+	return target, found
+}
+
+// setTarget caches the PTZ target of the requested profile token of the device
+func (c *PTZCache) setTarget(deviceName string, profileToken string, target ptzTarget) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.targets[deviceName] == nil {
+		c.targets[deviceName] = make(map[string]ptzTarget)
+	}
+	c.targets[deviceName][profileToken] = target
+}
+
+// Remove clears the cache of the device
+func (c *PTZCache) Remove(deviceName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.targets, deviceName)
+}
+
+// The vectors of the onvif library omit the coordinates which are 0, so the PTZ moves are defined by the driver
+
+// ptzVector2D is a pan and tilt position, translation or speed in the space
+type ptzVector2D struct {
+	X     float64 `xml:"x,attr"`
+	Y     float64 `xml:"y,attr"`
+	Space string  `xml:"space,attr,omitempty"`
+}
+
+// ptzVector1D is a zoom position, translation or speed in the space
+type ptzVector1D struct {
+	X     float64 `xml:"x,attr"`
+	Space string  `xml:"space,attr,omitempty"`
+}
+
+// ptzVector is a pan, tilt and zoom vector
+type ptzVector struct {
+	PanTilt *ptzVector2D `xml:"onvif:PanTilt,omitempty"`
+	Zoom    *ptzVector1D `xml:"onvif:Zoom,omitempty"`
+}
+
+// ptzAbsoluteMove is the PTZ AbsoluteMove request
+type ptzAbsoluteMove struct {
+	XMLName      string     `xml:"tptz:AbsoluteMove"`
+	ProfileToken string     `xml:"tptz:ProfileToken"`
+	Position     ptzVector  `xml:"tptz:Position"`
+	Speed        *ptzVector `xml:"tptz:Speed,omitempty"`
+}
+
+func (*ptzAbsoluteMove) webService() string {
+	return onvif.PTZWebService
+}
+
+// ptzAbsoluteMoveFunction is the onvif.Function of the PTZ AbsoluteMove request
+type ptzAbsoluteMoveFunction struct{}
+
+func (*ptzAbsoluteMoveFunction) Request() interface{} {
+	return &ptzAbsoluteMove{}
+}
+
+func (*ptzAbsoluteMoveFunction) Response() interface{} {
+	return &ptz.AbsoluteMoveResponse{}
+}
+
+// ptzRelativeMove is the PTZ RelativeMove request
+type ptzRelativeMove struct {
+	XMLName      string     `xml:"tptz:RelativeMove"`
+	ProfileToken string     `xml:"tptz:ProfileToken"`
+	Translation  ptzVector  `xml:"tptz:Translation"`
+	Speed        *ptzVector `xml:"tptz:Speed,omitempty"`
+}
+
+func (*ptzRelativeMove) webService() string {
+	return onvif.PTZWebService
+}
+
+// ptzRelativeMoveFunction is the onvif.Function of the PTZ RelativeMove request
+type ptzRelativeMoveFunction struct{}
+
+func (*ptzRelativeMoveFunction) Request() interface{} {
+	return &ptzRelativeMove{}
+}
+
+func (*ptzRelativeMoveFunction) Response() interface{} {
+	return &ptz.RelativeMoveResponse{}
+}
+
+// ptzGetConfigurationsResponse is the response of the PTZ GetConfigurations request holding the node tokens of the
+// PTZ configurations, which the PTZ configuration of the onvif library can't unmarshal
+type ptzGetConfigurationsResponse struct {
+	PTZConfiguration []struct {
+		Token     string `xml:"token,attr"`
+		NodeToken string
+	}
+}
+
+// ptzGetConfigurationsFunction is the onvif.Function of the PTZ GetConfigurations request
+type ptzGetConfigurationsFunction struct{}
+
+func (*ptzGetConfigurationsFunction) Request() interface{} {
+	return &ptz.GetConfigurations{}
+}
+
+func (*ptzGetConfigurationsFunction) Response() interface{} {
+	return &ptzGetConfigurationsResponse{}
+}
+
+// parsePTZMoveRequest parses the parameters of the PTZMoveTo command if absolute is true, otherwise of the PTZNudge
+// command, and checks the values are normalized
+func parsePTZMoveRequest(data []byte, absolute bool) (PTZMoveRequest, errors.EdgeX) {
+	var request PTZMoveRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, "failed to unmarshal the PTZ move parameters", err)
+	}
+	if request.Pan == nil && request.Tilt == nil && request.Zoom == nil {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, "at least one of Pan, Tilt or Zoom is required", nil)
+	}
+	if absolute && (request.Pan == nil) != (request.Tilt == nil) {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, "Pan and Tilt must be set together", nil)
+	}
+	zoomMin := -1.0
+	if absolute {
+		zoomMin = 0
+	}
+	for _, value := range []struct {
+		name     string
+		value    *float64
+		min, max float64
+	}{
+		{"Pan", request.Pan, -1, 1},
+		{"Tilt", request.Tilt, -1, 1},
+		{"Zoom", request.Zoom, zoomMin, 1},
+		{"Speed", request.Speed, 0, 1},
+	} {
+		if value.value != nil && (*value.value < value.min || *value.value > value.max) {
+			return request, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("%s must be between %v and %v", value.name, value.min, value.max), nil)
+		}
+	}
+	if request.Speed != nil && *request.Speed == 0 {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, "Speed must be greater than 0", nil)
+	}
+	return request, nil
+}
+
+// callPTZMoveToFunction moves the camera to the absolute normalized pan, tilt and zoom position
+func (onvifClient *OnvifClient) callPTZMoveToFunction(data []byte) errors.EdgeX {
+	request, edgexErr := parsePTZMoveRequest(data, true)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	target, edgexErr := onvifClient.resolvePTZTarget(request.ProfileToken)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	spaces := target.spaces
+	position, edgexErr := ptzVectorInSpaces(request, -1, 0, spaces.AbsolutePanTiltPositionSpace, spaces.AbsoluteZoomPositionSpace, "absolute")
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	move := ptzAbsoluteMove{ProfileToken: target.profileToken, Position: position, Speed: ptzSpeed(request, spaces)}
+	return onvifClient.callPTZMoveFunction(onvif.AbsoluteMove, &ptzAbsoluteMoveFunction{}, move)
+}
+
+// callPTZNudgeFunction moves the camera relatively to its current position. The normalized translation is mapped
+// onto the relative translation space of the camera, where 1 is the largest translation supported by the camera.
+func (onvifClient *OnvifClient) callPTZNudgeFunction(data []byte) errors.EdgeX {
+	request, edgexErr := parsePTZMoveRequest(data, false)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	// the axis which is not set is not moved
+	zero := 0.0
+	if request.Pan != nil && request.Tilt == nil {
+		request.Tilt = &zero
+	} else if request.Tilt != nil && request.Pan == nil {
+		request.Pan = &zero
+	}
+	target, edgexErr := onvifClient.resolvePTZTarget(request.ProfileToken)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	spaces := target.spaces
+	translation, edgexErr := ptzVectorInSpaces(request, -1, -1, spaces.RelativePanTiltTranslationSpace, spaces.RelativeZoomTranslationSpace, "relative")
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	move := ptzRelativeMove{ProfileToken: target.profileToken, Translation: translation, Speed: ptzSpeed(request, spaces)}
+	return onvifClient.callPTZMoveFunction(onvif.RelativeMove, &ptzRelativeMoveFunction{}, move)
+}
+
+// callPTZMoveFunction sends the PTZ move request, and clears the cached PTZ targets of the device if it fails
+func (onvifClient *OnvifClient) callPTZMoveFunction(functionName string, function onvif.Function, move interface{}) errors.EdgeX {
+	requestData, err := json.Marshal(move)
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to marshal the %s request", functionName), err)
+	}
+	if _, edgexErr := onvifClient.callFunction(onvif.PTZWebService, functionName, function, requestData); edgexErr != nil {
+		onvifClient.driver.ptzCache.Remove(onvifClient.DeviceName)
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	return nil
+}
+
+// callPTZStopFunction stops the ongoing pan, tilt and zoom movements of the camera
+func (onvifClient *OnvifClient) callPTZStopFunction(data []byte) errors.EdgeX {
+	var request PTZStopRequest
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &request); err != nil {
+			return errors.NewCommonEdgeX(errors.KindContractInvalid, "failed to unmarshal the PTZStop parameters", err)
+		}
+	}
+	target, edgexErr := onvifClient.resolvePTZTarget(request.ProfileToken)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	requestData, err := json.Marshal(ptz.Stop{
+		ProfileToken: xsdOnvif.ReferenceToken(target.profileToken),
+		PanTilt:      xsd.Boolean(request.PanTilt == nil || *request.PanTilt),
+		Zoom:         xsd.Boolean(request.Zoom == nil || *request.Zoom),
+	})
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the Stop request", err)
+	}
+	if _, edgexErr = onvifClient.callOnvifFunction(onvif.PTZWebService, onvif.Stop, requestData); edgexErr != nil {
+		onvifClient.driver.ptzCache.Remove(onvifClient.DeviceName)
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	return nil
+}
+
+// callPTZGotoPresetFunction moves the camera to the preset with the name. The name is matched exactly, or ignoring
+// the case if no preset has the exact name.
+func (onvifClient *OnvifClient) callPTZGotoPresetFunction(data []byte) errors.EdgeX {
+	var request PTZPresetRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "failed to unmarshal the PTZGotoPreset parameters", err)
+	}
+	if strings.TrimSpace(request.Name) == "" {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "the Name of the preset is required", nil)
+	}
+	target, edgexErr := onvifClient.resolvePTZTarget(request.ProfileToken)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
+	requestData, err := json.Marshal(ptz.GetPresets{ProfileToken: xsdOnvif.ReferenceToken(target.profileToken)})
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the GetPresets request", err)
+	}
+	respContent, edgexErr := onvifClient.callOnvifFunction(onvif.PTZWebService, onvif.GetPresets, requestData)
+	if edgexErr != nil {
+		onvifClient.driver.ptzCache.Remove(onvifClient.DeviceName)
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	presetsResp, ok := respContent.(*ptz.GetPresetsResponse)
+	if !ok {
+		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetPresetsResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+	}
+	presetToken, found := findPresetToken(presetsResp.Preset, request.Name)
+	if !found {
+		names := make([]string, 0, len(presetsResp.Preset))
+		for _, preset := range presetsResp.Preset {
+			names = append(names, string(preset.Name))
+		}
+		return errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("preset '%s' not found, the presets of the camera are %v", request.Name, names), nil)
+	}
+
+	profileToken := xsdOnvif.ReferenceToken(target.profileToken)
+	requestData, err = json.Marshal(ptz.GotoPreset{ProfileToken: &profileToken, PresetToken: &presetToken})
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the GotoPreset request", err)
+	}
+	if _, edgexErr = onvifClient.callOnvifFunction(onvif.PTZWebService, onvif.GotoPreset, requestData); edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	return nil
+}
+
+// findPresetToken returns the token of the preset with the name, matched exactly or else ignoring the case
+func findPresetToken(presets []xsdOnvif.PTZPreset, name string) (xsdOnvif.ReferenceToken, bool) {
+	for _, preset := range presets {
+		if string(preset.Name) == name {
+			return preset.Token, true
+		}
+	}
+	for _, preset := range presets {
+		if strings.EqualFold(string(preset.Name), name) {
+			return preset.Token, true
+		}
+	}
+	return "", false
+}
+
+// resolvePTZTarget returns the media profile with the token, or the first media profile with a PTZ configuration if
+// the token is empty, along with the spaces of the PTZ node of its PTZ configuration
+func (onvifClient *OnvifClient) resolvePTZTarget(profileToken string) (ptzTarget, errors.EdgeX) {
+	cache := onvifClient.driver.ptzCache
+	if target, found := cache.target(onvifClient.DeviceName, profileToken); found {
+		return target, nil
+	}
+
+	profiles, _, edgexErr := onvifClient.getMediaProfiles("")
+	if edgexErr != nil {
+		return ptzTarget{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	var profile *xsdOnvif.Profile
+	for i := range profiles {
+		if profiles[i].PTZConfiguration != nil && (profileToken == "" || string(profiles[i].Token) == profileToken) {
+			profile = &profiles[i]
+			break
+		}
+	}
+	if profile == nil {
+		if profileToken != "" {
+			return ptzTarget{}, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("media profile with token '%s' and a PTZ configuration not found", profileToken), nil)
+		}
+		return ptzTarget{}, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, "no media profile with a PTZ configuration found", nil)
+	}
+
+	nodeToken, edgexErr := onvifClient.getPTZNodeToken(string(profile.PTZConfiguration.Token))
+	if edgexErr != nil {
+		return ptzTarget{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	respContent, edgexErr := onvifClient.callOnvifFunction(onvif.PTZWebService, onvif.GetNodes, nil)
+	if edgexErr != nil {
+		return ptzTarget{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	nodesResp, ok := respContent.(*ptz.GetNodesResponse)
+	if !ok {
+		return ptzTarget{}, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetNodesResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+	}
+	for _, node := range nodesResp.PTZNode {
+		if string(node.Token) != nodeToken {
+			continue
+		}
+		if node.SupportedPTZSpaces == nil {
+			return ptzTarget{}, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("the PTZ node '%s' does not describe its supported spaces", nodeToken), nil)
+		}
+		target := ptzTarget{profileToken: string(profile.Token), spaces: *node.SupportedPTZSpaces}
+		cache.setTarget(onvifClient.DeviceName, profileToken, target)
+		return target, nil
+	}
+	return ptzTarget{}, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("PTZ node with token '%s' not found", nodeToken), nil)
+}
+
+// getPTZNodeToken returns the token of the PTZ node of the PTZ configuration
+func (onvifClient *OnvifClient) getPTZNodeToken(configurationToken string) (string, errors.EdgeX) {
+	respContent, edgexErr := onvifClient.callFunction(onvif.PTZWebService, onvif.GetConfigurations, &ptzGetConfigurationsFunction{}, nil)
+	if edgexErr != nil {
+		return "", errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	configurationsResp, ok := respContent.(*ptzGetConfigurationsResponse)
+	if !ok {
+		return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetConfigurationsResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+	}
+	for _, configuration := range configurationsResp.PTZConfiguration {
+		if configuration.Token == configurationToken {
+			return strings.TrimSpace(configuration.NodeToken), nil
+		}
+	}
+	return "", errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("PTZ configuration with token '%s' not found", configurationToken), nil)
+}
+
+// ptzVectorInSpaces maps the normalized pan, tilt and zoom of the request onto the spaces of the PTZ node. The pan
+// and tilt are normalized from panTiltMin to 1, and the zoom from zoomMin to 1.
+func ptzVectorInSpaces(request PTZMoveRequest, panTiltMin float64, zoomMin float64, panTiltSpace xsdOnvif.Space2DDescription, zoomSpace xsdOnvif.Space1DDescription, move string) (ptzVector, errors.EdgeX) {
+	var vector ptzVector
+	if request.Pan != nil {
+		if panTiltSpace.URI == nil || panTiltSpace.XRange == nil || panTiltSpace.YRange == nil {
+			return vector, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("the camera does not support %s pan and tilt moves", move), nil)
+		}
+		vector.PanTilt = &ptzVector2D{
+			X:     scaleToRange(*request.Pan, panTiltMin, *panTiltSpace.XRange),
+			Y:     scaleToRange(*request.Tilt, panTiltMin, *panTiltSpace.YRange),
+			Space: string(*panTiltSpace.URI),
+		}
+	}
+	if request.Zoom != nil {
+		if zoomSpace.URI == "" {
+			return vector, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("the camera does not support %s zoom moves", move), nil)
+		}
+		vector.Zoom = &ptzVector1D{X: scaleToRange(*request.Zoom, zoomMin, zoomSpace.XRange), Space: string(zoomSpace.URI)}
+	}
+	return vector, nil
+}
+
+// ptzSpeed returns the speed vector of the normalized speed of the request, or nil to use the default speed
+func ptzSpeed(request PTZMoveRequest, spaces xsdOnvif.PTZSpaces) *ptzVector {
+	if request.Speed == nil {
+		return nil
+	}
+	var speed ptzVector
+	if request.Pan != nil && spaces.PanTiltSpeedSpace.URI != "" {
+		x := scaleToRange(*request.Speed, 0, spaces.PanTiltSpeedSpace.XRange)
+		speed.PanTilt = &ptzVector2D{X: x, Y: x, Space: string(spaces.PanTiltSpeedSpace.URI)}
+	}
+	if request.Zoom != nil && spaces.ZoomSpeedSpace.URI != "" {
+		speed.Zoom = &ptzVector1D{X: scaleToRange(*request.Speed, 0, spaces.ZoomSpeedSpace.XRange), Space: string(spaces.ZoomSpeedSpace.URI)}
+	}
+	if speed.PanTilt == nil && speed.Zoom == nil {
+		return nil
+	}
+	return &speed
+}
+
+// scaleToRange maps the value normalized from min to 1 onto the range
+func scaleToRange(value float64, min float64, r xsdOnvif.FloatRange) float64 {
+	return r.Min + (value-min)/(1-min)*(r.Max-r.Min)
+}
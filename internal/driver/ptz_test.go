@@ -0,0 +1,200 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edgexfoundry/device-onvif-camera/internal/driver/mocks"
+)
+
+const (
+	testPTZProfilesResponse = `<GetProfilesResponse>` +
+		`<Profiles token="main"><Name>MainStream</Name></Profiles>` +
+		`<Profiles token="ptz"><Name>PTZStream</Name><PTZConfiguration token="ptz1"><Name>ptz1</Name></PTZConfiguration></Profiles>` +
+		`</GetProfilesResponse>`
+	testPTZConfigurationsResponse = `<GetConfigurationsResponse>` +
+		`<PTZConfiguration token="ptz0"><Name>ptz0</Name><NodeToken>node0</NodeToken></PTZConfiguration>` +
+		`<PTZConfiguration token="ptz1"><Name>ptz1</Name><NodeToken>node1</NodeToken></PTZConfiguration>` +
+		`</GetConfigurationsResponse>`
+	testPTZNodesResponse = `<GetNodesResponse><PTZNode token="node1"><Name>node1</Name><SupportedPTZSpaces>` +
+		`<AbsolutePanTiltPositionSpace><URI>http://www.onvif.org/ver10/tptz/PanTiltSpaces/PositionGenericSpace</URI>` +
+		`<XRange><Min>-180</Min><Max>180</Max></XRange><YRange><Min>-90</Min><Max>90</Max></YRange></AbsolutePanTiltPositionSpace>` +
+		`<AbsoluteZoomPositionSpace><URI>http://www.onvif.org/ver10/tptz/ZoomSpaces/PositionGenericSpace</URI>` +
+		`<XRange><Min>0</Min><Max>10</Max></XRange></AbsoluteZoomPositionSpace>` +
+		`<RelativePanTiltTranslationSpace><URI>http://www.onvif.org/ver10/tptz/PanTiltSpaces/TranslationGenericSpace</URI>` +
+		`<XRange><Min>-20</Min><Max>20</Max></XRange><YRange><Min>-10</Min><Max>10</Max></YRange></RelativePanTiltTranslationSpace>` +
+		`<PanTiltSpeedSpace><URI>http://www.onvif.org/ver10/tptz/PanTiltSpaces/GenericSpeedSpace</URI>` +
+		`<XRange><Min>0</Min><Max>2</Max></XRange></PanTiltSpeedSpace>` +
+		`</SupportedPTZSpaces></PTZNode></GetNodesResponse>`
+	testPTZPresetsResponse = `<GetPresetsResponse>` +
+		`<Preset token="1"><Name>Entrance</Name></Preset>` +
+		`<Preset token="2"><Name>Parking Lot</Name></Preset>` +
+		`</GetPresetsResponse>`
+)
+
+// mockPTZCamera mocks a camera with a PTZ node on its second media profile, and sends the bodies of the requests
+// moving the camera to the channel
+func mockPTZCamera(mockDevice *mocks.OnvifDevice, moveRequests chan<- string) {
+	mockDevice.On("GetEndpointByRequestStruct", mock.Anything).Return("http://camera/onvif/service", nil)
+	mockDevice.On("GetEndpoint", "device").Return("")
+	mockDevice.On("GetEndpoint", "ptz").Return("http://camera/onvif/ptz_service")
+	mockDevice.On("SendSoap", mock.Anything, mock.Anything).Return(func(_ string, body string) *http.Response {
+		var content string
+		switch {
+		case strings.Contains(body, "GetProfiles"):
+			content = testPTZProfilesResponse
+		case strings.Contains(body, "tptz:GetConfigurations"):
+			content = testPTZConfigurationsResponse
+		case strings.Contains(body, "tptz:GetNodes"):
+			content = testPTZNodesResponse
+		case strings.Contains(body, "tptz:GetPresets"):
+			content = testPTZPresetsResponse
+		case strings.Contains(body, "tptz:AbsoluteMove"), strings.Contains(body, "tptz:RelativeMove"),
+			strings.Contains(body, "tptz:Stop"), strings.Contains(body, "tptz:GotoPreset"):
+			moveRequests <- body
+		}
+		response := fmt.Sprintf(testRotationEnvelope, content)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(response))}
+	}, nil)
+}
+
+func TestParsePTZMoveRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		absolute      bool
+		errorExpected bool
+	}{
+		{name: "absolute pan and tilt", data: `{"Pan":-1,"Tilt":1}`, absolute: true},
+		{name: "absolute zoom with speed", data: `{"Zoom":0.5,"Speed":1}`, absolute: true},
+		{name: "absolute pan without tilt", data: `{"Pan":0.5}`, absolute: true, errorExpected: true},
+		{name: "absolute negative zoom", data: `{"Zoom":-0.5}`, absolute: true, errorExpected: true},
+		{name: "relative pan only", data: `{"Pan":0.5}`},
+		{name: "relative negative zoom", data: `{"Zoom":-0.5}`},
+		{name: "pan out of range", data: `{"Pan":1.5,"Tilt":0}`, errorExpected: true},
+		{name: "zero speed", data: `{"Zoom":0.5,"Speed":0}`, errorExpected: true},
+		{name: "no axis", data: `{"ProfileToken":"ptz"}`, errorExpected: true},
+		{name: "invalid json", data: `{`, errorExpected: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parsePTZMoveRequest([]byte(test.data), test.absolute)
+			if test.errorExpected {
+				require.Error(t, err)
+				assert.Equal(t, errors.KindContractInvalid, errors.Kind(err))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestOnvifClient_callPTZMoveToFunction(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	moveRequests := make(chan string, 1)
+	mockPTZCamera(mockDevice, moveRequests)
+
+	err := onvifClient.callPTZMoveToFunction([]byte(`{"Pan":0.5,"Tilt":-1,"Zoom":0,"Speed":0.5}`))
+	require.NoError(t, err)
+	body := <-moveRequests
+	assert.Contains(t, body, `<tptz:ProfileToken>ptz</tptz:ProfileToken>`)
+	// the normalized coordinates are mapped onto the ranges of the node, and the zero coordinates are kept
+	assert.Contains(t, body, `<onvif:PanTilt x="90" y="-90" space="http://www.onvif.org/ver10/tptz/PanTiltSpaces/PositionGenericSpace">`)
+	assert.Contains(t, body, `<onvif:Zoom x="0" space="http://www.onvif.org/ver10/tptz/ZoomSpaces/PositionGenericSpace">`)
+	assert.Contains(t, body, `<tptz:Speed><onvif:PanTilt x="1" y="1" space="http://www.onvif.org/ver10/tptz/PanTiltSpaces/GenericSpeedSpace">`)
+
+	// the PTZ target is cached
+	_, found := driver.ptzCache.target(testDeviceName, "")
+	assert.True(t, found)
+}
+
+func TestOnvifClient_callPTZNudgeFunction(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	moveRequests := make(chan string, 1)
+	mockPTZCamera(mockDevice, moveRequests)
+
+	err := onvifClient.callPTZNudgeFunction([]byte(`{"ProfileToken":"ptz","Pan":-0.5}`))
+	require.NoError(t, err)
+	body := <-moveRequests
+	assert.Contains(t, body, `<tptz:RelativeMove>`)
+	assert.Contains(t, body, `<onvif:PanTilt x="-10" y="0" space="http://www.onvif.org/ver10/tptz/PanTiltSpaces/TranslationGenericSpace">`)
+	assert.NotContains(t, body, `tptz:Speed`)
+
+	// the node has no relative zoom space
+	err = onvifClient.callPTZNudgeFunction([]byte(`{"Zoom":0.5}`))
+	require.Error(t, err)
+	assert.Equal(t, errors.KindContractInvalid, errors.Kind(err))
+
+	err = onvifClient.callPTZNudgeFunction([]byte(`{"ProfileToken":"main","Pan":0.5}`))
+	require.Error(t, err)
+	assert.Equal(t, errors.KindEntityDoesNotExist, errors.Kind(err))
+}
+
+func TestOnvifClient_callPTZStopFunction(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	moveRequests := make(chan string, 1)
+	mockPTZCamera(mockDevice, moveRequests)
+
+	err := onvifClient.callPTZStopFunction([]byte(`{"Zoom":false}`))
+	require.NoError(t, err)
+	body := <-moveRequests
+	assert.Contains(t, body, `<tptz:ProfileToken>ptz</tptz:ProfileToken>`)
+	assert.Contains(t, body, `<tptz:PanTilt>true</tptz:PanTilt>`)
+	assert.Contains(t, body, `<tptz:Zoom>false</tptz:Zoom>`)
+}
+
+func TestOnvifClient_callPTZGotoPresetFunction(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		presetToken   string
+		errorExpected bool
+		errorKind     errors.ErrKind
+	}{
+		{name: "exact name", data: `{"Name":"Entrance"}`, presetToken: "1"},
+		{name: "case insensitive name", data: `{"Name":"parking lot"}`, presetToken: "2"},
+		{name: "unknown name", data: `{"Name":"Lobby"}`, errorExpected: true, errorKind: errors.KindEntityDoesNotExist},
+		{name: "missing name", data: `{}`, errorExpected: true, errorKind: errors.KindContractInvalid},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			driver, _ := createDriverWithMockService()
+			onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+			moveRequests := make(chan string, 1)
+			mockPTZCamera(mockDevice, moveRequests)
+
+			err := onvifClient.callPTZGotoPresetFunction([]byte(test.data))
+			if test.errorExpected {
+				require.Error(t, err)
+				assert.Equal(t, test.errorKind, errors.Kind(err))
+				assert.Empty(t, moveRequests)
+				return
+			}
+			require.NoError(t, err)
+			body := <-moveRequests
+			assert.Contains(t, body, `<tptz:ProfileToken>ptz</tptz:ProfileToken>`)
+			assert.Contains(t, body, fmt.Sprintf(`<tptz:PresetToken>%s</tptz:PresetToken>`, test.presetToken))
+		})
+	}
+}
@@ -49,11 +49,7 @@ func (manager *PullPointManager) NewSubscriber(onvifClient *OnvifClient, resourc
 		return errors.NewCommonEdgeXWrapper(edgexErr)
 	}
 
-	onvifClient.driver.configMu.RLock()
-	requestTimeout := onvifClient.driver.config.AppCustom.RequestTimeout
-	onvifClient.driver.configMu.RUnlock()
-
-	onvifDevice, err := manager.newSubscriberOnvifDevice(onvifClient.onvifDevice, *request.MessageTimeout, requestTimeout)
+	onvifDevice, err := manager.newSubscriberOnvifDevice(onvifClient.getOnvifDevice(), *request.MessageTimeout, onvifClient.getRequestPolicy().Timeout)
 	if edgexErr != nil {
 		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create onvif device for pulling event, %v", err), edgexErr)
 	}
@@ -73,24 +69,52 @@ func (manager *PullPointManager) NewSubscriber(onvifClient *OnvifClient, resourc
 	if edgexErr != nil {
 		return errors.NewCommonEdgeX(errors.Kind(edgexErr), fmt.Sprintf("failed to create the PullPoint subscription for resource '%s'", sub.Name), edgexErr)
 	}
+	if onvifClient.getRequestPolicy().MaxConcurrentRequests == 1 {
+		manager.lc.Warnf("The event pulls of device %s hold its only request slot for up to the MessageTimeout, "+
+			"so the other requests wait for them. Use a MaxConcurrentRequests of at least 2 with pull point subscriptions.", onvifClient.DeviceName)
+	}
 	manager.addSubscriber(sub)
 	go sub.StartPullMessageLoop()
 	return nil
 }
 
-func (manager *PullPointManager) newSubscriberOnvifDevice(device OnvifDevice, messageTimeout string, httpRequestTimeout int) (OnvifDevice, error) {
+func (manager *PullPointManager) newSubscriberOnvifDevice(device OnvifDevice, messageTimeout string, httpRequestTimeout time.Duration) (OnvifDevice, error) {
 	timeout, err := ParseISO8601(messageTimeout)
 	if err != nil {
 		return nil, err
 	}
-	timeout = timeout + time.Duration(httpRequestTimeout)*time.Second
+	timeout = timeout + httpRequestTimeout
 	params := device.GetDeviceParams()
+	// keep the transport of the device, which holds its TLS settings
+	var transport http.RoundTripper
+	if params.HttpClient != nil {
+		transport = params.HttpClient.Transport
+	}
 	params.HttpClient = &http.Client{
-		Timeout: timeout,
+		Timeout:   timeout,
+		Transport: transport,
 	}
 	return onvif.NewDevice(params)
 }
 
+// updateOnvifDevice recreates the onvif devices of the subscribers from the onvif device of the client,
+// so the subscribers keep pulling the events after the credentials changed
+func (manager *PullPointManager) updateOnvifDevice(device OnvifDevice, httpRequestTimeout time.Duration) {
+	manager.lock.RLock()
+	defer manager.lock.RUnlock()
+
+	for _, sub := range manager.subscribers {
+		onvifDevice, err := manager.newSubscriberOnvifDevice(device, *sub.subscriptionRequest.MessageTimeout, httpRequestTimeout)
+		if err != nil {
+			manager.lc.Warnf("Unable to update the onvif device of the subscriber '%s': %v", sub.Name, err)
+			continue
+		}
+		sub.deviceMu.Lock()
+		sub.onvifDevice = onvifDevice
+		sub.deviceMu.Unlock()
+	}
+}
+
 func (manager *PullPointManager) addSubscriber(sub *Subscriber) {
 	manager.lock.Lock()
 	defer manager.lock.Unlock()
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sync"
 
 	sdkModel "github.com/edgexfoundry/device-sdk-go/v2/pkg/models"
 	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
@@ -29,6 +30,8 @@ type Subscriber struct {
 
 	// onvifDevice is used to send the pullMessage onvif function with specified request timeout
 	onvifDevice OnvifDevice
+	// deviceMu is for locking access to the onvifDevice, which is replaced when the credentials change
+	deviceMu sync.RWMutex
 	// SubscriptionAddress is used to pull the event from the camera
 	SubscriptionAddress string
 	// subscriptionRequest is used to create the PullPoint subscription
@@ -39,6 +42,13 @@ type Subscriber struct {
 	Stopped chan bool
 }
 
+// getOnvifDevice returns the onvif device used to pull the messages
+func (sub *Subscriber) getOnvifDevice() OnvifDevice {
+	sub.deviceMu.RLock()
+	defer sub.deviceMu.RUnlock()
+	return sub.onvifDevice
+}
+
 // StartPullMessageLoop implements the long-polling strategy to pull the camera event
 func (sub *Subscriber) StartPullMessageLoop() {
 	sub.onvifClient.lc.Infof("Subscriber starts the PullMessage loop for '%s'", sub.Name)
@@ -72,13 +82,19 @@ func (sub *Subscriber) pullMessage() errors.EdgeX {
 	if err != nil {
 		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to marshal the PullMessage request for '%s', %v", sub.Name, err), err)
 	}
-	servResp, err := sub.onvifDevice.SendSoap(sub.SubscriptionAddress, string(requestBody))
+	// the event pulls are sent with priority over the other requests waiting to be sent to the camera, and hold
+	// their request slot while the camera holds the pull, for up to the MessageTimeout
+	release := sub.onvifClient.acquireRequestSlot(true)
+	defer release()
+	servResp, err := sub.getOnvifDevice().SendSoap(sub.SubscriptionAddress, string(requestBody))
 	if err != nil {
 		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to send the '%s' pull event message request, %v", onvif.PullMessages, err), err)
 	}
 	defer servResp.Body.Close()
 	if *sub.subscriptionRequest.AutoRenew && (servResp.StatusCode == http.StatusNotFound || servResp.StatusCode == http.StatusBadRequest) {
 		sub.onvifClient.lc.Warnf("The pull point expired, try to create a new one")
+		// the new pull point is requested with a slot of its own
+		release()
 
 		edgexErr := sub.createPullPoint()
 		if edgexErr != nil {
@@ -109,12 +125,7 @@ func (sub *Subscriber) pullMessage() errors.EdgeX {
 	if err != nil {
 		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create commandValue  for '%s', %v", sub.Name, err), err)
 	}
-	asyncValues := &sdkModel.AsyncValues{
-		DeviceName:    sub.onvifClient.DeviceName,
-		CommandValues: []*sdkModel.CommandValue{cv},
-	}
-
-	sub.onvifClient.driver.asynchCh <- asyncValues
+	sub.onvifClient.publishCameraEvent(cv, sub.subscriptionRequest)
 	return nil
 }
 
@@ -161,7 +172,7 @@ func (sub *Subscriber) unsubscribe() errors.EdgeX {
 	if err != nil {
 		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to marshal the unsubscribe request for '%s', %v", sub.Name, err), err)
 	}
-	_, edgexErr := sub.onvifClient.onvifDevice.SendSoap(sub.SubscriptionAddress, string(requestBody))
+	_, edgexErr := sub.onvifClient.getOnvifDevice().SendSoap(sub.SubscriptionAddress, string(requestBody))
 	if edgexErr != nil {
 		return errors.NewCommonEdgeXWrapper(edgexErr)
 	}
@@ -0,0 +1,150 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edgexfoundry/device-onvif-camera/internal/driver/mocks"
+)
+
+const testCreatePullPointResponse = `<CreatePullPointSubscriptionResponse><SubscriptionReference>` +
+	`<Address>http://camera/onvif/subscription/2</Address></SubscriptionReference></CreatePullPointSubscriptionResponse>`
+
+// createTestSubscriber creates a subscriber pulling the events with the pullDevice, of a camera which accepts a single
+// request at a time
+func createTestSubscriber(t *testing.T, pullDevice *mocks.OnvifDevice) (*Subscriber, *OnvifClient) {
+	driver, _ := createDriverWithMockService()
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	onvifClient.limiter = NewRequestLimiter()
+	onvifClient.requestPolicy = requestPolicy{MaxConcurrentRequests: 1}
+	mockDevice.On("GetEndpointByRequestStruct", mock.Anything).Return("http://camera/onvif/event_service", nil)
+	mockDevice.On("SendSoap", mock.Anything, mock.Anything).Return(func(_ string, body string) *http.Response {
+		assert.Contains(t, body, "CreatePullPointSubscription")
+		response := fmt.Sprintf(testRotationEnvelope, testCreatePullPointResponse)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(response))}
+	}, nil)
+
+	autoRenew := true
+	initialTerminationTime := "PT1H"
+	subscriptionPolicy := ""
+	return &Subscriber{
+		Name:                "CameraEvent",
+		onvifClient:         onvifClient,
+		onvifDevice:         pullDevice,
+		SubscriptionAddress: "http://camera/onvif/subscription/1",
+		subscriptionRequest: &SubscriptionRequest{
+			AutoRenew:              &autoRenew,
+			InitialTerminationTime: &initialTerminationTime,
+			SubscriptionPolicy:     &subscriptionPolicy,
+		},
+	}, onvifClient
+}
+
+func TestSubscriber_pullMessage_expiredPullPoint(t *testing.T) {
+	pullDevice := &mocks.OnvifDevice{}
+	pullDevice.On("SendSoap", mock.Anything, mock.Anything).Return(func(string, string) *http.Response {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader(""))}
+	}, nil)
+	sub, _ := createTestSubscriber(t, pullDevice)
+
+	// the pull point is renewed while the camera only accepts a single request at a time
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.pullMessage()
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "the expired pull point was not renewed")
+	}
+	assert.Equal(t, "http://camera/onvif/subscription/2", sub.SubscriptionAddress)
+}
+
+func TestSubscriber_pullMessage_longPoll(t *testing.T) {
+	pulling := make(chan struct{})
+	pullDone := make(chan struct{})
+	pullDevice := &mocks.OnvifDevice{}
+	pullDevice.On("SendSoap", mock.Anything, mock.Anything).Return(func(string, string) *http.Response {
+		close(pulling)
+		<-pullDone
+		response := fmt.Sprintf(testRotationEnvelope, "<PullMessagesResponse></PullMessagesResponse>")
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(response))}
+	}, nil)
+	sub, onvifClient := createTestSubscriber(t, pullDevice)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.pullMessage()
+	}()
+	<-pulling
+
+	// the pull holds the only request slot of the camera while the camera holds it
+	acquired := make(chan func(), 1)
+	go func() {
+		acquired <- onvifClient.acquireRequestSlot(false)
+	}()
+	select {
+	case <-acquired:
+		require.Fail(t, "the request was sent along with the event pull")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(pullDone)
+	require.NoError(t, <-done)
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		require.Fail(t, "the request slot of the event pull was not released")
+	}
+}
+
+func TestSubscriber_pullMessage_longPollWithSecondSlot(t *testing.T) {
+	pulling := make(chan struct{})
+	pullDone := make(chan struct{})
+	pullDevice := &mocks.OnvifDevice{}
+	pullDevice.On("SendSoap", mock.Anything, mock.Anything).Return(func(string, string) *http.Response {
+		close(pulling)
+		<-pullDone
+		response := fmt.Sprintf(testRotationEnvelope, "<PullMessagesResponse></PullMessagesResponse>")
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(response))}
+	}, nil)
+	sub, onvifClient := createTestSubscriber(t, pullDevice)
+	onvifClient.requestPolicy = requestPolicy{MaxConcurrentRequests: 2}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sub.pullMessage()
+	}()
+	<-pulling
+
+	// the other requests use the second slot while the camera holds the pull
+	acquired := make(chan func(), 1)
+	go func() {
+		acquired <- onvifClient.acquireRequestSlot(false)
+	}()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		require.Fail(t, "the request was blocked by the event pull")
+	}
+
+	close(pullDone)
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,122 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"sync"
+)
+
+// deviceRequestQueue keeps track of the requests of a single device
+type deviceRequestQueue struct {
+	// active is the amount of requests currently sent to the device
+	active int
+	// limit is the latest maximum amount of concurrent requests of the device
+	limit int
+	// priority and normal are the waiting requests, in the order they arrived
+	priority []chan struct{}
+	normal   []chan struct{}
+}
+
+// RequestLimiter limits the amount of concurrent requests sent to each device, as some cameras fail when they
+// receive concurrent requests. The waiting requests are sent in the order they arrived, except for the priority
+// requests which are sent before any other waiting request.
+type RequestLimiter struct {
+	mu     sync.Mutex
+	queues map[string]*deviceRequestQueue
+}
+
+// NewRequestLimiter creates a new RequestLimiter
+func NewRequestLimiter() *RequestLimiter {
+	return &RequestLimiter{
+		queues: make(map[string]*deviceRequestQueue),
+	}
+}
+
+// Acquire waits until a request may be sent to the device, and returns the function which must be called once
+// the request is completed. A limit of 0 means the requests of the device are not limited.
+func (l *RequestLimiter) Acquire(deviceName string, limit int, priority bool) (release func()) {
+	l.mu.Lock()
+	queue, found := l.queues[deviceName]
+	if limit <= 0 {
+		if found {
+			// the limit was removed, so the waiting requests are woken up by the running requests
+			queue.limit = 0
+		}
+		l.mu.Unlock()
+		return func() {}
+	}
+	if !found {
+		queue = &deviceRequestQueue{}
+		l.queues[deviceName] = queue
+	}
+	queue.limit = limit
+	if queue.active < limit && len(queue.priority) == 0 && len(queue.normal) == 0 {
+		queue.active++
+		l.mu.Unlock()
+		return l.releaseFunc(deviceName)
+	}
+
+	ready := make(chan struct{})
+	if priority {
+		queue.priority = append(queue.priority, ready)
+	} else {
+		queue.normal = append(queue.normal, ready)
+	}
+	l.mu.Unlock()
+
+	// the slot is handed over by a request which released it
+	<-ready
+	return l.releaseFunc(deviceName)
+}
+
+// releaseFunc returns the function which releases a request slot of the device only once
+func (l *RequestLimiter) releaseFunc(deviceName string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.release(deviceName)
+		})
+	}
+}
+
+// release frees a request slot of the device, and hands the free slots over to the next waiting requests
+func (l *RequestLimiter) release(deviceName string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	queue, found := l.queues[deviceName]
+	if !found {
+		return
+	}
+	queue.active--
+	// the limit may have changed while the request was running, so more than one request may be woken up
+	for queue.limit <= 0 || queue.active < queue.limit {
+		var next chan struct{}
+		if len(queue.priority) > 0 {
+			next, queue.priority = queue.priority[0], queue.priority[1:]
+		} else if len(queue.normal) > 0 {
+			next, queue.normal = queue.normal[0], queue.normal[1:]
+		} else {
+			break
+		}
+		queue.active++
+		close(next)
+	}
+
+	if queue.active == 0 && len(queue.priority) == 0 && len(queue.normal) == 0 {
+		delete(l.queues, deviceName)
+	}
+}
+
+// acquireRequestSlot waits until a request may be sent to the camera, and returns the function which must be
+// called once the request is completed. Priority requests are sent before any other waiting request.
+func (onvifClient *OnvifClient) acquireRequestSlot(priority bool) func() {
+	if onvifClient.limiter == nil {
+		return func() {}
+	}
+	return onvifClient.limiter.Acquire(onvifClient.DeviceName, onvifClient.getRequestPolicy().MaxConcurrentRequests, priority)
+}
@@ -0,0 +1,109 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForQueued waits until the amount of requests of the device waiting in the limiter is reached
+func waitForQueued(t *testing.T, limiter *RequestLimiter, deviceName string, expected int) {
+	require.Eventually(t, func() bool {
+		limiter.mu.Lock()
+		defer limiter.mu.Unlock()
+		queue, found := limiter.queues[deviceName]
+		return found && len(queue.priority)+len(queue.normal) == expected
+	}, time.Second, time.Millisecond)
+}
+
+func TestRequestLimiter_order(t *testing.T) {
+	limiter := NewRequestLimiter()
+	release := limiter.Acquire(testDeviceName, 1, false)
+
+	var mu sync.Mutex
+	var order []string
+	wg := sync.WaitGroup{}
+	enqueue := func(name string, priority bool, queued int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := limiter.Acquire(testDeviceName, 1, priority)
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			release()
+		}()
+		waitForQueued(t, limiter, testDeviceName, queued)
+	}
+	enqueue("first", false, 1)
+	enqueue("second", false, 2)
+	enqueue("event pull", true, 3)
+
+	release()
+	// releasing more than once has no effect
+	release()
+	wg.Wait()
+
+	assert.Equal(t, []string{"event pull", "first", "second"}, order)
+	assert.Empty(t, limiter.queues)
+}
+
+func TestRequestLimiter_limit(t *testing.T) {
+	limiter := NewRequestLimiter()
+
+	var active, maxActive int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := limiter.Acquire(testDeviceName, 2, false)
+			defer release()
+			current := atomic.AddInt32(&active, 1)
+			for {
+				previous := atomic.LoadInt32(&maxActive)
+				if current <= previous || atomic.CompareAndSwapInt32(&maxActive, previous, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&active, -1)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), maxActive)
+	assert.Empty(t, limiter.queues)
+}
+
+func TestRequestLimiter_noLimit(t *testing.T) {
+	limiter := NewRequestLimiter()
+	release := limiter.Acquire(testDeviceName, 1, false)
+
+	done := make(chan struct{})
+	go func() {
+		limiter.Acquire(testDeviceName, 1, false)()
+		close(done)
+	}()
+	waitForQueued(t, limiter, testDeviceName, 1)
+
+	// the requests are not limited anymore, so the waiting request is woken up once the running request completes
+	limiter.Acquire(testDeviceName, 0, false)()
+	release()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the waiting request was not woken up")
+	}
+	assert.Empty(t, limiter.queues)
+}
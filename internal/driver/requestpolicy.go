@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+)
+
+const (
+	// defaultRequestRetryBackoffMillis is used when the RequestRetryBackoffMillis is not a positive value
+	defaultRequestRetryBackoffMillis = 500
+	// maxRequestRetryBackoff is the maximum amount of time to wait between two attempts of the same request
+	maxRequestRetryBackoff = 30 * time.Second
+)
+
+// requestPolicy holds the timeout and retry settings of the Onvif requests sent to a device
+type requestPolicy struct {
+	// Timeout is the maximum amount of time to wait for each attempt of a request
+	Timeout time.Duration
+	// Retries is the maximum amount of times a failed request of an idempotent function is retried
+	Retries int
+	// Backoff is the amount of time to wait before the first retry. It is doubled for every following retry.
+	Backoff time.Duration
+	// MaxConcurrentRequests is the maximum amount of requests sent to the device at the same time, 0 means no limit
+	MaxConcurrentRequests int
+}
+
+// requestPolicyForDevice returns the request policy of the device. The RequestTimeout, RequestRetries,
+// RequestRetryBackoffMillis and MaxConcurrentRequests protocol properties of the device override the configured values.
+func (d *Driver) requestPolicyForDevice(device models.Device) requestPolicy {
+	d.configMu.RLock()
+	timeoutSeconds := d.config.AppCustom.RequestTimeout
+	retries := d.config.AppCustom.RequestRetries
+	backoffMillis := d.config.AppCustom.RequestRetryBackoffMillis
+	maxConcurrentRequests := d.config.AppCustom.MaxConcurrentRequestsPerDevice
+	d.configMu.RUnlock()
+
+	timeoutSeconds = d.devicePropertyInt(device, DeviceRequestTimeout, timeoutSeconds)
+	retries = d.devicePropertyInt(device, DeviceRequestRetries, retries)
+	backoffMillis = d.devicePropertyInt(device, DeviceRequestRetryBackoffMillis, backoffMillis)
+	maxConcurrentRequests = d.devicePropertyInt(device, DeviceMaxConcurrentRequests, maxConcurrentRequests)
+
+	if retries < 0 {
+		retries = 0
+	}
+	if backoffMillis <= 0 {
+		backoffMillis = defaultRequestRetryBackoffMillis
+	}
+	if maxConcurrentRequests < 0 {
+		maxConcurrentRequests = 0
+	}
+	return requestPolicy{
+		Timeout: time.Duration(timeoutSeconds) * time.Second,
+		Retries: retries,
+		Backoff: time.Duration(backoffMillis) * time.Millisecond,
+
+		MaxConcurrentRequests: maxConcurrentRequests,
+	}
+}
+
+// devicePropertyInt parses the integer value of the Onvif protocol property of the device. The default value is
+// returned when the property is not set or invalid.
+func (d *Driver) devicePropertyInt(device models.Device, name string, defaultValue int) int {
+	value := device.Protocols[OnvifProtocol][name]
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		d.lc.Warnf("Device %s has an invalid %s value: '%s', using '%d' instead.", device.Name, name, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// isIdempotentFunction returns true if the Onvif function only reads the state of the camera, so it is safe to retry
+func isIdempotentFunction(functionName string) bool {
+	return strings.HasPrefix(functionName, "Get")
+}
+
+// isRetryableResponse returns true if the request failed due to a network error or because the camera
+// was temporarily unavailable
+func isRetryableResponse(statusCode int, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch statusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryBackoff returns the amount of time to wait before the specified retry, starting from 1
+func (policy requestPolicy) retryBackoff(retry int) time.Duration {
+	backoff := policy.Backoff
+	for i := 1; i < retry && backoff < maxRequestRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRequestRetryBackoff {
+		backoff = maxRequestRetryBackoff
+	}
+	return backoff
+}
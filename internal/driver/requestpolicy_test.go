@@ -0,0 +1,168 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestPolicyForDevice(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol models.ProtocolProperties
+		expected requestPolicy
+	}{
+		{
+			name:     "configured values",
+			protocol: models.ProtocolProperties{},
+			expected: requestPolicy{Timeout: 5 * time.Second, Retries: 2, Backoff: 100 * time.Millisecond},
+		},
+		{
+			name: "device overrides",
+			protocol: models.ProtocolProperties{
+				DeviceRequestTimeout:            "30",
+				DeviceRequestRetries:            "4",
+				DeviceRequestRetryBackoffMillis: "1000",
+				DeviceMaxConcurrentRequests:     "1",
+			},
+			expected: requestPolicy{Timeout: 30 * time.Second, Retries: 4, Backoff: time.Second, MaxConcurrentRequests: 1},
+		},
+		{
+			name: "invalid overrides",
+			protocol: models.ProtocolProperties{
+				DeviceRequestTimeout: "fast",
+				DeviceRequestRetries: "-1",
+			},
+			expected: requestPolicy{Timeout: 5 * time.Second, Retries: 0, Backoff: 100 * time.Millisecond},
+		},
+		{
+			name:     "default backoff",
+			protocol: models.ProtocolProperties{DeviceRequestRetryBackoffMillis: "0"},
+			expected: requestPolicy{Timeout: 5 * time.Second, Retries: 2, Backoff: defaultRequestRetryBackoffMillis * time.Millisecond},
+		},
+	}
+
+	driver, _ := createDriverWithMockService()
+	driver.configMu = new(sync.RWMutex)
+	driver.config = &ServiceConfig{AppCustom: CustomConfig{RequestTimeout: 5, RequestRetries: 2, RequestRetryBackoffMillis: 100}}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			device := models.Device{Name: "cam1", Protocols: map[string]models.ProtocolProperties{OnvifProtocol: test.protocol}}
+			assert.Equal(t, test.expected, driver.requestPolicyForDevice(device))
+		})
+	}
+}
+
+func TestRequestPolicy_retryBackoff(t *testing.T) {
+	policy := requestPolicy{Backoff: 10 * time.Second}
+	assert.Equal(t, 10*time.Second, policy.retryBackoff(1))
+	assert.Equal(t, 20*time.Second, policy.retryBackoff(2))
+	assert.Equal(t, maxRequestRetryBackoff, policy.retryBackoff(3))
+	assert.Equal(t, maxRequestRetryBackoff, policy.retryBackoff(100))
+}
+
+func TestOnvifClient_callOnvifFunction_retry(t *testing.T) {
+	newResponse := func(statusCode int) *http.Response {
+		response := fmt.Sprintf(testRotationEnvelope, `<GetDeviceInformationResponse><Model>test</Model></GetDeviceInformationResponse>`)
+		if statusCode != http.StatusOK {
+			response = fmt.Sprintf(testRotationEnvelope, testRotationFault)
+		}
+		return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(strings.NewReader(response))}
+	}
+
+	tests := []struct {
+		name          string
+		serviceName   string
+		functionName  string
+		data          []byte
+		failures      []int
+		expectedCalls int
+		errorExpected bool
+	}{
+		{
+			name:          "get function succeeds after network errors",
+			serviceName:   onvif.DeviceWebService,
+			functionName:  onvif.GetDeviceInformation,
+			failures:      []int{0, http.StatusServiceUnavailable},
+			expectedCalls: 3,
+		},
+		{
+			name:          "get function fails after all retries",
+			serviceName:   onvif.DeviceWebService,
+			functionName:  onvif.GetDeviceInformation,
+			failures:      []int{0, 0, 0, 0},
+			expectedCalls: 3,
+			errorExpected: true,
+		},
+		{
+			name:          "get function is not retried on unauthorized",
+			serviceName:   onvif.DeviceWebService,
+			functionName:  onvif.GetDeviceInformation,
+			failures:      []int{http.StatusUnauthorized},
+			expectedCalls: 1,
+			errorExpected: true,
+		},
+		{
+			name:          "set function is never retried",
+			serviceName:   onvif.DeviceWebService,
+			functionName:  onvif.SetHostname,
+			data:          []byte(`{"Name": "camera"}`),
+			failures:      []int{0},
+			expectedCalls: 1,
+			errorExpected: true,
+		},
+		{
+			name:          "reboot is never retried",
+			serviceName:   onvif.DeviceWebService,
+			functionName:  onvif.SystemReboot,
+			failures:      []int{http.StatusServiceUnavailable},
+			expectedCalls: 1,
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			driver, _ := createDriverWithMockService()
+			onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+			onvifClient.requestPolicy = requestPolicy{Retries: 2, Backoff: time.Millisecond}
+			mockDevice.On("GetEndpointByRequestStruct", mock.Anything).Return("http://camera/onvif/device_service", nil)
+
+			for _, statusCode := range test.failures {
+				if statusCode == 0 {
+					mockDevice.On("SendSoap", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("connection refused")).Once()
+				} else {
+					mockDevice.On("SendSoap", mock.Anything, mock.Anything).Return(newResponse(statusCode), nil).Once()
+				}
+			}
+			mockDevice.On("SendSoap", mock.Anything, mock.Anything).Return(newResponse(http.StatusOK), nil)
+
+			_, err := onvifClient.callOnvifFunction(test.serviceName, test.functionName, test.data)
+			mockDevice.AssertNumberOfCalls(t, "SendSoap", test.expectedCalls)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
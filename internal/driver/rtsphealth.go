@@ -0,0 +1,245 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultRTSPPort is the port of the rtsp uris without a port
+	defaultRTSPPort = "554"
+	// rtspUserAgent is the User-Agent of the RTSP requests of the stream health check
+	rtspUserAgent = "device-onvif-camera"
+)
+
+// rtspResponse is the status code, headers and body of an RTSP response
+type rtspResponse struct {
+	statusCode int
+	header     textproto.MIMEHeader
+	body       []byte
+}
+
+// rtspConn sends the RTSP requests of the stream health check over a single connection
+type rtspConn struct {
+	conn   net.Conn
+	reader *textproto.Reader
+	cseq   int
+}
+
+// streamHealthCheckEnabled returns true if the stream health of UpWithAuth devices should be checked
+func (d *Driver) streamHealthCheckEnabled() bool {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.config.AppCustom.EnableStreamHealthCheck
+}
+
+// checkStreamHealth runs RTSP OPTIONS and DESCRIBE requests against the stream uri of the first media profile of the
+// camera, and sets the StreamHealthy and StreamLatencyMillis properties. The stream is healthy when the camera
+// describes a video stream with the codec of the video encoder of the profile.
+func (d *Driver) checkStreamHealth(devClient *OnvifClient, health *DeviceHealth, properties map[string]string) {
+	d.configMu.RLock()
+	timeout := time.Duration(d.config.AppCustom.StreamHealthTimeoutMillis) * time.Millisecond
+	d.configMu.RUnlock()
+
+	start := time.Now()
+	latency, err := d.probeDeviceStream(devClient, timeout)
+	health.addResult(methodRTSPDescribe, start, err)
+	if err != nil {
+		d.lc.Debugf("The stream of device %s is not healthy: %s", devClient.DeviceName, err.Error())
+		properties[StreamHealthy] = strconv.FormatBool(false)
+		properties[StreamLatencyMillis] = ""
+		return
+	}
+	properties[StreamHealthy] = strconv.FormatBool(true)
+	properties[StreamLatencyMillis] = strconv.FormatInt(latency.Milliseconds(), 10)
+}
+
+// probeDeviceStream resolves the stream uri of the first media profile of the camera and probes it
+func (d *Driver) probeDeviceStream(devClient *OnvifClient, timeout time.Duration) (time.Duration, error) {
+	profiles, mediaService, edgexErr := devClient.getStreamProfiles("")
+	if edgexErr != nil {
+		return 0, edgexErr
+	}
+	profile := profiles[0]
+	uri, edgexErr := devClient.getStreamURI(mediaService, profile.ProfileToken, StreamTransportTCP)
+	if edgexErr != nil {
+		return 0, edgexErr
+	}
+	params := devClient.getOnvifDevice().GetDeviceParams()
+	return probeRTSPStream(uri, params.Username, params.Password, profile.Encoding, timeout)
+}
+
+// probeRTSPStream sends the OPTIONS and DESCRIBE requests to the rtsp uri, answering the authentication challenge of
+// the camera with the credentials, and checks that the SDP of the stream describes a video stream with the encoding.
+// The encoding is not checked if empty. Returns the time taken by the requests.
+func probeRTSPStream(uri string, username string, password string, encoding string, timeout time.Duration) (time.Duration, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stream uri %s: %w", uri, err)
+	}
+	if parsed.Scheme != "rtsp" {
+		return 0, fmt.Errorf("unsupported stream uri scheme '%s'", parsed.Scheme)
+	}
+	host := parsed.Host
+	if parsed.Port() == "" {
+		host = net.JoinHostPort(parsed.Hostname(), defaultRTSPPort)
+	}
+	// the credentials embedded in the uri are sent with the Authorization header instead
+	parsed.User = nil
+	uri = parsed.String()
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", host, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return 0, err
+	}
+	rtsp := &rtspConn{conn: conn, reader: textproto.NewReader(bufio.NewReader(conn))}
+
+	// some cameras reject the DESCRIBE of unauthenticated clients, but accept the OPTIONS
+	if _, err := rtsp.request("OPTIONS", uri, nil); err != nil {
+		return 0, err
+	}
+
+	header := textproto.MIMEHeader{"Accept": {"application/sdp"}}
+	response, err := rtsp.request("DESCRIBE", uri, header)
+	if err != nil {
+		return 0, err
+	}
+	if response.statusCode == 401 && username != "" {
+		challenge, found := selectAuthChallenge(parseAuthChallenges(response.header.Values("WWW-Authenticate")))
+		if !found {
+			return 0, fmt.Errorf("the stream uri %s requires an unsupported authentication scheme", uri)
+		}
+		authorization, err := rtspAuthorization(challenge, "DESCRIBE", uri, username, password)
+		if err != nil {
+			return 0, err
+		}
+		header.Set("Authorization", authorization)
+		if response, err = rtsp.request("DESCRIBE", uri, header); err != nil {
+			return 0, err
+		}
+	}
+	latency := time.Since(start)
+	if response.statusCode != 200 {
+		return 0, fmt.Errorf("the DESCRIBE request of the stream uri %s failed with status %d", uri, response.statusCode)
+	}
+	if err := checkSDPVideo(string(response.body), encoding); err != nil {
+		return 0, err
+	}
+	return latency, nil
+}
+
+// rtspAuthorization returns the Authorization header value answering the challenge
+func rtspAuthorization(challenge httpAuthChallenge, method, uri, username, password string) (string, error) {
+	switch challenge.scheme {
+	case httpAuthSchemeBasic:
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	case httpAuthSchemeDigest:
+		return challenge.digestAuthorization(method, uri, username, password)
+	}
+	return "", fmt.Errorf("unsupported authentication scheme '%s'", challenge.scheme)
+}
+
+// request sends the RTSP request and reads its response
+func (rtsp *rtspConn) request(method string, uri string, header textproto.MIMEHeader) (rtspResponse, error) {
+	rtsp.cseq++
+	var request strings.Builder
+	fmt.Fprintf(&request, "%s %s RTSP/1.0\r\nCSeq: %d\r\nUser-Agent: %s\r\n", method, uri, rtsp.cseq, rtspUserAgent)
+	for key, values := range header {
+		for _, value := range values {
+			fmt.Fprintf(&request, "%s: %s\r\n", key, value)
+		}
+	}
+	request.WriteString("\r\n")
+	if _, err := io.WriteString(rtsp.conn, request.String()); err != nil {
+		return rtspResponse{}, err
+	}
+
+	statusLine, err := rtsp.reader.ReadLine()
+	if err != nil {
+		return rtspResponse{}, err
+	}
+	protocol, status, _ := strings.Cut(statusLine, " ")
+	code, _, _ := strings.Cut(status, " ")
+	statusCode, err := strconv.Atoi(code)
+	if !strings.HasPrefix(protocol, "RTSP/") || err != nil {
+		return rtspResponse{}, fmt.Errorf("invalid RTSP response '%s'", statusLine)
+	}
+	responseHeader, err := rtsp.reader.ReadMIMEHeader()
+	if err != nil {
+		return rtspResponse{}, err
+	}
+	response := rtspResponse{statusCode: statusCode, header: responseHeader}
+	if length := responseHeader.Get("Content-Length"); length != "" {
+		size, err := strconv.Atoi(length)
+		if err != nil || size < 0 {
+			return rtspResponse{}, fmt.Errorf("invalid RTSP Content-Length '%s'", length)
+		}
+		response.body = make([]byte, size)
+		if _, err := io.ReadFull(rtsp.reader.R, response.body); err != nil {
+			return rtspResponse{}, err
+		}
+	}
+	return response, nil
+}
+
+// checkSDPVideo checks that the SDP describes a video stream with the encoding, or any encoding if empty
+func checkSDPVideo(sdp string, encoding string) error {
+	video := false
+	var codecs []string
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "m=") {
+			video = strings.HasPrefix(line, "m=video")
+			continue
+		}
+		// a=rtpmap:<payload type> <encoding name>/<clock rate>
+		if !video || !strings.HasPrefix(line, "a=rtpmap:") {
+			continue
+		}
+		if _, rtpmap, found := strings.Cut(line, " "); found {
+			name, _, _ := strings.Cut(rtpmap, "/")
+			codecs = append(codecs, strings.ToUpper(name))
+		}
+	}
+	if len(codecs) == 0 {
+		return fmt.Errorf("the SDP of the stream does not describe a video stream")
+	}
+	if encoding == "" {
+		return nil
+	}
+	expected := sdpEncodingName(encoding)
+	for _, codec := range codecs {
+		if codec == expected {
+			return nil
+		}
+	}
+	return fmt.Errorf("the SDP of the stream describes the codecs %v instead of %s", codecs, expected)
+}
+
+// sdpEncodingName returns the RTP encoding name of the Onvif video encoding
+func sdpEncodingName(encoding string) string {
+	switch strings.ToUpper(encoding) {
+	case "MPEG4":
+		return "MP4V-ES"
+	}
+	return strings.ToUpper(encoding)
+}
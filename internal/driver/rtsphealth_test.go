@@ -0,0 +1,189 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSDP = "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=stream\r\n" +
+	"m=audio 0 RTP/AVP 0\r\na=rtpmap:0 PCMU/8000\r\n" +
+	"m=video 0 RTP/AVP 96\r\na=rtpmap:96 H264/90000\r\n"
+
+// newTestRTSPServer starts a minimal RTSP server which answers the DESCRIBE requests with the SDP, once the client
+// is authenticated with the RFC 2069 digest of the test credentials, and returns the stream uri
+func newTestRTSPServer(t *testing.T, sdp string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	md5Hex := func(value string) string {
+		hash := md5.Sum([]byte(value))
+		return hex.EncodeToString(hash[:])
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := textproto.NewReader(bufio.NewReader(conn))
+				for {
+					requestLine, err := reader.ReadLine()
+					if err != nil {
+						return
+					}
+					header, err := reader.ReadMIMEHeader()
+					if err != nil {
+						return
+					}
+					method, rest, _ := strings.Cut(requestLine, " ")
+					uri, _, _ := strings.Cut(rest, " ")
+					status, extra, body := "200 OK", "Public: OPTIONS, DESCRIBE\r\n", ""
+					if method == "DESCRIBE" {
+						expected := md5Hex(md5Hex(testRotationUser+":camera:"+testRotationPassword) + ":nonce:" + md5Hex("DESCRIBE:"+uri))
+						if strings.Contains(header.Get("Authorization"), fmt.Sprintf(`response="%s"`, expected)) {
+							extra, body = "Content-Type: application/sdp\r\n", sdp
+						} else {
+							status, extra = "401 Unauthorized", "WWW-Authenticate: Digest realm=\"camera\", nonce=\"nonce\"\r\n"
+						}
+					}
+					_, _ = fmt.Fprintf(conn, "RTSP/1.0 %s\r\nCSeq: %s\r\n%sContent-Length: %d\r\n\r\n%s",
+						status, header.Get("CSeq"), extra, len(body), body)
+				}
+			}()
+		}
+	}()
+	return fmt.Sprintf("rtsp://%s/stream1", listener.Addr().String())
+}
+
+func TestProbeRTSPStream(t *testing.T) {
+	uri := newTestRTSPServer(t, testSDP)
+	audioOnly := newTestRTSPServer(t, "v=0\r\nm=audio 0 RTP/AVP 0\r\na=rtpmap:0 PCMU/8000\r\n")
+
+	tests := []struct {
+		name          string
+		uri           string
+		password      string
+		encoding      string
+		errorExpected bool
+	}{
+		{
+			name:     "healthy",
+			uri:      uri,
+			password: testRotationPassword,
+			encoding: "H264",
+		},
+		{
+			name:     "any codec",
+			uri:      uri,
+			password: testRotationPassword,
+		},
+		{
+			name:          "unexpected codec",
+			uri:           uri,
+			password:      testRotationPassword,
+			encoding:      "H265",
+			errorExpected: true,
+		},
+		{
+			name:          "wrong password",
+			uri:           uri,
+			password:      "wrong",
+			encoding:      "H264",
+			errorExpected: true,
+		},
+		{
+			name:          "no video",
+			uri:           audioOnly,
+			password:      testRotationPassword,
+			errorExpected: true,
+		},
+		{
+			name:          "unsupported scheme",
+			uri:           "http://127.0.0.1/stream1",
+			password:      testRotationPassword,
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			latency, err := probeRTSPStream(test.uri, testRotationUser, test.password, test.encoding, 5*time.Second)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Greater(t, latency, time.Duration(0))
+		})
+	}
+}
+
+func TestCheckSDPVideo(t *testing.T) {
+	assert.NoError(t, checkSDPVideo("m=video 0 RTP/AVP 26\na=rtpmap:26 JPEG/90000", "JPEG"))
+	assert.NoError(t, checkSDPVideo("m=video 0 RTP/AVP 96\na=rtpmap:96 MP4V-ES/90000", "MPEG4"))
+	assert.Error(t, checkSDPVideo(testSDP, "PCMU"))
+}
+
+func TestTestConnectionMethods_streamHealth(t *testing.T) {
+	tests := []struct {
+		name      string
+		streamURI string
+		healthy   bool
+	}{
+		{
+			name:      "healthy stream",
+			streamURI: newTestRTSPServer(t, testSDP),
+			healthy:   true,
+		},
+		{
+			name: "no media profile",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			camera := newTestCamera(t)
+			defer camera.server.Close()
+			camera.streamURI = test.streamURI
+			device := camera.device(t, "cam1")
+
+			// the status check uses a temporary client, which is not attached to the driver
+			driver, _ := createTestCameraDriver(t, AuthModeUsernameToken, []models.Device{device})
+			driver.config.AppCustom.EnableStreamHealthCheck = true
+			driver.config.AppCustom.StreamHealthTimeoutMillis = 1000
+
+			status, properties := driver.testConnectionMethods(device)
+			assert.Equal(t, UpWithAuth, status)
+			assert.Equal(t, fmt.Sprint(test.healthy), properties[StreamHealthy])
+			assert.Equal(t, test.healthy, properties[StreamLatencyMillis] != "")
+
+			// the stream properties are cleared when the stream health is not checked
+			driver.config.AppCustom.EnableStreamHealthCheck = false
+			_, properties = driver.testConnectionMethods(device)
+			assert.Equal(t, "", properties[StreamHealthy])
+			assert.Equal(t, "", properties[StreamLatencyMillis])
+		})
+	}
+}
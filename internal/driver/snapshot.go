@@ -0,0 +1,477 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/IOTechSystems/onvif/media"
+	xsdOnvif "github.com/IOTechSystems/onvif/xsd/onvif"
+)
+
+// SnapshotRequest holds the optional parameters of the GetSnapshot command, which select the media profile
+// used to take the snapshot and how the image is transcoded. The ProfileToken takes precedence over the ProfileName,
+// which takes precedence over the resolution. When no parameter is set, the first media profile is used and the
+// image is returned as sent by the camera.
+type SnapshotRequest struct {
+	// ProfileToken is the token of the media profile
+	ProfileToken string
+	// ProfileName is the name of the media profile
+	ProfileName string
+	// Width and Height are the desired resolution of the snapshot. The media profile with the smallest resolution
+	// which is at least as large as the desired resolution is used, or the largest resolution if none is large enough.
+	Width  int
+	Height int
+	// MaxWidth and MaxHeight scale the image down to fit within the resolution, keeping its aspect ratio
+	MaxWidth  int
+	MaxHeight int
+	// Format is the format the image is transcoded to, either jpeg or png
+	Format string
+	// Quality is the jpeg quality from 1 to 100 of the transcoded image, which implies the jpeg format
+	Quality int
+	// MediaService is the media service used to take the snapshot, either Media or Media2. By default, Media2 is used
+	// when the camera supports it.
+	MediaService string
+}
+
+// snapshotURIKey identifies the snapshot uri of a media profile, which is requested from a media service
+type snapshotURIKey struct {
+	mediaService string
+	token        xsdOnvif.ReferenceToken
+}
+
+// deviceSnapshotCache holds the preferred media service and the snapshot uris of a single device
+type deviceSnapshotCache struct {
+	// mediaService is the preferred media service of the device
+	mediaService string
+	uris         map[snapshotURIKey]string
+}
+
+// SnapshotCache keeps the preferred media service and the snapshot uri of each media profile of every device, so they
+// are not requested from the camera for every snapshot. The media profiles themselves are not cached, as their
+// encoder configuration may change at any time. The cache of a device is cleared when its snapshot fails.
+type SnapshotCache struct {
+	mu      sync.Mutex
+	devices map[string]*deviceSnapshotCache
+}
+
+// NewSnapshotCache creates a new SnapshotCache
+func NewSnapshotCache() *SnapshotCache {
+	return &SnapshotCache{
+		devices: make(map[string]*deviceSnapshotCache),
+	}
+}
+
+// device returns the cache of the device, creating it if needed. The caller must hold the lock.
+func (c *SnapshotCache) device(deviceName string) *deviceSnapshotCache {
+	cache, found := c.devices[deviceName]
+	if !found {
+		cache = &deviceSnapshotCache{uris: make(map[snapshotURIKey]string)}
+		c.devices[deviceName] = cache
+	}
+	return cache
+}
+
+// preferredMediaService returns the cached preferred media service of the device
+func (c *SnapshotCache) preferredMediaService(deviceName string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cache, found := c.devices[deviceName]
+	if !found || cache.mediaService == "" {
+		return "", false
+	}
+	return cache.mediaService, true
+}
+
+// setPreferredMediaService caches the preferred media service of the device
+func (c *SnapshotCache) setPreferredMediaService(deviceName string, mediaService string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.device(deviceName).mediaService = mediaService
+}
+
+// uri returns the cached snapshot uri of the media profile of the device
+func (c *SnapshotCache) uri(deviceName string, mediaService string, token xsdOnvif.ReferenceToken) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cache, found := c.devices[deviceName]
+	if !found {
+		return "", false
+	}
+	uri, found := cache.uris[snapshotURIKey{mediaService: mediaService, token: token}]
+	return uri, found
+}
+
+// setURI caches the snapshot uri of the media profile of the device
+func (c *SnapshotCache) setURI(deviceName string, mediaService string, token xsdOnvif.ReferenceToken, uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.device(deviceName).uris[snapshotURIKey{mediaService: mediaService, token: token}] = uri
+}
+
+// Remove clears the cache of the device
+func (c *SnapshotCache) Remove(deviceName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.devices, deviceName)
+}
+
+// parseSnapshotRequest parses the optional jsonObject parameter of the GetSnapshot command
+func parseSnapshotRequest(data []byte) (SnapshotRequest, errors.EdgeX) {
+	var request SnapshotRequest
+	if len(data) == 0 {
+		return request, nil
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, "failed to unmarshal the GetSnapshot parameters", err)
+	}
+	if request.Width < 0 || request.Height < 0 {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid snapshot resolution %dx%d", request.Width, request.Height), nil)
+	}
+	if request.MaxWidth < 0 || request.MaxHeight < 0 {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid snapshot maximum resolution %dx%d", request.MaxWidth, request.MaxHeight), nil)
+	}
+	if request.Quality < 0 || request.Quality > 100 {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid snapshot quality %d, must be between 1 and 100", request.Quality), nil)
+	}
+	format, valid := normalizeSnapshotFormat(request.Format)
+	if !valid {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("unsupported snapshot format '%s', must be %s or %s", request.Format, SnapshotFormatJPEG, SnapshotFormatPNG), nil)
+	}
+	request.Format = format
+	if !isMediaService(request.MediaService) {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("unsupported media service '%s', must be %s or %s", request.MediaService, onvif.MediaWebService, onvif.Media2WebService), nil)
+	}
+	return request, nil
+}
+
+// callGetSnapshotFunction returns a snapshot from the camera, using the media profile selected by the optional
+// parameters of the command, and transcoded as requested by them.
+// The implementation can refer to https://github.com/edgexfoundry/device-camera-go/blob/5c4f34d1d59b8e25e1a6316661d463e2495d45fe/internal/driver/onvifclient.go#L119
+func (onvifClient *OnvifClient) callGetSnapshotFunction(data []byte) (snapshotImage, errors.EdgeX) {
+	request, edgexErr := parseSnapshotRequest(data)
+	if edgexErr != nil {
+		return snapshotImage{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	profiles, mediaService, edgexErr := onvifClient.getMediaProfiles(request.MediaService)
+	if edgexErr != nil {
+		return snapshotImage{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	profile, edgexErr := selectSnapshotProfile(profiles, request)
+	if edgexErr != nil {
+		return snapshotImage{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	url, edgexErr := onvifClient.getSnapshotURI(mediaService, profile.Token)
+	if edgexErr != nil {
+		return snapshotImage{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
+	buf, contentType, edgexErr := onvifClient.downloadSnapshot(url)
+	if edgexErr != nil {
+		// the media service or the snapshot uri may have changed, so they are requested again for the next snapshot
+		if cache := onvifClient.snapshotCache(); cache != nil {
+			cache.Remove(onvifClient.DeviceName)
+		}
+		return snapshotImage{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	mediaType := detectMediaType(buf, contentType)
+	snapshot, edgexErr := transcodeSnapshot(snapshotImage{data: buf, mediaType: mediaType, originalMediaType: mediaType}, request)
+	if edgexErr != nil {
+		return snapshotImage{}, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	return snapshot, nil
+}
+
+// isMediaService returns true if the media service is Media, Media2, or empty for the preferred media service
+func isMediaService(mediaService string) bool {
+	switch mediaService {
+	case "", onvif.MediaWebService, onvif.Media2WebService:
+		return true
+	}
+	return false
+}
+
+// snapshotCache returns the snapshot cache of the client, or nil for the temporary clients, which are not attached to
+// the driver
+func (onvifClient *OnvifClient) snapshotCache() *SnapshotCache {
+	if onvifClient.driver == nil {
+		return nil
+	}
+	return onvifClient.driver.snapshotCache
+}
+
+// getMediaProfiles returns the media profiles of the camera and the media service they were requested from.
+// When no media service is specified, Media2 is preferred if the camera supports it, falling back to Media. The
+// preferred media service is cached, except for the temporary clients.
+func (onvifClient *OnvifClient) getMediaProfiles(mediaService string) ([]xsdOnvif.Profile, string, errors.EdgeX) {
+	cache := onvifClient.snapshotCache()
+	if mediaService == "" && cache != nil {
+		if preferred, found := cache.preferredMediaService(onvifClient.DeviceName); found {
+			mediaService = preferred
+		}
+	}
+	if mediaService != "" {
+		profiles, edgexErr := onvifClient.requestMediaProfiles(mediaService)
+		if edgexErr != nil {
+			return nil, "", errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+		return profiles, mediaService, nil
+	}
+
+	if onvifClient.supportsMedia2() {
+		profiles, edgexErr := onvifClient.requestMediaProfiles(onvif.Media2WebService)
+		if edgexErr == nil {
+			if cache != nil {
+				cache.setPreferredMediaService(onvifClient.DeviceName, onvif.Media2WebService)
+			}
+			return profiles, onvif.Media2WebService, nil
+		}
+		onvifClient.lc.Debugf("Unable to get the Media2 profiles of device %s, falling back to Media: %s", onvifClient.DeviceName, edgexErr.Error())
+	}
+	profiles, edgexErr := onvifClient.requestMediaProfiles(onvif.MediaWebService)
+	if edgexErr != nil {
+		return nil, "", errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	if cache != nil {
+		cache.setPreferredMediaService(onvifClient.DeviceName, onvif.MediaWebService)
+	}
+	return profiles, onvif.MediaWebService, nil
+}
+
+// supportsMedia2 returns true if the camera reports the Media2 service in its GetServices response
+func (onvifClient *OnvifClient) supportsMedia2() bool {
+	respContent, edgexErr := onvifClient.callFunction(onvif.DeviceWebService, onvif.GetServices, &deviceGetServicesFunction{}, nil)
+	if edgexErr != nil {
+		onvifClient.lc.Debugf("Unable to get the services of device %s: %s", onvifClient.DeviceName, edgexErr.Error())
+		return false
+	}
+	servicesResp, ok := respContent.(*deviceGetServicesResponse)
+	if !ok {
+		return false
+	}
+	for _, service := range servicesResp.Service {
+		if strings.TrimSpace(service.Namespace) == media2Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// requestMediaProfiles requests the media profiles from the media service. The Media2 profiles are normalized into
+// Media profiles.
+func (onvifClient *OnvifClient) requestMediaProfiles(mediaService string) ([]xsdOnvif.Profile, errors.EdgeX) {
+	var profiles []xsdOnvif.Profile
+	if mediaService == onvif.Media2WebService {
+		respContent, edgexErr := onvifClient.callFunction(onvif.Media2WebService, onvif.GetProfiles, &media2GetProfilesFunction{}, nil)
+		if edgexErr != nil {
+			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+		profilesResp, ok := respContent.(*media2GetProfilesResponse)
+		if !ok {
+			return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid Media2 GetProfilesResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+		}
+		for _, profile := range profilesResp.Profiles {
+			profiles = append(profiles, profile.mediaProfile())
+		}
+	} else {
+		respContent, edgexErr := onvifClient.callOnvifFunction(onvif.MediaWebService, onvif.GetProfiles, nil)
+		if edgexErr != nil {
+			return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+		profilesResp, ok := respContent.(*media.GetProfilesResponse)
+		if !ok {
+			return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetProfilesResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+		}
+		profiles = profilesResp.Profiles
+	}
+	if len(profiles) == 0 {
+		return nil, errors.NewCommonEdgeX(errors.KindServerError, "no onvif profiles found", nil)
+	}
+	return profiles, nil
+}
+
+// getSnapshotURI returns the snapshot uri of the media profile of the media service, from the cache if available
+func (onvifClient *OnvifClient) getSnapshotURI(mediaService string, token xsdOnvif.ReferenceToken) (string, errors.EdgeX) {
+	cache := onvifClient.snapshotCache()
+	if cache != nil {
+		if uri, found := cache.uri(onvifClient.DeviceName, mediaService, token); found {
+			return uri, nil
+		}
+	}
+
+	var uri string
+	if mediaService == onvif.Media2WebService {
+		requestData, err := json.Marshal(media2GetSnapshotUri{ProfileToken: string(token)})
+		if err != nil {
+			return "", errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the Media2 GetSnapshotUri request", err)
+		}
+		respContent, edgexErr := onvifClient.callFunction(onvif.Media2WebService, onvif.GetSnapshotUri, &media2GetSnapshotUriFunction{}, requestData)
+		if edgexErr != nil {
+			return "", errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+		uriResponse, ok := respContent.(*media2GetSnapshotUriResponse)
+		if !ok {
+			return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid Media2 GetSnapshotUriResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+		}
+		uri = strings.TrimSpace(uriResponse.Uri)
+	} else {
+		requestData, edgexErr := snapshotUriRequestData(token)
+		if edgexErr != nil {
+			return "", errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+		respContent, edgexErr := onvifClient.callOnvifFunction(onvif.MediaWebService, onvif.GetSnapshotUri, requestData)
+		if edgexErr != nil {
+			return "", errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+		uriResponse, ok := respContent.(*media.GetSnapshotUriResponse)
+		if !ok {
+			return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetSnapshotUriResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+		}
+		uri = string(uriResponse.MediaUri.Uri)
+	}
+	if cache != nil {
+		cache.setURI(onvifClient.DeviceName, mediaService, token, uri)
+	}
+	return uri, nil
+}
+
+// downloadSnapshot downloads the snapshot binary data and its Content-Type from the snapshot uri. The snapshot endpoint of many cameras
+// requires HTTP basic or digest authentication, which is negotiated with the credentials of the device by retrying
+// once with the scheme of the challenge when the camera responds with 401.
+func (onvifClient *OnvifClient) downloadSnapshot(url string) ([]byte, string, errors.EdgeX) {
+	release := onvifClient.acquireRequestSlot(false)
+	defer release()
+
+	params := onvifClient.getOnvifDevice().GetDeviceParams()
+	httpClient := params.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := sendSnapshotRequest(httpClient, url, nil, params.Username, params.Password)
+	if err != nil {
+		return nil, "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to retrieve the snapshot from the url %s", url), err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized && params.Username != "" {
+		challenge, found := selectAuthChallenge(parseAuthChallenges(resp.Header.Values("WWW-Authenticate")))
+		// the body is drained so the connection can be reused for the authenticated request
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if !found {
+			return nil, "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("the snapshot url %s requires an unsupported authentication scheme", url), nil)
+		}
+		resp, err = sendSnapshotRequest(httpClient, url, &challenge, params.Username, params.Password)
+		if err != nil {
+			return nil, "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to retrieve the snapshot from the url %s with %s authentication", url, challenge.scheme), err)
+		}
+	}
+	defer resp.Body.Close()
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", errors.NewCommonEdgeX(errors.KindServerError, "error reading http request", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("http request for image failed with status %v, %s", resp.StatusCode, string(buf)), nil)
+	}
+	return buf, resp.Header.Get("Content-Type"), nil
+}
+
+// sendSnapshotRequest sends the GET request of the snapshot, answering the authentication challenge if not nil
+func sendSnapshotRequest(httpClient *http.Client, url string, challenge *httpAuthChallenge, username, password string) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		if err := challenge.authorize(request, username, password); err != nil {
+			return nil, err
+		}
+	}
+	return httpClient.Do(request)
+}
+
+// selectSnapshotProfile returns the media profile matching the snapshot request
+func selectSnapshotProfile(profiles []xsdOnvif.Profile, request SnapshotRequest) (xsdOnvif.Profile, errors.EdgeX) {
+	if len(profiles) == 0 {
+		return xsdOnvif.Profile{}, errors.NewCommonEdgeX(errors.KindServerError, "no onvif profiles found", nil)
+	}
+
+	switch {
+	case request.ProfileToken != "":
+		for _, profile := range profiles {
+			if string(profile.Token) == request.ProfileToken {
+				return profile, nil
+			}
+		}
+		return xsdOnvif.Profile{}, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("media profile with token '%s' not found", request.ProfileToken), nil)
+
+	case request.ProfileName != "":
+		for _, profile := range profiles {
+			if string(profile.Name) == request.ProfileName {
+				return profile, nil
+			}
+		}
+		return xsdOnvif.Profile{}, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("media profile with name '%s' not found", request.ProfileName), nil)
+
+	case request.Width > 0 || request.Height > 0:
+		return selectProfileByResolution(profiles, request.Width, request.Height), nil
+	}
+
+	return profiles[0], nil
+}
+
+// selectProfileByResolution returns the media profile with the smallest resolution which is at least as large as
+// the desired resolution, or the profile with the largest resolution if none is large enough. A desired width or
+// height of 0 is not compared. The first profile is returned if none of the profiles have a resolution.
+func selectProfileByResolution(profiles []xsdOnvif.Profile, width, height int) xsdOnvif.Profile {
+	var best, largest *xsdOnvif.Profile
+	var bestArea, largestArea int
+	for i := range profiles {
+		profileWidth, profileHeight, found := profileResolution(profiles[i])
+		if !found {
+			continue
+		}
+		area := profileWidth * profileHeight
+		if largest == nil || area > largestArea {
+			largest, largestArea = &profiles[i], area
+		}
+		if profileWidth >= width && profileHeight >= height && (best == nil || area < bestArea) {
+			best, bestArea = &profiles[i], area
+		}
+	}
+
+	if best != nil {
+		return *best
+	}
+	if largest != nil {
+		return *largest
+	}
+	return profiles[0]
+}
+
+// profileResolution returns the resolution of the video encoder of the media profile
+func profileResolution(profile xsdOnvif.Profile) (width int, height int, found bool) {
+	encoder := profile.VideoEncoderConfiguration
+	if encoder == nil || encoder.Resolution == nil || encoder.Resolution.Width == nil || encoder.Resolution.Height == nil {
+		return 0, 0, false
+	}
+	return int(*encoder.Resolution.Width), int(*encoder.Resolution.Height), true
+}
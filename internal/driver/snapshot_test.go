@@ -0,0 +1,270 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/IOTechSystems/onvif/xsd"
+	xsdOnvif "github.com/IOTechSystems/onvif/xsd/onvif"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edgexfoundry/device-onvif-camera/internal/driver/mocks"
+)
+
+const (
+	testProfilesResponse = `<GetProfilesResponse>` +
+		`<Profiles token="main"><Name>MainStream</Name><VideoEncoderConfiguration><Resolution><Width>1920</Width><Height>1080</Height></Resolution></VideoEncoderConfiguration></Profiles>` +
+		`<Profiles token="sub"><Name>SubStream</Name><VideoEncoderConfiguration><Resolution><Width>640</Width><Height>360</Height></Resolution></VideoEncoderConfiguration></Profiles>` +
+		`</GetProfilesResponse>`
+	testSnapshotUriResponse = `<GetSnapshotUriResponse><MediaUri><Uri>%s/snapshot/%s</Uri></MediaUri></GetSnapshotUriResponse>`
+)
+
+var snapshotProfileTokenRegex = regexp.MustCompile(`<trt:ProfileToken>([^<]*)</trt:ProfileToken>`)
+
+func testProfile(token string, width, height int) xsdOnvif.Profile {
+	profile := xsdOnvif.Profile{Token: xsdOnvif.ReferenceToken(token), Name: xsdOnvif.Name(token)}
+	if width > 0 {
+		w, h := xsd.Int(width), xsd.Int(height)
+		profile.VideoEncoderConfiguration = &xsdOnvif.VideoEncoderConfiguration{
+			Resolution: &xsdOnvif.VideoResolution{Width: &w, Height: &h},
+		}
+	}
+	return profile
+}
+
+func TestSelectSnapshotProfile(t *testing.T) {
+	profiles := []xsdOnvif.Profile{
+		testProfile("main", 1920, 1080),
+		testProfile("sub", 640, 360),
+		testProfile("third", 1280, 720),
+		testProfile("audio", 0, 0),
+	}
+
+	tests := []struct {
+		name          string
+		request       SnapshotRequest
+		expected      string
+		errorExpected bool
+	}{
+		{
+			name:     "default",
+			expected: "main",
+		},
+		{
+			name:     "profile token",
+			request:  SnapshotRequest{ProfileToken: "sub", ProfileName: "main", Width: 1920},
+			expected: "sub",
+		},
+		{
+			name:          "unknown profile token",
+			request:       SnapshotRequest{ProfileToken: "unknown"},
+			errorExpected: true,
+		},
+		{
+			name:     "profile name",
+			request:  SnapshotRequest{ProfileName: "third", Width: 100},
+			expected: "third",
+		},
+		{
+			name:          "unknown profile name",
+			request:       SnapshotRequest{ProfileName: "unknown"},
+			errorExpected: true,
+		},
+		{
+			name:     "thumbnail",
+			request:  SnapshotRequest{Width: 320, Height: 180},
+			expected: "sub",
+		},
+		{
+			name:     "smallest large enough resolution",
+			request:  SnapshotRequest{Width: 1000, Height: 500},
+			expected: "third",
+		},
+		{
+			name:     "height only",
+			request:  SnapshotRequest{Height: 1000},
+			expected: "main",
+		},
+		{
+			name:     "larger than every resolution",
+			request:  SnapshotRequest{Width: 3840, Height: 2160},
+			expected: "main",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			profile, err := selectSnapshotProfile(profiles, test.request)
+			if test.errorExpected {
+				require.Error(t, err)
+				assert.Equal(t, errors.KindEntityDoesNotExist, errors.Kind(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(profile.Token))
+		})
+	}
+
+	profile, err := selectSnapshotProfile([]xsdOnvif.Profile{testProfile("first", 0, 0)}, SnapshotRequest{Width: 320})
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(profile.Token))
+}
+
+func TestParseSnapshotRequest(t *testing.T) {
+	request, err := parseSnapshotRequest(nil)
+	require.NoError(t, err)
+	assert.Equal(t, SnapshotRequest{}, request)
+
+	request, err = parseSnapshotRequest([]byte(`{"Width": 320, "Height": 240}`))
+	require.NoError(t, err)
+	assert.Equal(t, SnapshotRequest{Width: 320, Height: 240}, request)
+
+	request, err = parseSnapshotRequest([]byte(`{"MaxWidth": 320, "Format": "JPG", "Quality": 50}`))
+	require.NoError(t, err)
+	assert.Equal(t, SnapshotRequest{MaxWidth: 320, Format: SnapshotFormatJPEG, Quality: 50}, request)
+
+	_, err = parseSnapshotRequest([]byte(`{"Format": "gif"}`))
+	require.Error(t, err)
+
+	_, err = parseSnapshotRequest([]byte(`{"Quality": 101}`))
+	require.Error(t, err)
+
+	_, err = parseSnapshotRequest([]byte(`{"MaxHeight": -1}`))
+	require.Error(t, err)
+
+	_, err = parseSnapshotRequest([]byte(`{"Width": -1}`))
+	require.Error(t, err)
+
+	_, err = parseSnapshotRequest([]byte(`not json`))
+	require.Error(t, err)
+}
+
+// mockSnapshotCamera sets up the mock device to respond to the Media GetProfiles and GetSnapshotUri requests with the
+// snapshot uris of the server. The GetServices request is not supported, so the Media service is used.
+func mockSnapshotCamera(mockDevice *mocks.OnvifDevice, server *httptest.Server) {
+	mockDevice.On("GetEndpointByRequestStruct", mock.Anything).Return("http://camera/onvif/media_service", nil)
+	mockDevice.On("GetEndpoint", "device").Return("")
+	mockDevice.On("SendSoap", mock.Anything, mock.Anything).Return(func(_ string, body string) *http.Response {
+		content := testProfilesResponse
+		if match := snapshotProfileTokenRegex.FindStringSubmatch(body); match != nil {
+			content = fmt.Sprintf(testSnapshotUriResponse, server.URL, match[1])
+		}
+		response := fmt.Sprintf(testRotationEnvelope, content)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(response))}
+	}, nil)
+	mockDevice.On("GetDeviceParams").Return(onvif.DeviceParams{
+		Username:   testRotationUser,
+		Password:   testRotationPassword,
+		HttpClient: server.Client(),
+		AuthMode:   AuthModeUsernameToken,
+	})
+}
+
+// newSnapshotServer creates a server responding with the name of the requested profile followed by " image"
+func newSnapshotServer(t *testing.T, status int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(path.Base(r.URL.Path) + " image"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOnvifClient_callGetSnapshotFunction(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	mockSnapshotCamera(mockDevice, newSnapshotServer(t, http.StatusOK))
+
+	snapshot, err := onvifClient.callGetSnapshotFunction([]byte(`{"Width": 320}`))
+	require.NoError(t, err)
+	assert.Equal(t, "sub image", string(snapshot.data))
+	mockDevice.AssertNumberOfCalls(t, "SendSoap", 2)
+
+	// the snapshot uri is cached, but the profiles are requested again
+	snapshot, err = onvifClient.callGetSnapshotFunction([]byte(`{"ProfileName": "SubStream"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "sub image", string(snapshot.data))
+	mockDevice.AssertNumberOfCalls(t, "SendSoap", 3)
+
+	snapshot, err = onvifClient.callGetSnapshotFunction(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "main image", string(snapshot.data))
+	mockDevice.AssertNumberOfCalls(t, "SendSoap", 5)
+}
+
+func TestOnvifClient_callGetSnapshotFunction_clearCache(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	mockSnapshotCamera(mockDevice, newSnapshotServer(t, http.StatusNotFound))
+
+	_, err := onvifClient.callGetSnapshotFunction(nil)
+	require.Error(t, err)
+	_, found := driver.snapshotCache.uri(testDeviceName, onvif.MediaWebService, "main")
+	assert.False(t, found)
+	_, found = driver.snapshotCache.preferredMediaService(testDeviceName)
+	assert.False(t, found)
+
+	_, err = onvifClient.callGetSnapshotFunction(nil)
+	require.Error(t, err)
+	mockDevice.AssertNumberOfCalls(t, "SendSoap", 4)
+}
+
+func TestOnvifClient_getMediaProfiles(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	mockStreamCamera(mockDevice, true)
+
+	// Media2 is preferred since the camera reports it in the GetServices response
+	profiles, mediaService, err := onvifClient.getMediaProfiles("")
+	require.NoError(t, err)
+	assert.Equal(t, onvif.Media2WebService, mediaService)
+	require.Len(t, profiles, 1)
+	assert.Equal(t, "main", string(profiles[0].Token))
+	mockDevice.AssertNumberOfCalls(t, "SendSoap", 2)
+
+	// the preferred media service is cached, so only the profiles are requested again
+	_, mediaService, err = onvifClient.getMediaProfiles("")
+	require.NoError(t, err)
+	assert.Equal(t, onvif.Media2WebService, mediaService)
+	mockDevice.AssertNumberOfCalls(t, "SendSoap", 3)
+
+	// the profiles of another media service are requested without replacing the cache
+	profiles, mediaService, err = onvifClient.getMediaProfiles(onvif.MediaWebService)
+	require.NoError(t, err)
+	assert.Equal(t, onvif.MediaWebService, mediaService)
+	assert.Len(t, profiles, 2)
+	mockDevice.AssertNumberOfCalls(t, "SendSoap", 4)
+	cachedService, found := driver.snapshotCache.preferredMediaService(testDeviceName)
+	assert.True(t, found)
+	assert.Equal(t, onvif.Media2WebService, cachedService)
+}
+
+func TestOnvifClient_getMediaProfiles_media2Fallback(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	mockStreamCamera(mockDevice, false)
+
+	profiles, mediaService, err := onvifClient.getMediaProfiles("")
+	require.NoError(t, err)
+	assert.Equal(t, onvif.MediaWebService, mediaService)
+	assert.Len(t, profiles, 2)
+	// GetServices, then the failed Media2 GetProfiles, then the Media GetProfiles
+	mockDevice.AssertNumberOfCalls(t, "SendSoap", 3)
+}
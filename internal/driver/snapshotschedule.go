@@ -0,0 +1,234 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	sdkModel "github.com/edgexfoundry/device-sdk-go/v2/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+)
+
+const (
+	// snapshotScheduleTick is the interval at which the snapshot schedule checks which devices are due for a snapshot
+	snapshotScheduleTick = 10 * time.Second
+	// snapshotFileTimeFormat is the format of the capture time used to name the snapshot files, which sorts
+	// chronologically
+	snapshotFileTimeFormat = "20060102T150405.000Z"
+)
+
+// SnapshotCaptureResult is the reading published for every scheduled snapshot, which references the stored image
+// instead of holding it
+type SnapshotCaptureResult struct {
+	// Location is the file path or the object url of the stored image
+	Location string
+	// SHA256 is the hex encoded SHA-256 hash of the image
+	SHA256 string
+	// Size is the size of the image in bytes
+	Size int
+	// MediaType is the media type of the image
+	MediaType string
+	// Timestamp is the capture time of the image in nanoseconds since the epoch
+	Timestamp int64
+}
+
+// SnapshotScheduler keeps track of the snapshots captured by the snapshot schedule for each device
+type SnapshotScheduler struct {
+	mu sync.Mutex
+	// lastCapture is the time the latest snapshot of each device was started
+	lastCapture map[string]time.Time
+	// running are the devices whose snapshot is being captured
+	running map[string]bool
+}
+
+// NewSnapshotScheduler creates a new SnapshotScheduler
+func NewSnapshotScheduler() *SnapshotScheduler {
+	return &SnapshotScheduler{
+		lastCapture: make(map[string]time.Time),
+		running:     make(map[string]bool),
+	}
+}
+
+// start returns true and marks the capture of the device as running if the device is due for a snapshot.
+// A device is not due while its previous snapshot is still being captured.
+func (s *SnapshotScheduler) start(deviceName string, interval time.Duration, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running[deviceName] {
+		return false
+	}
+	if last, found := s.lastCapture[deviceName]; found && now.Sub(last) < interval {
+		return false
+	}
+	s.lastCapture[deviceName] = now
+	s.running[deviceName] = true
+	return true
+}
+
+// done marks the capture of the device as completed
+func (s *SnapshotScheduler) done(deviceName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.running, deviceName)
+}
+
+// Remove clears the schedule state of the device
+func (s *SnapshotScheduler) Remove(deviceName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.lastCapture, deviceName)
+	delete(s.running, deviceName)
+}
+
+// snapshotIntervalForDevice returns the interval of the scheduled snapshots of the device, or 0 if the device is not
+// scheduled. The SnapshotIntervalSeconds protocol property takes precedence over the SnapshotScheduleLabels, and
+// the shortest interval is used when multiple labels of the device are scheduled.
+func (d *Driver) snapshotIntervalForDevice(device models.Device) time.Duration {
+	d.configMu.RLock()
+	intervalSeconds := 0
+	for _, label := range device.Labels {
+		if seconds, found := d.config.AppCustom.SnapshotScheduleLabels[label]; found && seconds > 0 &&
+			(intervalSeconds == 0 || seconds < intervalSeconds) {
+			intervalSeconds = seconds
+		}
+	}
+	d.configMu.RUnlock()
+
+	intervalSeconds = d.devicePropertyInt(device, SnapshotIntervalSeconds, intervalSeconds)
+	if intervalSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(intervalSeconds) * time.Second
+}
+
+// snapshotScheduleLoop captures the snapshots of the scheduled devices until the driver is stopped
+func (d *Driver) snapshotScheduleLoop() {
+	ticker := time.NewTicker(snapshotScheduleTick)
+	defer ticker.Stop()
+
+	d.lc.Info("Starting snapshot schedule loop.")
+
+	for {
+		select {
+		case <-d.taskCh:
+			return
+		case <-ticker.C:
+			d.captureScheduledSnapshots(time.Now())
+		}
+	}
+}
+
+// captureScheduledSnapshots captures the snapshots of the devices which are due for a snapshot, in the background so
+// a slow camera does not delay the other cameras. Only the UpWithAuth devices are captured when the status check is
+// enabled, as the status of the devices is not kept up to date otherwise.
+func (d *Driver) captureScheduledSnapshots(now time.Time) *sync.WaitGroup {
+	d.configMu.RLock()
+	enableStatusCheck := d.config.AppCustom.EnableStatusCheck
+	d.configMu.RUnlock()
+
+	wg := &sync.WaitGroup{}
+	var store SnapshotStore
+	for _, device := range d.sdkService.Devices() {
+		device := device // save the device value within the closure
+		interval := d.snapshotIntervalForDevice(device)
+		if interval == 0 || (enableStatusCheck && device.Protocols[OnvifProtocol][DeviceStatus] != UpWithAuth) {
+			continue
+		}
+		if store == nil {
+			var edgexErr errors.EdgeX
+			if store, edgexErr = d.newSnapshotStore(); edgexErr != nil {
+				d.lc.Errorf("Unable to capture the scheduled snapshots: %s", edgexErr.Error())
+				return wg
+			}
+		}
+		if !d.snapshotScheduler.start(device.Name, interval, now) {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer d.snapshotScheduler.done(device.Name)
+			if err := d.captureScheduledSnapshot(device.Name, store, now); err != nil {
+				d.lc.Warnf("Unable to capture the scheduled snapshot of device %s: %s", device.Name, err.Error())
+			}
+		}()
+	}
+	return wg
+}
+
+// captureScheduledSnapshot captures the snapshot of the device, stores it, and publishes the SnapshotCaptureResult
+// reading of the device resource with the SnapshotCapture get function
+func (d *Driver) captureScheduledSnapshot(deviceName string, store SnapshotStore, now time.Time) errors.EdgeX {
+	onvifClient, edgexErr := d.getOnvifClient(deviceName)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	d.configMu.RLock()
+	parameters := d.config.AppCustom.SnapshotScheduleParameters
+	d.configMu.RUnlock()
+
+	snapshot, edgexErr := onvifClient.callGetSnapshotFunction([]byte(parameters))
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	fileName := now.UTC().Format(snapshotFileTimeFormat) + snapshotFileExtension(snapshot.mediaType)
+	location, edgexErr := store.Store(deviceName, fileName, snapshot)
+	if edgexErr != nil {
+		if location == "" {
+			return errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+		// the snapshot is stored, only the retention failed
+		d.lc.Warnf("Unable to apply the snapshot retention of device %s: %s", deviceName, edgexErr.Error())
+	}
+	d.lc.Debugf("Stored the scheduled snapshot of device %s to %s", deviceName, location)
+
+	resource, edgexErr := d.getDeviceResourceByGetFunction(deviceName, SnapshotCapture)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("the snapshot was stored to %s but could not be published", location), edgexErr)
+	}
+	hash := sha256.Sum256(snapshot.data)
+	cv, err := sdkModel.NewCommandValue(resource.Name, common.ValueTypeObject, SnapshotCaptureResult{
+		Location:  location,
+		SHA256:    hex.EncodeToString(hash[:]),
+		Size:      len(snapshot.data),
+		MediaType: snapshot.mediaType,
+		Timestamp: now.UnixNano(),
+	})
+	if err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create the commandValue of the snapshot of device %s", deviceName), err)
+	}
+	d.asynchCh <- &sdkModel.AsyncValues{
+		DeviceName:    deviceName,
+		CommandValues: []*sdkModel.CommandValue{cv},
+	}
+	return nil
+}
+
+// snapshotFileExtension returns the file extension of the image media type
+func snapshotFileExtension(mediaType string) string {
+	switch mediaType {
+	case mediaTypeJPEG:
+		return ".jpg"
+	case mediaTypePNG:
+		return ".png"
+	case "image/bmp":
+		return ".bmp"
+	case "image/gif":
+		return ".gif"
+	}
+	return ".img"
+}
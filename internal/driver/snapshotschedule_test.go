@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	sdkModel "github.com/edgexfoundry/device-sdk-go/v2/pkg/models"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotIntervalForDevice(t *testing.T) {
+	driver, _ := createDriverWithMockService()
+	driver.configMu = new(sync.RWMutex)
+	driver.config = &ServiceConfig{AppCustom: CustomConfig{SnapshotScheduleLabels: map[string]int{"qa": 900, "lobby": 60, "off": 0}}}
+
+	tests := []struct {
+		name       string
+		labels     []string
+		properties map[string]string
+		expected   time.Duration
+	}{
+		{
+			name:     "not scheduled",
+			labels:   []string{"other", "off"},
+			expected: 0,
+		},
+		{
+			name:     "label",
+			labels:   []string{"qa"},
+			expected: 15 * time.Minute,
+		},
+		{
+			name:     "shortest label interval",
+			labels:   []string{"qa", "lobby"},
+			expected: time.Minute,
+		},
+		{
+			name:       "protocol property",
+			labels:     []string{"qa"},
+			properties: map[string]string{SnapshotIntervalSeconds: "300"},
+			expected:   5 * time.Minute,
+		},
+		{
+			name:       "disabled by protocol property",
+			labels:     []string{"qa"},
+			properties: map[string]string{SnapshotIntervalSeconds: "0"},
+			expected:   0,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			device := models.Device{Name: testDeviceName, Labels: test.labels,
+				Protocols: map[string]models.ProtocolProperties{OnvifProtocol: test.properties}}
+			assert.Equal(t, test.expected, driver.snapshotIntervalForDevice(device))
+		})
+	}
+}
+
+func TestSnapshotScheduler(t *testing.T) {
+	scheduler := NewSnapshotScheduler()
+	now := time.Now()
+
+	assert.True(t, scheduler.start(testDeviceName, time.Minute, now))
+	// the previous snapshot is still being captured
+	assert.False(t, scheduler.start(testDeviceName, time.Minute, now.Add(2*time.Minute)))
+	scheduler.done(testDeviceName)
+	assert.False(t, scheduler.start(testDeviceName, time.Minute, now.Add(30*time.Second)))
+	assert.True(t, scheduler.start(testDeviceName, time.Minute, now.Add(time.Minute)))
+
+	scheduler.Remove(testDeviceName)
+	assert.Empty(t, scheduler.lastCapture)
+	assert.Empty(t, scheduler.running)
+}
+
+func TestDriver_captureScheduledSnapshots(t *testing.T) {
+	driver, mockService := createDriverWithMockService()
+	dir := t.TempDir()
+	asyncCh := make(chan *sdkModel.AsyncValues, 1)
+	driver.asynchCh = asyncCh
+	driver.configMu = new(sync.RWMutex)
+	driver.clientsMu = new(sync.RWMutex)
+	driver.config = &ServiceConfig{AppCustom: CustomConfig{EnableStatusCheck: true, SnapshotStorage: SnapshotStorageLocal, SnapshotDirectory: dir}}
+
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	mockSnapshotCamera(mockDevice, newSnapshotServer(t, http.StatusOK))
+	driver.onvifClients = map[string]*OnvifClient{testDeviceName: onvifClient}
+
+	device := models.Device{Name: testDeviceName, ProfileName: "onvif-camera", Protocols: map[string]models.ProtocolProperties{
+		OnvifProtocol: {DeviceStatus: UpWithAuth, SnapshotIntervalSeconds: "900"},
+	}}
+	unreachable := models.Device{Name: "unreachable", Protocols: map[string]models.ProtocolProperties{
+		OnvifProtocol: {DeviceStatus: Unreachable, SnapshotIntervalSeconds: "900"},
+	}}
+	mockService.On("Devices").Return([]models.Device{device, unreachable})
+	mockService.On("GetDeviceByName", testDeviceName).Return(device, nil)
+	mockService.On("GetProfileByName", "onvif-camera").Return(models.DeviceProfile{DeviceResources: []models.DeviceResource{
+		{Name: "SnapshotCapture", Attributes: map[string]interface{}{Service: EdgeXWebService, GetFunction: SnapshotCapture}},
+	}}, nil)
+
+	now := time.Date(2022, 10, 18, 10, 15, 0, 0, time.UTC)
+	driver.captureScheduledSnapshots(now).Wait()
+
+	path := filepath.Join(dir, testDeviceName, "20221018T101500.000Z.img")
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "main image", string(data))
+
+	require.Len(t, asyncCh, 1)
+	asyncValues := <-asyncCh
+	assert.Equal(t, testDeviceName, asyncValues.DeviceName)
+	require.Len(t, asyncValues.CommandValues, 1)
+	assert.Equal(t, "SnapshotCapture", asyncValues.CommandValues[0].DeviceResourceName)
+	hash := sha256.Sum256(data)
+	assert.Equal(t, SnapshotCaptureResult{
+		Location:  path,
+		SHA256:    hex.EncodeToString(hash[:]),
+		Size:      len(data),
+		MediaType: "text/plain",
+		Timestamp: now.UnixNano(),
+	}, asyncValues.CommandValues[0].Value)
+
+	// the device is not due again until the interval has elapsed
+	driver.captureScheduledSnapshots(now.Add(time.Minute)).Wait()
+	assert.Empty(t, asyncCh)
+}
+
+func TestDriver_captureScheduledSnapshots_statusCheckDisabled(t *testing.T) {
+	driver, mockService := createDriverWithMockService()
+	dir := t.TempDir()
+	driver.asynchCh = make(chan *sdkModel.AsyncValues, 1)
+	driver.configMu = new(sync.RWMutex)
+	driver.clientsMu = new(sync.RWMutex)
+	driver.config = &ServiceConfig{AppCustom: CustomConfig{SnapshotStorage: SnapshotStorageLocal, SnapshotDirectory: dir}}
+
+	onvifClient, mockDevice := createOnvifClientWithMockDevice(driver, testDeviceName)
+	mockSnapshotCamera(mockDevice, newSnapshotServer(t, http.StatusOK))
+	driver.onvifClients = map[string]*OnvifClient{testDeviceName: onvifClient}
+
+	// the status of the device is never updated without the status check
+	device := models.Device{Name: testDeviceName, ProfileName: "onvif-camera", Protocols: map[string]models.ProtocolProperties{
+		OnvifProtocol: {SnapshotIntervalSeconds: "900"},
+	}}
+	mockService.On("Devices").Return([]models.Device{device})
+	mockService.On("GetDeviceByName", testDeviceName).Return(device, nil)
+	mockService.On("GetProfileByName", "onvif-camera").Return(models.DeviceProfile{DeviceResources: []models.DeviceResource{
+		{Name: "SnapshotCapture", Attributes: map[string]interface{}{Service: EdgeXWebService, GetFunction: SnapshotCapture}},
+	}}, nil)
+
+	now := time.Date(2022, 10, 18, 10, 15, 0, 0, time.UTC)
+	driver.captureScheduledSnapshots(now).Wait()
+
+	_, err := os.Stat(filepath.Join(dir, testDeviceName, "20221018T101500.000Z.img"))
+	require.NoError(t, err)
+}
@@ -0,0 +1,279 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+)
+
+const (
+	// SnapshotStorageLocal stores the scheduled snapshots in the SnapshotDirectory
+	SnapshotStorageLocal = "local"
+	// SnapshotStorageS3 stores the scheduled snapshots in the SnapshotS3Bucket of an S3-compatible object store
+	SnapshotStorageS3 = "s3"
+
+	// S3AccessKeyKey and S3SecretKeyKey are the keys of the object store credentials in the SnapshotS3SecretPath
+	S3AccessKeyKey = "accessKey"
+	S3SecretKeyKey = "secretKey"
+
+	defaultSnapshotS3Region = "us-east-1"
+	// snapshotStoreTimeout is the maximum amount of time to wait when uploading a snapshot to the object store
+	snapshotStoreTimeout = 30 * time.Second
+
+	awsSigningAlgorithm = "AWS4-HMAC-SHA256"
+	awsDateFormat       = "20060102T150405Z"
+)
+
+// SnapshotStore stores the snapshots captured by the snapshot schedule
+type SnapshotStore interface {
+	// Store stores the snapshot of the device under the file name, and returns the path or url of the stored image
+	Store(deviceName string, fileName string, snapshot snapshotImage) (string, errors.EdgeX)
+}
+
+// newSnapshotStore creates the snapshot store configured by the SnapshotStorage
+func (d *Driver) newSnapshotStore() (SnapshotStore, errors.EdgeX) {
+	d.configMu.RLock()
+	config := d.config.AppCustom
+	d.configMu.RUnlock()
+
+	switch strings.ToLower(config.SnapshotStorage) {
+	case "", SnapshotStorageLocal:
+		if config.SnapshotDirectory == "" {
+			return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, "the SnapshotDirectory is not configured", nil)
+		}
+		return &localSnapshotStore{
+			directory:      config.SnapshotDirectory,
+			retentionCount: config.SnapshotRetentionCount,
+			retentionAge:   time.Duration(config.SnapshotRetentionHours) * time.Hour,
+		}, nil
+
+	case SnapshotStorageS3:
+		if config.SnapshotS3Endpoint == "" || config.SnapshotS3Bucket == "" {
+			return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, "the SnapshotS3Endpoint and SnapshotS3Bucket must be configured", nil)
+		}
+		endpoint, err := url.Parse(strings.TrimSuffix(config.SnapshotS3Endpoint, "/"))
+		if err != nil || endpoint.Host == "" {
+			return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid SnapshotS3Endpoint '%s'", config.SnapshotS3Endpoint), err)
+		}
+		secretData, err := d.sdkService.GetSecretProvider().GetSecret(config.SnapshotS3SecretPath, S3AccessKeyKey, S3SecretKeyKey)
+		if err != nil {
+			return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to get the object store credentials from the secret path '%s'", config.SnapshotS3SecretPath), err)
+		}
+		region := config.SnapshotS3Region
+		if region == "" {
+			region = defaultSnapshotS3Region
+		}
+		return &s3SnapshotStore{
+			endpoint:   endpoint,
+			bucket:     config.SnapshotS3Bucket,
+			region:     region,
+			accessKey:  secretData[S3AccessKeyKey],
+			secretKey:  secretData[S3SecretKeyKey],
+			httpClient: &http.Client{Timeout: snapshotStoreTimeout},
+		}, nil
+	}
+	return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("unsupported SnapshotStorage '%s', must be %s or %s", config.SnapshotStorage, SnapshotStorageLocal, SnapshotStorageS3), nil)
+}
+
+// localSnapshotStore stores the snapshots in a sub directory of the directory per device
+type localSnapshotStore struct {
+	directory string
+	// retentionCount is the maximum amount of snapshots kept per device, 0 means no limit
+	retentionCount int
+	// retentionAge is the maximum age of the snapshots kept, 0 means no limit
+	retentionAge time.Duration
+}
+
+// Store writes the snapshot to the directory of the device, then removes the snapshots exceeding the retention limits
+func (s *localSnapshotStore) Store(deviceName string, fileName string, snapshot snapshotImage) (string, errors.EdgeX) {
+	deviceDir := filepath.Join(s.directory, filepath.Base(deviceName))
+	if err := os.MkdirAll(deviceDir, 0750); err != nil {
+		return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to create the snapshot directory %s", deviceDir), err)
+	}
+	path := filepath.Join(deviceDir, fileName)
+	// the snapshot is written to a temporary file first, so a partial file is never seen by the readers
+	if err := ioutil.WriteFile(path+".tmp", snapshot.data, 0640); err != nil {
+		return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to write the snapshot %s", path), err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to write the snapshot %s", path), err)
+	}
+
+	if err := s.applyRetention(deviceDir, time.Now()); err != nil {
+		return path, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to remove the old snapshots of %s", deviceDir), err)
+	}
+	return path, nil
+}
+
+// applyRetention removes the oldest snapshots of the directory exceeding the retention count, and the snapshots
+// older than the retention age
+func (s *localSnapshotStore) applyRetention(deviceDir string, now time.Time) error {
+	if s.retentionCount <= 0 && s.retentionAge <= 0 {
+		return nil
+	}
+	files, err := ioutil.ReadDir(deviceDir)
+	if err != nil {
+		return err
+	}
+	var snapshots []os.FileInfo
+	for _, file := range files {
+		if !file.IsDir() && !strings.HasSuffix(file.Name(), ".tmp") {
+			snapshots = append(snapshots, file)
+		}
+	}
+	// the snapshots are named after their capture time, so the newest snapshots are the last ones
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name() < snapshots[j].Name() })
+
+	var errs []error
+	for i, file := range snapshots {
+		expired := s.retentionAge > 0 && now.Sub(file.ModTime()) > s.retentionAge
+		exceeded := s.retentionCount > 0 && len(snapshots)-i > s.retentionCount
+		if !expired && !exceeded {
+			continue
+		}
+		if err := os.Remove(filepath.Join(deviceDir, file.Name())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return MultiErr(errs)
+	}
+	return nil
+}
+
+// s3SnapshotStore uploads the snapshots to a bucket of an S3-compatible object store, such as MinIO, using
+// path-style urls. The retention of the snapshots is left to the lifecycle rules of the bucket.
+type s3SnapshotStore struct {
+	endpoint   *url.URL
+	bucket     string
+	region     string
+	accessKey  string
+	secretKey  string
+	httpClient *http.Client
+}
+
+// Store uploads the snapshot to the object with the key <deviceName>/<fileName>
+func (s *s3SnapshotStore) Store(deviceName string, fileName string, snapshot snapshotImage) (string, errors.EdgeX) {
+	objectURL := *s.endpoint
+	objectURL.Path = strings.TrimSuffix(objectURL.Path, "/") + "/" + s.bucket + "/" + deviceName + "/" + fileName
+	// the path is encoded the same way it is signed
+	objectURL.RawPath = awsURIEncode(objectURL.Path, false)
+	objectURL.RawQuery = ""
+
+	request, err := http.NewRequest(http.MethodPut, objectURL.String(), bytes.NewReader(snapshot.data))
+	if err != nil {
+		return "", errors.NewCommonEdgeX(errors.KindServerError, "failed to create the snapshot upload request", err)
+	}
+	request.Header.Set("Content-Type", snapshot.mediaType)
+	payloadHash := sha256.Sum256(snapshot.data)
+	signAWSV4(request, hex.EncodeToString(payloadHash[:]), s.region, "s3", s.accessKey, s.secretKey, time.Now())
+
+	resp, err := s.httpClient.Do(request)
+	if err != nil {
+		return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to upload the snapshot to %s", objectURL.String()), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("snapshot upload to %s failed with status %v, %s", objectURL.String(), resp.StatusCode, string(body)), nil)
+	}
+	return objectURL.String(), nil
+}
+
+// signAWSV4 signs the request with the AWS Signature Version 4, which is supported by every S3-compatible object
+// store. Every header of the request is signed, along with the host.
+func signAWSV4(request *http.Request, payloadHash, region, service, accessKey, secretKey string, now time.Time) {
+	amzDate := now.UTC().Format(awsDateFormat)
+	date := amzDate[:8]
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		awsURIEncode(request.URL.Path, false),
+		canonicalQueryString(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{awsSigningAlgorithm, amzDate, scope, hex.EncodeToString(canonicalRequestHash[:])}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), date)
+	for _, value := range []string{region, service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, value)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSigningAlgorithm, accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, value string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(value))
+	return h.Sum(nil)
+}
+
+// canonicalQueryString returns the query parameters sorted by name, and encoded as required by the signature
+func canonicalQueryString(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, awsURIEncode(name, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// awsURIEncode encodes every byte except the unreserved characters, and the slashes unless encodeSlash is set
+func awsURIEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
@@ -0,0 +1,155 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // registers the gif decoder
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	_ "golang.org/x/image/bmp" // registers the bmp decoder
+	"golang.org/x/image/draw"
+)
+
+const (
+	// SnapshotFormatJPEG and SnapshotFormatPNG are the formats the snapshot can be transcoded to
+	SnapshotFormatJPEG = "jpeg"
+	SnapshotFormatPNG  = "png"
+
+	mediaTypeJPEG = "image/jpeg"
+	mediaTypePNG  = "image/png"
+)
+
+// snapshotImage is the image returned by the GetSnapshot command
+type snapshotImage struct {
+	data []byte
+	// mediaType is the media type of the data
+	mediaType string
+	// originalMediaType is the media type of the image sent by the camera
+	originalMediaType string
+}
+
+// normalizeSnapshotFormat returns the snapshot format matching the format parameter, which is case-insensitive
+// and accepts the "jpg" alias
+func normalizeSnapshotFormat(format string) (string, bool) {
+	switch strings.ToLower(format) {
+	case "":
+		return "", true
+	case SnapshotFormatJPEG, "jpg":
+		return SnapshotFormatJPEG, true
+	case SnapshotFormatPNG:
+		return SnapshotFormatPNG, true
+	}
+	return "", false
+}
+
+// detectMediaType returns the media type of the image, detected from its content or else the Content-Type header
+func detectMediaType(data []byte, contentType string) string {
+	mediaType := http.DetectContentType(data)
+	if strings.HasPrefix(mediaType, "image/") || contentType == "" {
+		return mediaType
+	}
+	return strings.TrimSpace(strings.Split(contentType, ";")[0])
+}
+
+// transcodeSnapshot applies the format, quality and maximum resolution of the snapshot request to the image.
+// The image is returned unchanged when the request does not require to decode it, so the common case does not pay
+// the cost of transcoding.
+func transcodeSnapshot(original snapshotImage, request SnapshotRequest) (snapshotImage, errors.EdgeX) {
+	format := request.Format
+	if format == "" && request.Quality > 0 {
+		format = SnapshotFormatJPEG
+	}
+	sameFormat := format == "" || original.mediaType == formatMediaType(format)
+	if sameFormat && request.Quality == 0 && request.MaxWidth == 0 && request.MaxHeight == 0 {
+		return original, nil
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(original.data))
+	if err != nil {
+		return snapshotImage{}, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to decode the snapshot of type %s", original.mediaType), err)
+	}
+	width, height := fitResolution(config.Width, config.Height, request.MaxWidth, request.MaxHeight)
+	resize := width != config.Width || height != config.Height
+	if sameFormat && request.Quality == 0 && !resize {
+		return original, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(original.data))
+	if err != nil {
+		return snapshotImage{}, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to decode the snapshot of type %s", original.mediaType), err)
+	}
+	if resize {
+		scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.BiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+		img = scaled
+	}
+
+	if format == "" {
+		// only the jpeg and png formats can be encoded, and png is kept lossless
+		format = SnapshotFormatJPEG
+		if original.mediaType == mediaTypePNG {
+			format = SnapshotFormatPNG
+		}
+	}
+	var buf bytes.Buffer
+	switch format {
+	case SnapshotFormatPNG:
+		err = png.Encode(&buf, img)
+	default:
+		quality := request.Quality
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return snapshotImage{}, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to encode the snapshot as %s", format), err)
+	}
+	return snapshotImage{
+		data:              buf.Bytes(),
+		mediaType:         formatMediaType(format),
+		originalMediaType: original.originalMediaType,
+	}, nil
+}
+
+// formatMediaType returns the media type of the snapshot format
+func formatMediaType(format string) string {
+	if format == SnapshotFormatPNG {
+		return mediaTypePNG
+	}
+	return mediaTypeJPEG
+}
+
+// fitResolution returns the resolution scaled down to fit within the maximum resolution, keeping the aspect ratio.
+// A maximum width or height of 0 is not limited, and the resolution is never scaled up.
+func fitResolution(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight && float64(maxHeight)/float64(height) < scale {
+		scale = float64(maxHeight) / float64(height)
+	}
+	if scale == 1.0 {
+		return width, height
+	}
+	scaledWidth, scaledHeight := int(float64(width)*scale+0.5), int(float64(height)*scale+0.5)
+	if scaledWidth < 1 {
+		scaledWidth = 1
+	}
+	if scaledHeight < 1 {
+		scaledHeight = 1
+	}
+	return scaledWidth, scaledHeight
+}
@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/bmp"
+)
+
+// testImage returns a gradient image of the resolution
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeTestImage(t *testing.T, encode func(*bytes.Buffer, image.Image) error, width, height int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, encode(&buf, testImage(width, height)))
+	return buf.Bytes()
+}
+
+func TestTranscodeSnapshot(t *testing.T) {
+	jpegData := encodeTestImage(t, func(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) }, 640, 480)
+	pngData := encodeTestImage(t, func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) }, 640, 480)
+	bmpData := encodeTestImage(t, func(buf *bytes.Buffer, img image.Image) error { return bmp.Encode(buf, img) }, 640, 480)
+
+	tests := []struct {
+		name           string
+		data           []byte
+		request        SnapshotRequest
+		unchanged      bool
+		expectedType   string
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{
+			name:      "no transcoding",
+			data:      jpegData,
+			unchanged: true,
+		},
+		{
+			name:      "same format",
+			data:      pngData,
+			request:   SnapshotRequest{Format: SnapshotFormatPNG},
+			unchanged: true,
+		},
+		{
+			name:      "already fits",
+			data:      jpegData,
+			request:   SnapshotRequest{MaxWidth: 1920, MaxHeight: 1080},
+			unchanged: true,
+		},
+		{
+			name:           "thumbnail keeps the aspect ratio",
+			data:           jpegData,
+			request:        SnapshotRequest{MaxWidth: 160, MaxHeight: 160},
+			expectedType:   mediaTypeJPEG,
+			expectedWidth:  160,
+			expectedHeight: 120,
+		},
+		{
+			name:           "png is kept lossless",
+			data:           pngData,
+			request:        SnapshotRequest{MaxHeight: 240},
+			expectedType:   mediaTypePNG,
+			expectedWidth:  320,
+			expectedHeight: 240,
+		},
+		{
+			name:           "quality implies jpeg",
+			data:           pngData,
+			request:        SnapshotRequest{Quality: 50},
+			expectedType:   mediaTypeJPEG,
+			expectedWidth:  640,
+			expectedHeight: 480,
+		},
+		{
+			name:           "bmp to png",
+			data:           bmpData,
+			request:        SnapshotRequest{Format: SnapshotFormatPNG},
+			expectedType:   mediaTypePNG,
+			expectedWidth:  640,
+			expectedHeight: 480,
+		},
+		{
+			name:           "bmp is encoded as jpeg",
+			data:           bmpData,
+			request:        SnapshotRequest{MaxWidth: 64},
+			expectedType:   mediaTypeJPEG,
+			expectedWidth:  64,
+			expectedHeight: 48,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mediaType := detectMediaType(test.data, "")
+			original := snapshotImage{data: test.data, mediaType: mediaType, originalMediaType: mediaType}
+			snapshot, err := transcodeSnapshot(original, test.request)
+			require.NoError(t, err)
+			assert.Equal(t, mediaType, snapshot.originalMediaType)
+			if test.unchanged {
+				assert.Equal(t, original, snapshot)
+				return
+			}
+			assert.Equal(t, test.expectedType, snapshot.mediaType)
+			assert.Equal(t, test.expectedType, detectMediaType(snapshot.data, ""))
+			config, _, decodeErr := image.DecodeConfig(bytes.NewReader(snapshot.data))
+			require.NoError(t, decodeErr)
+			assert.Equal(t, test.expectedWidth, config.Width)
+			assert.Equal(t, test.expectedHeight, config.Height)
+		})
+	}
+
+	_, err := transcodeSnapshot(snapshotImage{data: []byte("not an image"), mediaType: "text/plain"}, SnapshotRequest{MaxWidth: 10})
+	require.Error(t, err)
+}
+
+func TestDetectMediaType(t *testing.T) {
+	assert.Equal(t, mediaTypeJPEG, detectMediaType([]byte("\xff\xd8\xff\xe0"), "application/octet-stream"))
+	assert.Equal(t, "image/x-custom", detectMediaType([]byte("raw"), "image/x-custom; charset=binary"))
+	assert.Equal(t, "text/plain; charset=utf-8", detectMediaType([]byte("raw"), ""))
+}
@@ -0,0 +1,257 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+
+	"github.com/IOTechSystems/onvif"
+	"github.com/IOTechSystems/onvif/media"
+	xsdOnvif "github.com/IOTechSystems/onvif/xsd/onvif"
+)
+
+const (
+	// StreamTransportUDP is the RTP stream over UDP, set up with RTSP
+	StreamTransportUDP = "RTSP/UDP"
+	// StreamTransportTCP is the RTP stream interleaved in the RTSP connection
+	StreamTransportTCP = "RTSP/TCP"
+	// StreamTransportHTTP is the RTP stream interleaved in the RTSP connection tunneled over HTTP
+	StreamTransportHTTP = "RTSP/HTTP"
+)
+
+// streamTransports are the supported transports, in the order they are returned
+var streamTransports = []string{StreamTransportUDP, StreamTransportTCP, StreamTransportHTTP}
+
+// StreamURIRequest holds the optional parameters of the GetStreamURIs command
+type StreamURIRequest struct {
+	// MediaService is the media service used to request the stream uris, either Media or Media2. By default, Media2
+	// is used when the camera supports it.
+	MediaService string
+	// ProfileToken restricts the stream uris to the media profile with the token
+	ProfileToken string
+	// Transports restricts the stream uris to the transports, all the transports are requested by default
+	Transports []string
+	// IncludeCredentials embeds the credentials of the device in the stream uris
+	IncludeCredentials bool
+}
+
+// StreamURI is the stream uri of a media profile for a transport, along with the video encoder settings of the profile
+type StreamURI struct {
+	ProfileToken string
+	ProfileName  string
+	MediaService string
+	Transport    string
+	// Encoding is the video codec of the stream, such as H264 or H265
+	Encoding string
+	Width    int
+	Height   int
+	// FrameRate is the frame rate limit of the stream in frames per second
+	FrameRate float64
+	// Bitrate is the bitrate limit of the stream in kbps
+	Bitrate int
+	URI     string
+}
+
+// parseStreamURIRequest parses the optional jsonObject parameter of the GetStreamURIs command
+func parseStreamURIRequest(data []byte) (StreamURIRequest, errors.EdgeX) {
+	var request StreamURIRequest
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &request); err != nil {
+			return request, errors.NewCommonEdgeX(errors.KindContractInvalid, "failed to unmarshal the GetStreamURIs parameters", err)
+		}
+	}
+	if !isMediaService(request.MediaService) {
+		return request, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("unsupported media service '%s', must be %s or %s", request.MediaService, onvif.MediaWebService, onvif.Media2WebService), nil)
+	}
+	if len(request.Transports) == 0 {
+		request.Transports = streamTransports
+	}
+	for _, transport := range request.Transports {
+		if !isStreamTransport(transport) {
+			return request, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("unsupported stream transport '%s', must be one of %v", transport, streamTransports), nil)
+		}
+	}
+	return request, nil
+}
+
+// isStreamTransport returns true if the transport is supported
+func isStreamTransport(transport string) bool {
+	for _, t := range streamTransports {
+		if t == transport {
+			return true
+		}
+	}
+	return false
+}
+
+// callGetStreamURIsFunction returns the stream uris of the media profiles of the camera for every requested transport.
+// The transports which are not supported by the camera are skipped.
+func (onvifClient *OnvifClient) callGetStreamURIsFunction(data []byte) ([]StreamURI, errors.EdgeX) {
+	request, edgexErr := parseStreamURIRequest(data)
+	if edgexErr != nil {
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	profiles, mediaService, edgexErr := onvifClient.getStreamProfiles(request.MediaService)
+	if edgexErr != nil {
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
+	var username, password string
+	if request.IncludeCredentials {
+		params := onvifClient.getOnvifDevice().GetDeviceParams()
+		username, password = params.Username, params.Password
+	}
+
+	uris := []StreamURI{}
+	var lastErr errors.EdgeX
+	for _, profile := range profiles {
+		if request.ProfileToken != "" && profile.ProfileToken != request.ProfileToken {
+			continue
+		}
+		for _, transport := range request.Transports {
+			uri, edgexErr := onvifClient.getStreamURI(mediaService, profile.ProfileToken, transport)
+			if edgexErr != nil {
+				onvifClient.lc.Debugf("Unable to get the %s stream uri of the profile '%s' of device %s: %s",
+					transport, profile.ProfileToken, onvifClient.DeviceName, edgexErr.Error())
+				lastErr = edgexErr
+				continue
+			}
+			streamURI := profile
+			streamURI.Transport = transport
+			streamURI.URI = embedStreamCredentials(uri, username, password)
+			uris = append(uris, streamURI)
+		}
+	}
+
+	if len(uris) == 0 {
+		if lastErr != nil {
+			return nil, errors.NewCommonEdgeX(errors.Kind(lastErr), "failed to get any stream uri", lastErr)
+		}
+		return nil, errors.NewCommonEdgeX(errors.KindEntityDoesNotExist, fmt.Sprintf("media profile with token '%s' not found", request.ProfileToken), nil)
+	}
+	return uris, nil
+}
+
+// getStreamProfiles returns the media profiles of the media service, or of the preferred media service if empty, as
+// StreamURI without a transport and uri. The media service the profiles were requested from is returned as well.
+func (onvifClient *OnvifClient) getStreamProfiles(mediaService string) ([]StreamURI, string, errors.EdgeX) {
+	mediaProfiles, mediaService, edgexErr := onvifClient.getMediaProfiles(mediaService)
+	if edgexErr != nil {
+		return nil, "", errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	profiles := make([]StreamURI, 0, len(mediaProfiles))
+	for _, profile := range mediaProfiles {
+		profiles = append(profiles, streamProfile(profile, mediaService))
+	}
+	return profiles, mediaService, nil
+}
+
+// streamProfile normalizes the media profile requested from the media service
+func streamProfile(profile xsdOnvif.Profile, mediaService string) StreamURI {
+	streamURI := StreamURI{
+		ProfileToken: string(profile.Token),
+		ProfileName:  string(profile.Name),
+		MediaService: mediaService,
+	}
+	streamURI.Width, streamURI.Height, _ = profileResolution(profile)
+	encoder := profile.VideoEncoderConfiguration
+	if encoder == nil {
+		return streamURI
+	}
+	if encoder.Encoding != nil {
+		streamURI.Encoding = string(*encoder.Encoding)
+	}
+	if encoder.RateControl != nil {
+		if encoder.RateControl.FrameRateLimit != nil {
+			streamURI.FrameRate = float64(*encoder.RateControl.FrameRateLimit)
+		}
+		if encoder.RateControl.BitrateLimit != nil {
+			streamURI.Bitrate = int(*encoder.RateControl.BitrateLimit)
+		}
+	}
+	return streamURI
+}
+
+// getStreamURI returns the stream uri of the media profile for the transport
+func (onvifClient *OnvifClient) getStreamURI(mediaService string, profileToken string, transport string) (string, errors.EdgeX) {
+	if mediaService == onvif.Media2WebService {
+		requestData, err := json.Marshal(media2GetStreamUri{Protocol: media2StreamProtocol(transport), ProfileToken: profileToken})
+		if err != nil {
+			return "", errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the Media2 GetStreamUri request", err)
+		}
+		respContent, edgexErr := onvifClient.callFunction(onvif.Media2WebService, onvif.GetStreamUri, &media2GetStreamUriFunction{}, requestData)
+		if edgexErr != nil {
+			return "", errors.NewCommonEdgeXWrapper(edgexErr)
+		}
+		uriResponse, ok := respContent.(*media2GetStreamUriResponse)
+		if !ok {
+			return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid Media2 GetStreamUriResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+		}
+		return strings.TrimSpace(uriResponse.Uri), nil
+	}
+
+	stream := xsdOnvif.StreamType("RTP-Unicast")
+	protocol := mediaTransportProtocol(transport)
+	token := xsdOnvif.ReferenceToken(profileToken)
+	requestData, err := json.Marshal(media.GetStreamUri{
+		StreamSetup:  &xsdOnvif.StreamSetup{Stream: &stream, Transport: &xsdOnvif.Transport{Protocol: &protocol}},
+		ProfileToken: &token,
+	})
+	if err != nil {
+		return "", errors.NewCommonEdgeX(errors.KindServerError, "failed to marshal the GetStreamUri request", err)
+	}
+	respContent, edgexErr := onvifClient.callOnvifFunction(onvif.MediaWebService, onvif.GetStreamUri, requestData)
+	if edgexErr != nil {
+		return "", errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	uriResponse, ok := respContent.(*media.GetStreamUriResponse)
+	if !ok {
+		return "", errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("invalid GetStreamUriResponse of type %T for the camera %s", respContent, onvifClient.DeviceName), nil)
+	}
+	return string(uriResponse.MediaUri.Uri), nil
+}
+
+// mediaTransportProtocol returns the Media transport protocol of the transport
+func mediaTransportProtocol(transport string) xsdOnvif.TransportProtocol {
+	switch transport {
+	case StreamTransportTCP:
+		return "RTSP"
+	case StreamTransportHTTP:
+		return "HTTP"
+	}
+	return "UDP"
+}
+
+// media2StreamProtocol returns the Media2 stream protocol of the transport
+func media2StreamProtocol(transport string) string {
+	switch transport {
+	case StreamTransportTCP:
+		return media2ProtocolRTSP
+	case StreamTransportHTTP:
+		return media2ProtocolRtspOverHttp
+	}
+	return media2ProtocolRtspUnicast
+}
+
+// embedStreamCredentials returns the stream uri with the credentials embedded, or the uri unchanged if there is no
+// username or the uri can't be parsed
+func embedStreamCredentials(uri string, username string, password string) string {
+	if username == "" {
+		return uri
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Host == "" {
+		return uri
+	}
+	parsed.User = url.UserPassword(username, password)
+	return parsed.String()
+}
@@ -36,6 +36,11 @@ type SubscriptionRequest struct {
 	MessageTimeout *string
 	// MessageTimeout indicates the limit for the number of messages to return at once
 	MessageLimit *int
+
+	// SnapshotOnEvent indicates the device service should capture a snapshot of the camera for every received event
+	SnapshotOnEvent *bool
+	// SnapshotParameters indicates the optional GetSnapshot json parameters of the event snapshot
+	SnapshotParameters *string
 }
 
 func newSubscriptionRequest(attributes map[string]interface{}, requestData []byte) (*SubscriptionRequest, errors.EdgeX) {
@@ -99,6 +104,26 @@ func newSubscriptionRequest(attributes map[string]interface{}, requestData []byt
 		}
 		request.MessageLimit = &val
 	}
+
+	snapshotOnEvent, ok := attributes[DefaultSnapshotOnEvent]
+	if request.SnapshotOnEvent == nil && ok {
+		val, err := strconv.ParseBool(fmt.Sprint(snapshotOnEvent))
+		if err != nil {
+			return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to parse the request attribute '%s'", DefaultSnapshotOnEvent), err)
+		}
+		request.SnapshotOnEvent = &val
+	}
+
+	snapshotParameters, ok := attributes[DefaultSnapshotParameters]
+	if request.SnapshotParameters == nil && ok {
+		val := fmt.Sprint(snapshotParameters)
+		request.SnapshotParameters = &val
+	}
+	if request.SnapshotParameters != nil && *request.SnapshotParameters != "" {
+		if _, edgexErr := parseSnapshotRequest([]byte(*request.SnapshotParameters)); edgexErr != nil {
+			return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, "invalid snapshot parameters", edgexErr)
+		}
+	}
 	return request, nil
 }
 
@@ -57,3 +57,24 @@ func TestParseISO8601(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSubscriptionRequest_snapshotOnEvent(t *testing.T) {
+	attributes := map[string]interface{}{
+		DefaultInitialTerminationTime: "PT1H",
+		DefaultSnapshotOnEvent:        true,
+		DefaultSnapshotParameters:     `{"MaxWidth": 640}`,
+	}
+
+	request, err := newSubscriptionRequest(attributes, []byte(`{}`))
+	require.NoError(t, err)
+	assert.True(t, *request.SnapshotOnEvent)
+	assert.Equal(t, `{"MaxWidth": 640}`, *request.SnapshotParameters)
+
+	// the request takes precedence over the attributes
+	request, err = newSubscriptionRequest(attributes, []byte(`{"SnapshotOnEvent": false}`))
+	require.NoError(t, err)
+	assert.False(t, *request.SnapshotOnEvent)
+
+	_, err = newSubscriptionRequest(attributes, []byte(`{"SnapshotParameters": "{\"Quality\": 200}"}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,247 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+
+	"github.com/IOTechSystems/onvif"
+	onvifdevice "github.com/IOTechSystems/onvif/device"
+	"github.com/IOTechSystems/onvif/xsd"
+	xsdOnvif "github.com/IOTechSystems/onvif/xsd/onvif"
+)
+
+const (
+	// TimeSyncModeManual sets the camera's clock to the host's UTC time using SetSystemDateAndTime
+	TimeSyncModeManual = "manual"
+	// TimeSyncModeNTP configures the camera to synchronize its clock with the TimeSyncNTPServer
+	TimeSyncModeNTP = "ntp"
+
+	// defaultTimeSyncInterval is used when the TimeSyncIntervalSeconds is not a positive value
+	defaultTimeSyncInterval = 3600
+
+	dateTimeTypeManual = "Manual"
+	dateTimeTypeNTP    = "NTP"
+
+	networkHostTypeIPv4 = "IPv4"
+	networkHostTypeDNS  = "DNS"
+)
+
+// timeSyncLoop periodically synchronizes the clocks of the cameras until the taskCh is closed
+func (d *Driver) timeSyncLoop() {
+	d.configMu.RLock()
+	interval := d.config.AppCustom.TimeSyncIntervalSeconds
+	d.configMu.RUnlock()
+	if interval <= 0 {
+		d.lc.Warnf("Time sync interval of %d seconds is invalid. Time sync interval has been set to %d seconds.", interval, defaultTimeSyncInterval)
+		interval = defaultTimeSyncInterval
+	}
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	d.lc.Info("Starting time sync loop.")
+
+	for {
+		select {
+		case <-d.taskCh:
+			return
+		case <-ticker.C:
+			start := time.Now()
+			d.syncDeviceClocks()
+			d.lc.Debugf("syncDeviceClocks completed in: %v", time.Since(start))
+		}
+	}
+}
+
+// syncDeviceClocks corrects the clock of every camera whose clock drift exceeds the TimeSyncThresholdSeconds. Only the
+// UpWithAuth cameras are corrected when the status check is enabled, as the status of the devices is not kept up to
+// date otherwise, in which case the cameras rejecting the credentials fail to set their clock.
+func (d *Driver) syncDeviceClocks() {
+	d.configMu.RLock()
+	enableStatusCheck := d.config.AppCustom.EnableStatusCheck
+	d.configMu.RUnlock()
+
+	wg := sync.WaitGroup{}
+	for _, device := range d.sdkService.Devices() {
+		device := device // save the device value within the closure
+		if enableStatusCheck && device.Protocols[OnvifProtocol][DeviceStatus] != UpWithAuth {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.syncDeviceClock(device); err != nil {
+				d.lc.Warnf("Unable to synchronize the clock of device %s: %s", device.Name, err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// syncDeviceClock corrects the clock of the camera if its drift exceeds the TimeSyncThresholdSeconds, and records
+// the correction in the protocol properties of the device
+func (d *Driver) syncDeviceClock(device models.Device) errors.EdgeX {
+	d.configMu.RLock()
+	threshold := time.Duration(d.config.AppCustom.TimeSyncThresholdSeconds) * time.Second
+	mode := strings.ToLower(d.config.AppCustom.TimeSyncMode)
+	ntpServer := d.config.AppCustom.TimeSyncNTPServer
+	d.configMu.RUnlock()
+
+	onvifClient, edgexErr := d.getOnvifClient(device.Name)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
+	drift, edgexErr := onvifClient.getClockDrift()
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	if !clockDriftExceeded(drift, threshold) {
+		return nil
+	}
+
+	switch mode {
+	case TimeSyncModeNTP:
+		edgexErr = onvifClient.setNTPDateAndTime(ntpServer)
+	case TimeSyncModeManual:
+		edgexErr = onvifClient.setManualDateAndTime(time.Now().UTC())
+	default:
+		d.lc.Warnf("TimeSyncMode is set to an invalid value: %s. Using '%s'.", mode, TimeSyncModeManual)
+		edgexErr = onvifClient.setManualDateAndTime(time.Now().UTC())
+	}
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
+	d.lc.Infof("Corrected the clock of device %s which had drifted by %v", device.Name, drift.Round(time.Second))
+
+	// lookup device from cache to ensure we are updating the latest version
+	device, err := d.sdkService.GetDeviceByName(device.Name)
+	if err != nil {
+		return errors.NewCommonEdgeXWrapper(err)
+	}
+	device.Protocols[OnvifProtocol][LastTimeSync] = time.Now().Format(time.UnixDate)
+	device.Protocols[OnvifProtocol][LastTimeSyncCorrection] = strconv.FormatInt(int64(drift.Round(time.Second)/time.Second), 10)
+	if err = d.sdkService.UpdateDevice(device); err != nil {
+		return errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to update device '%s'", device.Name), err)
+	}
+	return nil
+}
+
+// setManualDateAndTime sets the clock of the camera to the specified UTC time
+func (onvifClient *OnvifClient) setManualDateAndTime(now time.Time) errors.EdgeX {
+	requestData, edgexErr := manualDateAndTimeRequestData(now)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	_, edgexErr = onvifClient.callOnvifFunction(onvif.DeviceWebService, onvif.SetSystemDateAndTime, requestData)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	return nil
+}
+
+// setNTPDateAndTime configures the camera to synchronize its clock with the specified NTP server
+func (onvifClient *OnvifClient) setNTPDateAndTime(ntpServer string) errors.EdgeX {
+	if ntpServer == "" {
+		return errors.NewCommonEdgeX(errors.KindContractInvalid, "TimeSyncNTPServer must be set when using the ntp TimeSyncMode", nil)
+	}
+
+	requestData, edgexErr := setNTPRequestData(ntpServer)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	_, edgexErr = onvifClient.callOnvifFunction(onvif.DeviceWebService, onvif.SetNTP, requestData)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+
+	requestData, edgexErr = marshalRequestData(onvifdevice.SetSystemDateAndTime{
+		DateTimeType:    dateTimeType(dateTimeTypeNTP),
+		DaylightSavings: xsdBoolean(false),
+	})
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	_, edgexErr = onvifClient.callOnvifFunction(onvif.DeviceWebService, onvif.SetSystemDateAndTime, requestData)
+	if edgexErr != nil {
+		return errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	return nil
+}
+
+func manualDateAndTimeRequestData(now time.Time) ([]byte, errors.EdgeX) {
+	now = now.UTC()
+	return marshalRequestData(onvifdevice.SetSystemDateAndTime{
+		DateTimeType:    dateTimeType(dateTimeTypeManual),
+		DaylightSavings: xsdBoolean(false),
+		UTCDateTime: &xsdOnvif.DateTimeRequest{
+			Time: &xsdOnvif.TimeRequest{
+				Hour:   xsdInt(now.Hour()),
+				Minute: xsdInt(now.Minute()),
+				Second: xsdInt(now.Second()),
+			},
+			Date: &xsdOnvif.DateRequest{
+				Year:  xsdInt(now.Year()),
+				Month: xsdInt(int(now.Month())),
+				Day:   xsdInt(now.Day()),
+			},
+		},
+	})
+}
+
+func setNTPRequestData(ntpServer string) ([]byte, errors.EdgeX) {
+	host := xsdOnvif.NetworkHost{
+		Type:    networkHostTypeDNS,
+		DNSname: xsdOnvif.DNSName(ntpServer),
+	}
+	if ip := net.ParseIP(ntpServer); ip != nil && ip.To4() != nil {
+		host = xsdOnvif.NetworkHost{
+			Type:        networkHostTypeIPv4,
+			IPv4Address: xsdOnvif.IPv4Address(ntpServer),
+		}
+	}
+	return marshalRequestData(onvifdevice.SetNTP{
+		FromDHCP:  false,
+		NTPManual: host,
+	})
+}
+
+// marshalRequestData marshals the request struct into the json data expected by callOnvifFunction
+func marshalRequestData(request interface{}) ([]byte, errors.EdgeX) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindServerError, fmt.Sprintf("failed to marshal %T request", request), err)
+	}
+	return data, nil
+}
+
+func dateTimeType(val string) *xsdOnvif.SetDateTimeType {
+	t := xsdOnvif.SetDateTimeType(val)
+	return &t
+}
+
+func xsdBoolean(val bool) *xsd.Boolean {
+	b := xsd.Boolean(val)
+	return &b
+}
+
+func xsdInt(val int) *xsd.Int {
+	i := xsd.Int(val)
+	return &i
+}
@@ -0,0 +1,105 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	onvifdevice "github.com/IOTechSystems/onvif/device"
+	sdkMocks "github.com/edgexfoundry/device-sdk-go/v2/pkg/interfaces/mocks"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManualDateAndTimeRequestData(t *testing.T) {
+	now := time.Date(2022, time.October, 18, 13, 4, 5, 0, time.UTC)
+
+	data, err := manualDateAndTimeRequestData(now)
+	require.NoError(t, err)
+
+	var request onvifdevice.SetSystemDateAndTime
+	require.NoError(t, json.Unmarshal(data, &request))
+	assert.EqualValues(t, dateTimeTypeManual, *request.DateTimeType)
+	assert.EqualValues(t, false, *request.DaylightSavings)
+	assert.EqualValues(t, 2022, *request.UTCDateTime.Date.Year)
+	assert.EqualValues(t, 10, *request.UTCDateTime.Date.Month)
+	assert.EqualValues(t, 18, *request.UTCDateTime.Date.Day)
+	assert.EqualValues(t, 13, *request.UTCDateTime.Time.Hour)
+	assert.EqualValues(t, 4, *request.UTCDateTime.Time.Minute)
+	assert.EqualValues(t, 5, *request.UTCDateTime.Time.Second)
+}
+
+func TestSetNTPRequestData(t *testing.T) {
+	tests := []struct {
+		name         string
+		ntpServer    string
+		expectedType string
+	}{
+		{
+			name:         "ipv4 address",
+			ntpServer:    "192.168.1.10",
+			expectedType: networkHostTypeIPv4,
+		},
+		{
+			name:         "hostname",
+			ntpServer:    "pool.ntp.org",
+			expectedType: networkHostTypeDNS,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			data, err := setNTPRequestData(test.ntpServer)
+			require.NoError(t, err)
+
+			var request onvifdevice.SetNTP
+			require.NoError(t, json.Unmarshal(data, &request))
+			assert.EqualValues(t, false, request.FromDHCP)
+			assert.EqualValues(t, test.expectedType, request.NTPManual.Type)
+			if test.expectedType == networkHostTypeIPv4 {
+				assert.EqualValues(t, test.ntpServer, request.NTPManual.IPv4Address)
+			} else {
+				assert.EqualValues(t, test.ntpServer, request.NTPManual.DNSname)
+			}
+		})
+	}
+}
+
+func TestDriver_syncDeviceClocks_statusCheckDisabled(t *testing.T) {
+	camera := newTestCamera(t)
+	defer camera.server.Close()
+	camera.systemDateTime = time.Now().UTC().Add(-2 * time.Minute)
+	// the status of the device is never updated without the status check
+	device := camera.device(t, "cam1")
+
+	driver, _ := createTestCameraDriver(t, AuthModeUsernameToken, []models.Device{device})
+	driver.config.AppCustom.TimeSyncThresholdSeconds = 10
+	driver.config.AppCustom.TimeSyncMode = TimeSyncModeManual
+	onvifClient, edgexErr := driver.newTemporaryOnvifClientWithCredentials(device,
+		Credentials{Username: testRotationUser, Password: testRotationPassword, AuthMode: AuthModeUsernameToken})
+	require.NoError(t, edgexErr)
+	onvifClient.driver = driver
+	driver.onvifClients[device.Name] = onvifClient
+
+	synced := make(chan models.Device, 1)
+	driver.sdkService.(*sdkMocks.DeviceServiceSDK).On("UpdateDevice", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		synced <- args.Get(0).(models.Device)
+	})
+
+	driver.syncDeviceClocks()
+	require.Len(t, synced, 1)
+	updated := <-synced
+	correction, err := strconv.Atoi(updated.Protocols[OnvifProtocol][LastTimeSyncCorrection])
+	require.NoError(t, err)
+	assert.InDelta(t, -120, correction, 2)
+}
@@ -0,0 +1,177 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/errors"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+)
+
+// httpsUpgradeTransport sends every request using https. The Onvif library always connects to the device service
+// of the camera using http, so this is required for cameras which only serve https.
+type httpsUpgradeTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *httpsUpgradeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Scheme == "http" {
+		request = request.Clone(request.Context())
+		request.URL.Scheme = "https"
+	}
+	return t.base.RoundTrip(request)
+}
+
+// tlsPolicyForDevice returns the TLS policy of the device. The policy is looked up in the order:
+//  1. The TLSPolicy protocol property of the device
+//  2. The policy with the same name as the secret path of the device's credentials
+//  3. The DefaultTLSPolicy
+//
+// The UseHTTPS, TLSPinnedSHA256 and TLSInsecureSkipVerify protocol properties of the device override the policy.
+func (d *Driver) tlsPolicyForDevice(device models.Device) (TLSPolicy, errors.EdgeX) {
+	protocol := device.Protocols[OnvifProtocol]
+	secretPath := d.secretPathForDevice(device)
+
+	d.configMu.RLock()
+	policies := d.config.AppCustom.TLSPolicies
+	name := protocol[TLSPolicyName]
+	if name == "" {
+		if _, found := policies[secretPath]; found {
+			name = secretPath
+		} else {
+			name = d.config.AppCustom.DefaultTLSPolicy
+		}
+	}
+	policy, found := policies[name]
+	d.configMu.RUnlock()
+
+	if name != "" && !found {
+		return TLSPolicy{}, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("TLS policy '%s' of device %s does not exist", name, device.Name), nil)
+	}
+
+	if value := protocol[UseHTTPS]; value != "" {
+		useHTTPS, err := strconv.ParseBool(value)
+		if err != nil {
+			return TLSPolicy{}, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid %s value '%s' for device %s", UseHTTPS, value, device.Name), err)
+		}
+		policy.UseHTTPS = useHTTPS
+	}
+	if value := protocol[TLSInsecureSkipVerify]; value != "" {
+		insecureSkipVerify, err := strconv.ParseBool(value)
+		if err != nil {
+			return TLSPolicy{}, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid %s value '%s' for device %s", TLSInsecureSkipVerify, value, device.Name), err)
+		}
+		policy.InsecureSkipVerify = insecureSkipVerify
+	}
+	if value := protocol[TLSPinnedSHA256]; value != "" {
+		policy.PinnedSHA256 = value
+	}
+
+	return policy, nil
+}
+
+// newHTTPClient creates the http client used to communicate with the device. The client uses the shared transport
+// of the TLS policy of the device, so the connections to the device are reused.
+func (d *Driver) newHTTPClient(device models.Device, timeout time.Duration) (*http.Client, errors.EdgeX) {
+	policy, edgexErr := d.tlsPolicyForDevice(device)
+	if edgexErr != nil {
+		return nil, errors.NewCommonEdgeXWrapper(edgexErr)
+	}
+	transport, err := d.transportPool.Transport(policy)
+	if err != nil {
+		return nil, errors.NewCommonEdgeX(errors.KindContractInvalid, fmt.Sprintf("invalid http transport settings for device %s", device.Name), err)
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if policy.UseHTTPS {
+		roundTripper = &httpsUpgradeTransport{base: transport}
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: roundTripper,
+	}, nil
+}
+
+// newTLSConfig creates the TLS configuration of the policy
+func newTLSConfig(policy TLSPolicy) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if policy.CACertFile != "" {
+		pemCerts, err := ioutil.ReadFile(policy.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA certificates: %w", err)
+		}
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(pemCerts) {
+			return nil, fmt.Errorf("no CA certificates found in %s", policy.CACertFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if policy.ClientCertFile != "" || policy.ClientKeyFile != "" {
+		clientCert, err := tls.LoadX509KeyPair(policy.ClientCertFile, policy.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	pins, err := parseFingerprints(policy.PinnedSHA256)
+	if err != nil {
+		return nil, err
+	}
+	if len(pins) > 0 {
+		// cameras commonly use self-signed certificates, so the pinned fingerprint replaces the verification
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("camera did not present a certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			fingerprint := hex.EncodeToString(sum[:])
+			if _, found := pins[fingerprint]; !found {
+				return fmt.Errorf("certificate fingerprint %s does not match any of the pinned fingerprints", fingerprint)
+			}
+			return nil
+		}
+	} else if policy.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	return tlsConfig, nil
+}
+
+// parseFingerprints parses the comma separated list of hex encoded SHA-256 fingerprints. The hex values may
+// be separated by colons, and are case-insensitive.
+func parseFingerprints(values string) (map[string]struct{}, error) {
+	pins := make(map[string]struct{})
+	for _, value := range splitCSV(values) {
+		fingerprint := strings.ToLower(strings.ReplaceAll(value, ":", ""))
+		if decoded, err := hex.DecodeString(fingerprint); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 fingerprint '%s'", value)
+		}
+		pins[fingerprint] = struct{}{}
+	}
+	return pins, nil
+}
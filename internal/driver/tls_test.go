@@ -0,0 +1,200 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFingerprints(t *testing.T) {
+	fingerprint := strings.Repeat("ab", 32)
+	tests := []struct {
+		name          string
+		input         string
+		expected      []string
+		errorExpected bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:     "hex",
+			input:    fingerprint,
+			expected: []string{fingerprint},
+		},
+		{
+			name:     "upper case with colons",
+			input:    strings.ToUpper(strings.Repeat("ab:", 31) + "ab"),
+			expected: []string{fingerprint},
+		},
+		{
+			name:     "multiple",
+			input:    fingerprint + ", " + strings.Repeat("01", 32),
+			expected: []string{fingerprint, strings.Repeat("01", 32)},
+		},
+		{
+			name:          "too short",
+			input:         "abcdef",
+			errorExpected: true,
+		},
+		{
+			name:          "not hex",
+			input:         strings.Repeat("zz", 32),
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			pins, err := parseFingerprints(test.input)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, pins, len(test.expected))
+			for _, expected := range test.expected {
+				assert.Contains(t, pins, expected)
+			}
+		})
+	}
+}
+
+func TestTLSPolicyForDevice(t *testing.T) {
+	tests := []struct {
+		name          string
+		protocol      models.ProtocolProperties
+		expected      TLSPolicy
+		errorExpected bool
+	}{
+		{
+			name:     "default policy",
+			protocol: models.ProtocolProperties{},
+			expected: TLSPolicy{CACertFile: "default.pem"},
+		},
+		{
+			name:     "credential group policy",
+			protocol: models.ProtocolProperties{SecretPath: "group"},
+			expected: TLSPolicy{UseHTTPS: true},
+		},
+		{
+			name:     "device policy",
+			protocol: models.ProtocolProperties{SecretPath: "group", TLSPolicyName: "device"},
+			expected: TLSPolicy{InsecureSkipVerify: true},
+		},
+		{
+			name: "device overrides",
+			protocol: models.ProtocolProperties{
+				UseHTTPS:              "true",
+				TLSInsecureSkipVerify: "true",
+				TLSPinnedSHA256:       "pin",
+			},
+			expected: TLSPolicy{CACertFile: "default.pem", UseHTTPS: true, InsecureSkipVerify: true, PinnedSHA256: "pin"},
+		},
+		{
+			name:          "unknown policy",
+			protocol:      models.ProtocolProperties{TLSPolicyName: "unknown"},
+			errorExpected: true,
+		},
+		{
+			name:          "invalid override",
+			protocol:      models.ProtocolProperties{UseHTTPS: "maybe"},
+			errorExpected: true,
+		},
+	}
+
+	driver, _ := createTestCameraDriver(t, AuthModeNone, nil)
+	driver.config.AppCustom.DefaultTLSPolicy = "default"
+	driver.config.AppCustom.TLSPolicies = map[string]TLSPolicy{
+		"default": {CACertFile: "default.pem"},
+		"group":   {UseHTTPS: true},
+		"device":  {InsecureSkipVerify: true},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			device := models.Device{Name: "cam1", Protocols: map[string]models.ProtocolProperties{OnvifProtocol: test.protocol}}
+			policy, err := driver.tlsPolicyForDevice(device)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, policy)
+		})
+	}
+}
+
+func TestNewTemporaryOnvifClient_https(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	sum := sha256.Sum256(server.Certificate().Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name          string
+		protocol      models.ProtocolProperties
+		errorExpected bool
+	}{
+		{
+			name:          "http",
+			protocol:      models.ProtocolProperties{},
+			errorExpected: true,
+		},
+		{
+			name:          "untrusted certificate",
+			protocol:      models.ProtocolProperties{UseHTTPS: "true"},
+			errorExpected: true,
+		},
+		{
+			name:     "insecure skip verify",
+			protocol: models.ProtocolProperties{UseHTTPS: "true", TLSInsecureSkipVerify: "true"},
+		},
+		{
+			name:     "pinned fingerprint",
+			protocol: models.ProtocolProperties{UseHTTPS: "true", TLSPinnedSHA256: fingerprint},
+		},
+		{
+			name:          "wrong pinned fingerprint",
+			protocol:      models.ProtocolProperties{UseHTTPS: "true", TLSPinnedSHA256: strings.Repeat("00", 32)},
+			errorExpected: true,
+		},
+	}
+
+	driver, _ := createTestCameraDriver(t, AuthModeNone, nil)
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.protocol[Address] = serverURL.Hostname()
+			test.protocol[Port] = serverURL.Port()
+			device := models.Device{Name: "cam1", Protocols: map[string]models.ProtocolProperties{OnvifProtocol: test.protocol}}
+
+			_, err := driver.newTemporaryOnvifClientWithCredentials(device, noAuthCredentials)
+			if test.errorExpected {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
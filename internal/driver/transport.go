@@ -0,0 +1,163 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHTTPMaxIdleConnsPerHost is used when the HTTPMaxIdleConnsPerHost is not a positive value
+	defaultHTTPMaxIdleConnsPerHost = 2
+	// defaultHTTPIdleConnTimeoutSeconds is used when the HTTPIdleConnTimeoutSeconds is not a positive value
+	defaultHTTPIdleConnTimeoutSeconds = 90
+	// defaultHTTPDialTimeoutMillis is used when the HTTPDialTimeoutMillis is not a positive value
+	defaultHTTPDialTimeoutMillis = 5000
+	// defaultHTTPTLSHandshakeTimeoutMillis is used when the HTTPTLSHandshakeTimeoutMillis is not a positive value
+	defaultHTTPTLSHandshakeTimeoutMillis = 5000
+	// httpKeepAlive is the interval of the TCP keep-alive probes of the connections to the cameras
+	httpKeepAlive = 30 * time.Second
+)
+
+// HTTPTransportSettings holds the connection settings of the http transports shared by the Onvif clients
+type HTTPTransportSettings struct {
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	ProxyURL            string
+}
+
+// newHTTPTransportSettings returns the http transport settings of the configuration
+func newHTTPTransportSettings(config CustomConfig) HTTPTransportSettings {
+	settings := HTTPTransportSettings{
+		MaxIdleConnsPerHost: config.HTTPMaxIdleConnsPerHost,
+		MaxConnsPerHost:     config.HTTPMaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(config.HTTPIdleConnTimeoutSeconds) * time.Second,
+		DialTimeout:         time.Duration(config.HTTPDialTimeoutMillis) * time.Millisecond,
+		TLSHandshakeTimeout: time.Duration(config.HTTPTLSHandshakeTimeoutMillis) * time.Millisecond,
+		ProxyURL:            config.HTTPProxyURL,
+	}
+	if settings.MaxIdleConnsPerHost <= 0 {
+		settings.MaxIdleConnsPerHost = defaultHTTPMaxIdleConnsPerHost
+	}
+	if settings.MaxConnsPerHost < 0 {
+		settings.MaxConnsPerHost = 0
+	}
+	if settings.IdleConnTimeout <= 0 {
+		settings.IdleConnTimeout = defaultHTTPIdleConnTimeoutSeconds * time.Second
+	}
+	if settings.DialTimeout <= 0 {
+		settings.DialTimeout = defaultHTTPDialTimeoutMillis * time.Millisecond
+	}
+	if settings.TLSHandshakeTimeout <= 0 {
+		settings.TLSHandshakeTimeout = defaultHTTPTLSHandshakeTimeoutMillis * time.Millisecond
+	}
+	return settings
+}
+
+// HTTPTransportPool shares the http transports between the Onvif clients, so the connections to the cameras
+// are kept alive and reused instead of being opened for every client. As the TLS settings are part of the
+// transport, there is one transport per TLS policy.
+type HTTPTransportPool struct {
+	mu         sync.Mutex
+	settings   HTTPTransportSettings
+	transports map[TLSPolicy]*http.Transport
+}
+
+// NewHTTPTransportPool creates a new HTTPTransportPool
+func NewHTTPTransportPool(settings HTTPTransportSettings) *HTTPTransportPool {
+	return &HTTPTransportPool{
+		settings:   settings,
+		transports: make(map[TLSPolicy]*http.Transport),
+	}
+}
+
+// Transport returns the shared transport of the TLS policy, creating it if needed
+func (p *HTTPTransportPool) Transport(policy TLSPolicy) (*http.Transport, error) {
+	// the https upgrade is done per client, so it does not require a separate transport
+	policy.UseHTTPS = false
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if transport, found := p.transports[policy]; found {
+		return transport, nil
+	}
+	transport, err := newHTTPTransport(p.settings, policy)
+	if err != nil {
+		return nil, err
+	}
+	p.transports[policy] = transport
+	return transport, nil
+}
+
+// Update replaces the settings of the pool. When the settings changed, the existing transports are discarded and
+// their idle connections closed, so new transports are created with the new settings. The transports are also
+// discarded when resetTLS is true, so the certificate files of the TLS policies are read again.
+// Returns true if the transports were discarded.
+func (p *HTTPTransportPool) Update(settings HTTPTransportSettings, resetTLS bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if settings == p.settings && !resetTLS {
+		return false
+	}
+	p.settings = settings
+	for policy, transport := range p.transports {
+		transport.CloseIdleConnections()
+		delete(p.transports, policy)
+	}
+	return true
+}
+
+// CloseIdleConnections closes the idle connections of every transport
+func (p *HTTPTransportPool) CloseIdleConnections() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, transport := range p.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// newHTTPTransport creates a http transport with the connection settings and the TLS settings of the policy
+func newHTTPTransport(settings HTTPTransportSettings, policy TLSPolicy) (*http.Transport, error) {
+	tlsConfig, err := newTLSConfig(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if settings.ProxyURL != "" {
+		proxyURL, err := url.Parse(settings.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url '%s'", settings.ProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   settings.DialTimeout,
+		KeepAlive: httpKeepAlive,
+	}
+	return &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: settings.TLSHandshakeTimeout,
+		MaxIdleConnsPerHost: settings.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     settings.MaxConnsPerHost,
+		IdleConnTimeout:     settings.IdleConnTimeout,
+	}, nil
+}
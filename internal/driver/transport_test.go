@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//
+// Copyright (C) 2022 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPTransportSettings(t *testing.T) {
+	settings := newHTTPTransportSettings(CustomConfig{HTTPMaxConnsPerHost: -1})
+	assert.Equal(t, HTTPTransportSettings{
+		MaxIdleConnsPerHost: defaultHTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:     defaultHTTPIdleConnTimeoutSeconds * time.Second,
+		DialTimeout:         defaultHTTPDialTimeoutMillis * time.Millisecond,
+		TLSHandshakeTimeout: defaultHTTPTLSHandshakeTimeoutMillis * time.Millisecond,
+	}, settings)
+
+	settings = newHTTPTransportSettings(CustomConfig{
+		HTTPMaxIdleConnsPerHost:       4,
+		HTTPMaxConnsPerHost:           8,
+		HTTPIdleConnTimeoutSeconds:    30,
+		HTTPDialTimeoutMillis:         1000,
+		HTTPTLSHandshakeTimeoutMillis: 2000,
+		HTTPProxyURL:                  "http://proxy:3128",
+	})
+	assert.Equal(t, HTTPTransportSettings{
+		MaxIdleConnsPerHost: 4,
+		MaxConnsPerHost:     8,
+		IdleConnTimeout:     30 * time.Second,
+		DialTimeout:         time.Second,
+		TLSHandshakeTimeout: 2 * time.Second,
+		ProxyURL:            "http://proxy:3128",
+	}, settings)
+}
+
+func TestHTTPTransportPool(t *testing.T) {
+	settings := newHTTPTransportSettings(CustomConfig{HTTPMaxConnsPerHost: 8, HTTPProxyURL: "http://proxy:3128"})
+	pool := NewHTTPTransportPool(settings)
+
+	transport, err := pool.Transport(TLSPolicy{})
+	require.NoError(t, err)
+	assert.Equal(t, 8, transport.MaxConnsPerHost)
+	proxyURL, err := transport.Proxy(&http.Request{URL: &url.URL{Scheme: "http", Host: "camera"}})
+	require.NoError(t, err)
+	assert.Equal(t, "proxy:3128", proxyURL.Host)
+
+	// the https upgrade does not require a separate transport
+	same, err := pool.Transport(TLSPolicy{UseHTTPS: true})
+	require.NoError(t, err)
+	assert.Same(t, transport, same)
+
+	other, err := pool.Transport(TLSPolicy{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	assert.NotSame(t, transport, other)
+
+	assert.False(t, pool.Update(settings, false))
+	same, err = pool.Transport(TLSPolicy{})
+	require.NoError(t, err)
+	assert.Same(t, transport, same)
+
+	assert.True(t, pool.Update(settings, true))
+	recreated, err := pool.Transport(TLSPolicy{})
+	require.NoError(t, err)
+	assert.NotSame(t, transport, recreated)
+
+	settings.ProxyURL = "not a url"
+	assert.True(t, pool.Update(settings, false))
+	_, err = pool.Transport(TLSPolicy{})
+	assert.Error(t, err)
+}
+
+func TestNewTemporaryOnvifClient_sharedTransport(t *testing.T) {
+	connections := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections++
+		}
+	}
+	server.Start()
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	driver, _ := createTestCameraDriver(t, AuthModeNone, nil)
+	device := models.Device{Name: "cam1", Protocols: map[string]models.ProtocolProperties{
+		OnvifProtocol: {Address: serverURL.Hostname(), Port: serverURL.Port()},
+	}}
+	for i := 0; i < 3; i++ {
+		_, edgexErr := driver.newTemporaryOnvifClientWithCredentials(device, noAuthCredentials)
+		require.NoError(t, edgexErr)
+	}
+	// every temporary client reuses the same keep-alive connection
+	assert.Equal(t, 1, connections)
+}